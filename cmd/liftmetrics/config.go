@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"liftmetrics/frontend"
+	"liftmetrics/internal/config"
+	"liftmetrics/internal/plugins"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// settingFlags are the flags every command accepts for the settings in
+// config.Config. Settings given on the command line override the
+// environment, which overrides the configuration file.
+type settingFlags struct {
+	fs         *flag.FlagSet
+	configPath string
+}
+
+// newFlagSet returns the flag set of a command with the setting flags
+// registered. usage is the synopsis printed above the flags.
+func newFlagSet(name, usage string) (*flag.FlagSet, *settingFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	f := &settingFlags{fs: fs}
+	fs.StringVar(&f.configPath, "config", os.Getenv("LIFTMETRICS_CONFIG"), "YAML or TOML configuration file ($LIFTMETRICS_CONFIG)")
+
+	defaults := config.Default()
+	for _, s := range config.Settings {
+		if s.Flag == "" {
+			continue
+		}
+		help := fmt.Sprintf("%s ($%s)", s.Usage, s.Env)
+		if def := *s.Field(&defaults); def != "" {
+			help += fmt.Sprintf(" (default %q)", def)
+		}
+		fs.String(s.Flag, "", help)
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: liftmetrics %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, f
+}
+
+// load returns the configuration after the flags have been parsed.
+func (f *settingFlags) load() (config.Config, error) {
+	cfg, err := config.Load(f.configPath, os.Getenv)
+	if err != nil {
+		return cfg, err
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		for _, s := range config.Settings {
+			if s.Flag == fl.Name {
+				*s.Field(&cfg) = fl.Value.String()
+			}
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n  %s", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+	if cfg.DataDir, err = filepath.Abs(cfg.DataDir); err != nil {
+		return cfg, fmt.Errorf("resolving data directory: %w", err)
+	}
+	return cfg, nil
+}
+
+// frontendAssets returns the frontend files: the override directory if one
+// is configured and the embedded files otherwise.
+func frontendAssets(cfg config.Config) (fs.FS, error) {
+	if cfg.FrontendDir == "" {
+		return frontend.Files, nil
+	}
+	if _, err := os.Stat(filepath.Join(cfg.FrontendDir, "templates", "index.html")); err != nil {
+		return nil, fmt.Errorf("frontend directory: %w", err)
+	}
+	return os.DirFS(cfg.FrontendDir), nil
+}
+
+// loadCustomMetrics loads and dry-runs the user-defined metrics, if a
+// metrics directory is configured.
+func loadCustomMetrics(cfg config.Config) ([]*plugins.Definition, error) {
+	if cfg.MetricsDir == "" {
+		return nil, nil
+	}
+	defs, err := plugins.Load(context.Background(), cfg.MetricsDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading user-defined metrics:\n  %s", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+	log.Printf("Loaded %d user-defined metrics from %s", len(defs), cfg.MetricsDir)
+	return defs, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"liftmetrics/internal/config"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/plugins"
+	"liftmetrics/internal/services"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runIngest implements "liftmetrics ingest", which downloads the latest data
+// and rebuilds the database when a new revision has been published.
+func runIngest(args []string) error {
+	fs, settings := newFlagSet("ingest", "ingest [flags]")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	defs, err := loadCustomMetrics(cfg)
+	if err != nil {
+		return err
+	}
+	return ingestData(cfg, defs)
+}
+
+// runImport implements "liftmetrics import", which rebuilds the database from
+// a CSV file or ZIP archive that is already on disk.
+func runImport(args []string) error {
+	fs, settings := newFlagSet("import", "import [flags] <file.csv|file.zip>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	defs, err := loadCustomMetrics(cfg)
+	if err != nil {
+		return err
+	}
+	if err := createDataDirs(cfg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	if err := services.ImportFile(ctx, fs.Arg(0), cfg.DataDir, cfg.DatabasePath(), plugins.Calculators(defs)); err != nil {
+		return err
+	}
+	return generateLifterNames(cfg)
+}
+
+// runCheckRevision implements "liftmetrics check-revision", which compares
+// the local data with the revision published on the website.
+func runCheckRevision(args []string) error {
+	fs, settings := newFlagSet("check-revision", "check-revision [flags]")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	needsUpdate, err := services.CheckForUpdate(cfg.DataDir, cfg.WebsiteURL)
+	if err != nil {
+		return err
+	}
+	if needsUpdate {
+		fmt.Println("A new revision is available; run liftmetrics ingest to update.")
+	} else {
+		fmt.Println("The data is up to date.")
+	}
+	return nil
+}
+
+// runRecalc implements "liftmetrics recalc", which runs the metric
+// calculations again on the existing database, all of them or one
+// calculator and those depending on it.
+func runRecalc(args []string) error {
+	fs, settings := newFlagSet("recalc", "recalc [flags]")
+	calculator := fs.String("calculator", "", "recompute only this calculator and its dependents")
+	list := fs.Bool("list", false, "list the calculators in the order they run and exit")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	defs, err := loadCustomMetrics(cfg)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		fc := db.NewFeatureCalculator()
+		for _, calc := range plugins.Calculators(defs) {
+			fc.AddCalculator(calc)
+		}
+		names, err := fc.Order()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(cfg.DatabasePath()); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	if err := services.RecalculateMetrics(ctx, cfg.DatabasePath(), *calculator, plugins.Calculators(defs)); err != nil {
+		return err
+	}
+	log.Println("Metrics recalculated.")
+	return nil
+}
+
+// ingestData brings the database up to date with the published data and the
+// user-defined metrics defs, and regenerates lifters.json.
+func ingestData(cfg config.Config, defs []*plugins.Definition) error {
+	if err := createDataDirs(cfg); err != nil {
+		return err
+	}
+
+	log.Println("Checking for updates and processing data if needed...")
+	calcs := plugins.Calculators(defs)
+	err := services.SetupDatabase(cfg.DataURL, cfg.WebsiteURL, cfg.ZipPath(), cfg.DataDir, cfg.DatabasePath(), calcs)
+	if err != nil {
+		log.Printf("Error during database setup: %v", err)
+		return err
+	}
+	// Metrics added since the database was built are calculated now
+	if err := services.CalculateMissing(context.Background(), cfg.DatabasePath(), calcs); err != nil {
+		return err
+	}
+	log.Println("Database setup completed successfully.")
+
+	return generateLifterNames(cfg)
+}
+
+// createDataDirs creates the data directory and the database's directory.
+func createDataDirs(cfg config.Config) error {
+	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath()), os.ModePerm); err != nil {
+		return fmt.Errorf("creating directories: %w", err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, os.ModePerm); err != nil {
+		return fmt.Errorf("creating directories: %w", err)
+	}
+	return nil
+}
+
+// generateLifterNames writes lifters.json, the names offered by search.
+func generateLifterNames(cfg config.Config) error {
+	database, err := db.OpenDatabase(cfg.DatabasePath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer database.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	err = db.GenerateLifterJSON(ctx, database, cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("generating lifter JSON: %w", err)
+	}
+	log.Println("Lifter JSON file generated successfully.")
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/export"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// exportableTables are the tables "liftmetrics export" writes by default.
+var exportableTables = []string{
+	"records", "max_lifts", "attempts", "lifter_metrics", "aggregated_metrics_sbd", "aggregated_metrics_bench",
+	"weight_class_distribution", "age_group_performance", "performance_trends",
+}
+
+// runExport implements "liftmetrics export", which writes whole tables of the
+// database to a CSV file or to an XLSX workbook with a sheet per table.
+func runExport(args []string) error {
+	fs, settings := newFlagSet("export", "export [flags] [table...]")
+	format := fs.String("format", export.FormatXLSX, "output format, csv or xlsx")
+	output := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	tables := fs.Args()
+	if len(tables) == 0 {
+		tables = exportableTables
+	}
+	for _, table := range tables {
+		if !slices.Contains(exportableTables, table) {
+			return fmt.Errorf("unknown table %q, expected one of %s", table, strings.Join(exportableTables, ", "))
+		}
+	}
+	if *format == export.FormatCSV && len(tables) > 1 {
+		return fmt.Errorf("CSV holds a single table; name one of %s", strings.Join(tables, ", "))
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w, err := export.NewTableWriter(*format, out)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.OpenReadOnly(cfg.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	opts := db.ConsoleOptions{Timeout: time.Hour, MaxRows: math.MaxInt}
+	for _, table := range tables {
+		query := fmt.Sprintf("SELECT * FROM %q", table)
+		if _, err := db.RunConsoleQuery(context.Background(), database, query, opts, tableRows{w, table}); err != nil {
+			return fmt.Errorf("exporting %s: %w", table, err)
+		}
+	}
+	return w.Close()
+}
+
+// tableRows adapts a TableWriter to the rows of a single query.
+type tableRows struct {
+	export.TableWriter
+	name string
+}
+
+func (t tableRows) WriteHeader(columns []string) error {
+	return t.StartTable(t.name, columns)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/search"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Output formats of the lifter and compare commands.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+// lookupFlags are the flags shared by the lifter and compare commands.
+type lookupFlags struct {
+	filter db.EntryFilter
+	format string
+}
+
+func addLookupFlags(fs *flag.FlagSet) *lookupFlags {
+	f := &lookupFlags{}
+	fs.StringVar(&f.filter.Equipment, "equipment", "", "only entries with this equipment, e.g. Raw or Single-ply")
+	fs.StringVar(&f.filter.Event, "event", "", "only entries of this event, e.g. SBD or B")
+	fs.StringVar(&f.format, "format", outputTable, "output format: table, json or csv")
+	return f
+}
+
+func (f *lookupFlags) validate() error {
+	switch f.format {
+	case outputTable, outputJSON, outputCSV:
+	default:
+		return fmt.Errorf("unknown format %q, expected table, json or csv", f.format)
+	}
+	return f.filter.Validate()
+}
+
+// lifterReport is everything "liftmetrics lifter" prints about a lifter.
+type lifterReport struct {
+	Profile     db.LifterProfile       `json:"profile"`
+	Stats       []db.LifterStats       `json:"stats"`
+	Progression []db.LifterPerformance `json:"progression"`
+}
+
+// runLifter implements "liftmetrics lifter", which prints a lifter's profile,
+// stats per equipment and event, and meet history. As CSV only the meet
+// history is written.
+func runLifter(args []string) error {
+	fs, settings := newFlagSet("lifter", "lifter [flags] <name>")
+	lookup := addLookupFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	if err := lookup.validate(); err != nil {
+		return err
+	}
+
+	database, err := db.OpenReadOnly(cfg.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	name, err := resolveLifter(ctx, database, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	report, err := getLifterReport(ctx, database, name, lookup.filter)
+	if err != nil {
+		return err
+	}
+
+	switch lookup.format {
+	case outputJSON:
+		return printJSON(report)
+	case outputCSV:
+		progression, err := collectTable("Progression", report.Progression)
+		if err != nil {
+			return err
+		}
+		return progression.writeCSV(os.Stdout)
+	}
+
+	profile, err := profileTable(report.Profile)
+	if err != nil {
+		return err
+	}
+	bests, err := collectTable("Bests", report.Profile.Bests)
+	if err != nil {
+		return err
+	}
+	stats, err := collectTable("Stats", report.Stats)
+	if err != nil {
+		return err
+	}
+	progression, err := collectTable("Meets", report.Progression)
+	if err != nil {
+		return err
+	}
+	for _, t := range []*textTable{profile, bests, stats, progression} {
+		if err := t.print(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCompare implements "liftmetrics compare", which prints the profiles of
+// two lifters side by side with the differences between their numbers,
+// followed by their stats.
+func runCompare(args []string) error {
+	fs, settings := newFlagSet("compare", "compare [flags] <name> <name>")
+	lookup := addLookupFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	if err := lookup.validate(); err != nil {
+		return err
+	}
+
+	database, err := db.OpenReadOnly(cfg.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var reports []lifterReport
+	for _, arg := range fs.Args() {
+		name, err := resolveLifter(ctx, database, arg)
+		if err != nil {
+			return err
+		}
+		report, err := getLifterReport(ctx, database, name, lookup.filter)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	if lookup.format == outputJSON {
+		return printJSON(reports)
+	}
+
+	comparison, err := profileTable(reports[0].Profile, reports[1].Profile)
+	if err != nil {
+		return err
+	}
+	comparison.columns = append(comparison.columns, "difference")
+	for i, row := range comparison.rows {
+		a, aOK := row[1].(float64)
+		b, bOK := row[2].(float64)
+		if aOK && bOK {
+			comparison.rows[i] = append(row, a-b)
+		} else {
+			comparison.rows[i] = append(row, nil)
+		}
+	}
+	if lookup.format == outputCSV {
+		comparison.columns[0] = "field"
+		return comparison.writeCSV(os.Stdout)
+	}
+
+	bests, err := collectTable("Bests", append(reports[0].Profile.Bests, reports[1].Profile.Bests...))
+	if err != nil {
+		return err
+	}
+	stats, err := collectTable("Stats", append(reports[0].Stats, reports[1].Stats...))
+	if err != nil {
+		return err
+	}
+	for _, t := range []*textTable{comparison, bests, stats} {
+		if err := t.print(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profileTable returns the profiles as a table with a column per lifter.
+// Their bests are left out for a table of their own.
+func profileTable(profiles ...db.LifterProfile) (*textTable, error) {
+	table, err := collectTable("", profiles)
+	if err != nil {
+		return nil, err
+	}
+	table = table.transpose("name")
+	table.rows = slices.DeleteFunc(table.rows, func(row []interface{}) bool {
+		return row[0] == "bests"
+	})
+	return table, nil
+}
+
+func getLifterReport(ctx context.Context, database *sql.DB, name string, filter db.EntryFilter) (lifterReport, error) {
+	var report lifterReport
+	var err error
+	if report.Profile, err = db.GetLifterProfile(ctx, database, name, filter); err != nil {
+		return report, fmt.Errorf("getting profile of %s: %w", name, err)
+	}
+	if report.Stats, err = db.GetLifterStats(ctx, database, name, filter); err != nil && !errors.Is(err, db.ErrNoRows) {
+		return report, fmt.Errorf("getting stats of %s: %w", name, err)
+	}
+	if report.Progression, err = db.GetLifterPerformanceOverTime(ctx, database, name, filter); err != nil && !errors.Is(err, db.ErrNoRows) {
+		return report, fmt.Errorf("getting progression of %s: %w", name, err)
+	}
+	return report, nil
+}
+
+// resolveLifter returns the lifter name refers to. When it matches several
+// lifters, such as "John Smith #1" and "John Smith #2", or none but similar
+// names exist, the user is asked to choose if stdin is a terminal; otherwise
+// the candidates are listed in the error.
+func resolveLifter(ctx context.Context, database *sql.DB, name string) (string, error) {
+	matches, err := db.MatchLifterNames(ctx, database, name)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	prompt := fmt.Sprintf("%q matches several lifters", name)
+	if len(matches) == 0 {
+		if matches, err = similarLifterNames(ctx, database, name); err != nil {
+			return "", err
+		}
+		if len(matches) == 0 {
+			return "", fmt.Errorf("no lifter named %q", name)
+		}
+		prompt = fmt.Sprintf("No lifter named %q. Did you mean", name)
+	}
+
+	page, err := db.ListLifters(ctx, database, db.LifterFilter{Names: matches}, len(matches), 0)
+	if err != nil {
+		return "", err
+	}
+	if !isTerminal(os.Stdin) {
+		names := make([]string, len(page.Lifters))
+		for i, l := range page.Lifters {
+			names[i] = strconv.Quote(l.Name)
+		}
+		return "", fmt.Errorf("%s: %s", prompt, strings.Join(names, ", "))
+	}
+	return chooseLifter(os.Stdin, os.Stderr, prompt, page.Lifters)
+}
+
+// similarLifterNames returns up to five names close to name.
+func similarLifterNames(ctx context.Context, database *sql.DB, name string) ([]string, error) {
+	lifters, err := db.GetAllLifters(ctx, database)
+	if errors.Is(err, db.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(lifters))
+	for i, l := range lifters {
+		names[i] = l.Name
+	}
+
+	var similar []string
+	for _, r := range search.NewIndex(names).Search(name, 5) {
+		similar = append(similar, r.Name)
+	}
+	return similar, nil
+}
+
+// chooseLifter lists candidates on out and reads the number of one from in.
+func chooseLifter(in io.Reader, out io.Writer, prompt string, candidates []db.LifterSummary) (string, error) {
+	fmt.Fprintf(out, "%s:\n", prompt)
+	for i, l := range candidates {
+		fmt.Fprintf(out, "  %d) %s  %s, %s, %s, last meet %s\n", i+1, l.Name, l.Sex, l.Country, l.Federation, l.LastMeetDate)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Choose a lifter [1-%d]: ", len(candidates))
+		if !scanner.Scan() {
+			return "", errors.New("no lifter chosen")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err == nil && n >= 1 && n <= len(candidates) {
+			return candidates[n-1].Name, nil
+		}
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
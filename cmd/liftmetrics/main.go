@@ -1,131 +1,66 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"liftmetrics/internal/db"
-	"liftmetrics/internal/services"
-	"liftmetrics/internal/web"
 	"log"
 	"os"
-	"path/filepath"
-	"time"
 )
 
-const (
-	dataURL    = "https://openpowerlifting.gitlab.io/opl-csv/files/openipf-latest.zip"
-	websiteURL = "https://openpowerlifting.gitlab.io/opl-csv/bulk-csv.html"
-	dataDir    = "../../data"
-	zipFile    = "openipf-latest.zip"
-	dbName     = "openipf.db"
-)
+// command is a subcommand of liftmetrics. run receives the arguments after
+// the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "download new data if needed and start the web server", runServe},
+	{"ingest", "download the latest data and rebuild the database if it is out of date", runIngest},
+	{"import", "rebuild the database from a local CSV file or ZIP archive", runImport},
+	{"check-revision", "report whether newer data has been published", runCheckRevision},
+	{"recalc", "recalculate the metrics of the existing database", runRecalc},
+	{"export", "write database tables to a CSV file or XLSX workbook", runExport},
+	{"query", "run a read-only SQL query against the database", runQuery},
+	{"lifter", "print a lifter's profile, stats and meets", runLifter},
+	{"compare", "compare two lifters side by side", runCompare},
+}
 
 func main() {
 	// Set up logging to include date, time, and file information
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
-	// Get the current working directory
-	pwd, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("Failed to get current working directory: %v", err)
-	}
-	log.Printf("Current working directory: %s", pwd)
-
-	// Construct the path to interface.html
-	indexHTMLPath := filepath.Join(pwd, "..", "..", "frontend", "templates", "index.html")
-	log.Printf("Constructed index.html path: %s", indexHTMLPath)
-
-	// Check if the file exists
-	if _, err := os.Stat(indexHTMLPath); os.IsNotExist(err) {
-		log.Fatalf("index.html does not exist at path: %s", indexHTMLPath)
-	}
-
-	// Get the absolute path for the data directory
-	absDataDir, err := filepath.Abs(dataDir)
-	if err != nil {
-		log.Fatalf("Failed to get absolute path for data directory: %v", err)
-	}
-
-	// Set up file paths
-	filePath := filepath.Join(absDataDir, zipFile)
-	dbDir := filepath.Join(absDataDir, "db")
-	dbFilePath := filepath.Join(dbDir, dbName)
-
-	// Create necessary directories
-	if err := os.MkdirAll(dbDir, os.ModePerm); err != nil {
-		log.Fatalf("Failed to create directories: %v", err)
-	}
-
-	// Set up or update the database
-	if err := setupDatabase(dataURL, websiteURL, filePath, absDataDir, dbFilePath); err != nil {
-		log.Fatalf("Failed to setup database: %v", err)
+	// Without a command, or with only flags, the server is started as before
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
 	}
-
-	// Open the existing database
-	database, err := db.OpenDatabase(dbFilePath)
-	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
-	}
-	defer database.Close()
-
-	// Construct the path to lifters.json
-	liftersJSONPath := filepath.Join(pwd, "..", "..", "data", "lifters.json")
-	log.Printf("Constructed lifters.json path: %s", liftersJSONPath)
-	// Load lifter names
-	lifterNames, err := loadLifterNames(liftersJSONPath)
-	if err != nil {
-		log.Fatalf("Failed to load lifter names: %v", err)
-	}
-
-	// Log the number of lifter names loaded
-	log.Printf("Loaded %d lifter names", len(lifterNames))
-
-	// Create and start the HTTP server
-	server := web.NewServer(lifterNames, database, indexHTMLPath)
-	log.Fatal(server.Start(":8080"))
-}
-
-// setupDatabase handles the process of setting up or updating the database
-func setupDatabase(dataURL, websiteURL, filePath, absDataDir, dbFilePath string) error {
-	log.Println("Checking for updates and processing data if needed...")
-	err := services.SetupDatabase(dataURL, websiteURL, filePath, absDataDir, dbFilePath)
-	if err != nil {
-		log.Printf("Error during database setup: %v", err)
-		return err
+	if name == "sql" {
+		name = "query"
 	}
-	log.Println("Database setup completed successfully.")
-
-	// Generate JSON file of lifter names
-	database, err := db.OpenDatabase(dbFilePath)
-	if err != nil {
-		return fmt.Errorf("opening database: %w", err)
+	if name == "help" {
+		usage()
+		return
 	}
-	defer database.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	err = db.GenerateLifterJSON(ctx, database, absDataDir)
-	if err != nil {
-		return fmt.Errorf("generating lifter JSON: %w", err)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
-	log.Println("Lifter JSON file generated successfully.")
-
-	return nil
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
 }
 
-func loadLifterNames(filePath string) ([]string, error) {
-	jsonData, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: liftmetrics <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", cmd.name, cmd.summary)
 	}
-
-	var lifterNames []string
-	err = json.Unmarshal(jsonData, &lifterNames)
-	if err != nil {
-		return nil, err
-	}
-
-	return lifterNames, nil
+	fmt.Fprintln(os.Stderr, "\nRun liftmetrics <command> -h for the flags of a command.")
 }
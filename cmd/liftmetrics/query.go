@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/export"
+	"os"
+	"strings"
+)
+
+// runQuery implements "liftmetrics query", which runs a read-only query
+// against the local database and writes the result to stdout. The query is
+// taken from the arguments or, if there are none, from stdin. "liftmetrics
+// sql" is an alias.
+func runQuery(args []string) error {
+	fs, settings := newFlagSet("query", "query [flags] [query]")
+	format := fs.String("format", "csv", "output format, csv or json")
+	maxRows := fs.Int("max-rows", db.DefaultConsoleOptions.MaxRows, "maximum number of rows to print")
+	timeout := fs.Duration("timeout", db.DefaultConsoleOptions.Timeout, "statement timeout")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading query from stdin: %w", err)
+		}
+		query = string(data)
+	}
+	if strings.TrimSpace(query) == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	database, err := db.OpenReadOnly(cfg.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	opts := db.ConsoleOptions{Timeout: *timeout, MaxRows: *maxRows}
+	var truncated bool
+	switch *format {
+	case "csv":
+		w := export.NewCSVRows(os.Stdout)
+		truncated, err = db.RunConsoleQuery(context.Background(), database, query, opts, w)
+		if finishErr := w.Finish(); err == nil {
+			err = finishErr
+		}
+	case "json":
+		w := export.NewJSONRows(os.Stdout)
+		truncated, err = db.RunConsoleQuery(context.Background(), database, query, opts, w)
+		if w.Started() {
+			w.Finish(truncated, err)
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected csv or json", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Output truncated to %d rows\n", *maxRows)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"liftmetrics/internal/config"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/plugins"
+	"liftmetrics/internal/services"
+	"liftmetrics/internal/web"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runServe implements "liftmetrics serve", which brings the database up to
+// date unless -ingest=false is given and then serves the web interface and
+// the API until it receives SIGINT or SIGTERM.
+//
+// On the first start, when there is no database yet, the server starts
+// right away in a loading state and the data is ingested in the background.
+func runServe(args []string) error {
+	fs, settings := newFlagSet("serve", "serve [flags]")
+	ingest := fs.Bool("ingest", true, "download new data, if any, before serving")
+	fs.Parse(args)
+
+	cfg, err := settings.load()
+	if err != nil {
+		return err
+	}
+	log.Printf("Data directory: %s", cfg.DataDir)
+
+	assets, err := frontendAssets(cfg)
+	if err != nil {
+		return err
+	}
+	defs, err := loadCustomMetrics(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create the HTTP server; its data is installed once it is loaded
+	server, err := web.NewServer(nil, nil, assets)
+	if err != nil {
+		return err
+	}
+	server.CustomMetrics = defs
+
+	// The SQL console is only enabled when a token is configured
+	if cfg.SQLToken != "" {
+		readOnly, err := db.OpenReadOnly(cfg.DatabasePath())
+		if err != nil {
+			return err
+		}
+		defer readOnly.Close()
+		server.SQLConsole = &web.SQLConsoleConfig{DB: readOnly, Token: cfg.SQLToken, Options: db.DefaultConsoleOptions}
+		log.Println("SQL console enabled at /api/v1/sql")
+	}
+
+	// loaded receives the database once it is open, to be closed on exit
+	loaded := make(chan *sql.DB, 1)
+	defer func() {
+		select {
+		case database := <-loaded:
+			database.Close()
+		default:
+		}
+	}()
+
+	load := func() error {
+		database, lifterNames, err := openData(cfg)
+		if err != nil {
+			return err
+		}
+		loaded <- database
+		server.SetData(lifterNames, database)
+		return nil
+	}
+
+	switch {
+	case *ingest && !dataExists(cfg):
+		log.Println("No data yet; serving a loading state during the first ingest")
+		server.SetLoading()
+		go func() {
+			err := ingestData(cfg, defs)
+			if err == nil {
+				err = load()
+			}
+			if err != nil {
+				log.Printf("Failed to load data: %v", err)
+				server.SetLoadFailed(err)
+			}
+		}()
+	case *ingest:
+		if err := ingestData(cfg, defs); err != nil {
+			return fmt.Errorf("setting up database: %w", err)
+		}
+		fallthrough
+	default:
+		// Calculate the metrics added since the database was built; after
+		// an ingest, none are left
+		if err := services.CalculateMissing(ctx, cfg.DatabasePath(), plugins.Calculators(defs)); err != nil {
+			return err
+		}
+		if err := load(); err != nil {
+			return err
+		}
+	}
+
+	read, write, idle, shutdown := cfg.Timeouts()
+	return server.Serve(ctx, cfg.Addr, web.Timeouts{Read: read, Write: write, Idle: idle, Shutdown: shutdown})
+}
+
+// dataExists reports whether a database and lifters.json have been built.
+func dataExists(cfg config.Config) bool {
+	for _, path := range []string{cfg.DatabasePath(), cfg.LifterNamesPath()} {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// openData opens the database and loads the lifter names.
+func openData(cfg config.Config) (*sql.DB, []string, error) {
+	database, err := db.OpenDatabase(cfg.DatabasePath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	lifterNames, err := loadLifterNames(cfg.LifterNamesPath())
+	if err != nil {
+		database.Close()
+		return nil, nil, fmt.Errorf("loading lifter names: %w", err)
+	}
+	log.Printf("Loaded %d lifter names", len(lifterNames))
+	return database, lifterNames, nil
+}
+
+func loadLifterNames(filePath string) ([]string, error) {
+	jsonData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var lifterNames []string
+	err = json.Unmarshal(jsonData, &lifterNames)
+	if err != nil {
+		return nil, err
+	}
+
+	return lifterNames, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"liftmetrics/internal/export"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// textTable is a table printed as aligned columns. Tables of structs are
+// built with export.WriteStructs, so they have the columns of the JSON API.
+type textTable struct {
+	name    string
+	columns []string
+	rows    [][]interface{}
+}
+
+// collectTable returns rows, a slice of structs, as a table.
+func collectTable(name string, rows interface{}) (*textTable, error) {
+	var c tableCollector
+	if err := export.WriteStructs(&c, name, rows); err != nil {
+		return nil, err
+	}
+	return c.table, nil
+}
+
+// tableCollector is an export.TableWriter keeping a single table in memory.
+type tableCollector struct {
+	table *textTable
+}
+
+func (c *tableCollector) StartTable(name string, columns []string) error {
+	c.table = &textTable{name: name, columns: columns}
+	return nil
+}
+
+func (c *tableCollector) WriteRow(values []interface{}) error {
+	c.table.rows = append(c.table.rows, append([]interface{}{}, values...))
+	return nil
+}
+
+func (c *tableCollector) Close() error {
+	return nil
+}
+
+// transpose returns the table with a row per column, headed by the values of
+// the header column, e.g. the lifters' names.
+func (t *textTable) transpose(header string) *textTable {
+	out := &textTable{name: t.name, columns: []string{""}}
+	h := indexOf(t.columns, header)
+	for _, row := range t.rows {
+		out.columns = append(out.columns, export.FormatValue(row[h]))
+	}
+	for i, column := range t.columns {
+		if i == h {
+			continue
+		}
+		values := []interface{}{column}
+		for _, row := range t.rows {
+			values = append(values, row[i])
+		}
+		out.rows = append(out.rows, values)
+	}
+	return out
+}
+
+// print writes the table with its name as a title and aligned columns.
+// Numbers are rounded to two decimals.
+func (t *textTable) print(w io.Writer) error {
+	if t.name != "" {
+		fmt.Fprintf(w, "%s\n", t.name)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.columns, "\t")+"\t")
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = formatCell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t")+"\t")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func formatCell(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	}
+	return export.FormatValue(v)
+}
+
+// writeCSV writes the table as CSV.
+func (t *textTable) writeCSV(w io.Writer) error {
+	rows := export.NewCSVRows(w)
+	if err := rows.WriteHeader(t.columns); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if err := rows.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return rows.Finish()
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
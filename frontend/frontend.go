@@ -0,0 +1,10 @@
+// Package frontend holds the web interface's templates and static assets,
+// embedded so the binary runs from any directory.
+package frontend
+
+import "embed"
+
+// Files contains templates/index.html and the static directory.
+//
+//go:embed templates static
+var Files embed.FS
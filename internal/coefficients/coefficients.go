@@ -0,0 +1,158 @@
+// Package coefficients computes the bodyweight-adjusted scores that
+// OpenPowerlifting publishes with every entry: Dots, Wilks, Glossbrenner and
+// the IPF GL points it calls Goodlift.
+//
+// The formulas follow OpenPowerlifting's implementation, including how it
+// clamps bodyweights, so that the results match its CSV. Every function
+// returns 0 where the score is undefined: for a sex other than M, Mx or F, a
+// bodyweight or total that is not positive, or, for Goodlift, an event or
+// equipment the formula does not cover.
+package coefficients
+
+import "math"
+
+// isMale reports whether sex uses the men's parameters, and defined whether
+// the sex is known at all. Mx lifters are scored as men.
+func isMale(sex string) (male, defined bool) {
+	switch sex {
+	case "M", "Mx":
+		return true, true
+	case "F":
+		return false, true
+	}
+	return false, false
+}
+
+// points multiplies total by the coefficient for sex, or returns 0 if the
+// score is undefined.
+func points(sex string, bodyweightKg, totalKg float64, men, women func(float64) float64) float64 {
+	male, ok := isMale(sex)
+	if !ok || bodyweightKg <= 0 || totalKg <= 0 {
+		return 0
+	}
+	if male {
+		return totalKg * men(bodyweightKg)
+	}
+	return totalKg * women(bodyweightKg)
+}
+
+// polynomial evaluates c[0] + c[1]x + c[2]x² + ...
+func polynomial(x float64, c ...float64) float64 {
+	var sum float64
+	for i := len(c) - 1; i >= 0; i-- {
+		sum = sum*x + c[i]
+	}
+	return sum
+}
+
+func clamp(x, min, max float64) float64 {
+	return math.Max(min, math.Min(max, x))
+}
+
+// Dots returns the Dots points of a total.
+func Dots(sex string, bodyweightKg, totalKg float64) float64 {
+	return points(sex, bodyweightKg, totalKg, dotsMen, dotsWomen)
+}
+
+func dotsMen(bw float64) float64 {
+	return 500 / polynomial(clamp(bw, 40, 210), -307.75076, 24.0900756, -0.1918759221, 0.0007391293, -0.000001093)
+}
+
+func dotsWomen(bw float64) float64 {
+	return 500 / polynomial(clamp(bw, 40, 150), -57.96288, 13.6175032, -0.1126655495, 0.0005158568, -0.0000010706)
+}
+
+// Wilks returns the Wilks points of a total, using the original formula
+// rather than its 2020 revision.
+func Wilks(sex string, bodyweightKg, totalKg float64) float64 {
+	return points(sex, bodyweightKg, totalKg, wilksMen, wilksWomen)
+}
+
+func wilksMen(bw float64) float64 {
+	return 500 / polynomial(clamp(bw, 40, 201.9),
+		-216.0475144, 16.2606339, -0.002388645, -0.00113732, 7.01863e-06, -1.291e-08)
+}
+
+func wilksWomen(bw float64) float64 {
+	return 500 / polynomial(clamp(bw, 26.51, 154.53),
+		594.31747775582, -27.23842536447, 0.82112226871, -0.00930733913, 4.731582e-05, -9.054e-08)
+}
+
+// Glossbrenner returns the Glossbrenner points of a total. It averages the
+// Schwartz (men) or Malone (women) coefficient with Wilks, replaced by a
+// linear fit above the bodyweight where Wilks stops being usable.
+func Glossbrenner(sex string, bodyweightKg, totalKg float64) float64 {
+	return points(sex, bodyweightKg, totalKg, glossbrennerMen, glossbrennerWomen)
+}
+
+func glossbrennerMen(bw float64) float64 {
+	if bw < 153.05 {
+		return (schwartz(bw) + wilksMen(bw)) / 2
+	}
+	return (schwartz(bw) - 0.000821668402557*bw + 0.676940740094416) / 2
+}
+
+func glossbrennerWomen(bw float64) float64 {
+	if bw < 106.3 {
+		return (malone(bw) + wilksWomen(bw)) / 2
+	}
+	return (malone(bw) - 0.000313738002024*bw + 0.852664892884785) / 2
+}
+
+func schwartz(bw float64) float64 {
+	return 3565.902903983125*math.Pow(math.Max(bw, 40), -2.244917050872728) + 0.445775838479913
+}
+
+func malone(bw float64) float64 {
+	return 106.011586323613*math.Pow(math.Max(bw, 29.24), -1.293027130579051) + 0.322935585328304
+}
+
+// goodliftParameters are the A, B and C of the IPF GL formula.
+type goodliftParameters struct{ a, b, c float64 }
+
+// goodliftTable holds the parameters by sex, equipped and bench only.
+var goodliftTable = map[[3]bool]goodliftParameters{
+	{true, false, false}:  {1199.72839, 1025.18162, 0.00921},
+	{true, true, false}:   {1236.25115, 1449.21864, 0.01644},
+	{true, false, true}:   {320.98041, 281.40258, 0.01008},
+	{true, true, true}:    {381.22073, 733.79378, 0.02398},
+	{false, false, false}: {610.32796, 1045.59282, 0.03048},
+	{false, true, false}:  {758.63878, 949.31382, 0.02435},
+	{false, false, true}:  {142.40398, 442.52671, 0.04724},
+	{false, true, true}:   {221.82209, 357.00377, 0.02937},
+}
+
+// Goodlift returns the IPF GL points of a total. The formula has
+// parameters for full power (SBD) and bench only (B), each raw and
+// equipped. Wraps and Straps are scored as raw and every kind of equipped
+// lifting as single-ply. Bodyweights below 35 kg are not scored.
+func Goodlift(sex, equipment, event string, bodyweightKg, totalKg float64) float64 {
+	male, ok := isMale(sex)
+	if !ok || bodyweightKg < 35 || totalKg <= 0 {
+		return 0
+	}
+
+	var equipped bool
+	switch equipment {
+	case "Raw", "Wraps", "Straps":
+	case "Single-ply", "Multi-ply", "Unlimited":
+		equipped = true
+	default:
+		return 0
+	}
+	var benchOnly bool
+	switch event {
+	case "SBD":
+	case "B":
+		benchOnly = true
+	default:
+		return 0
+	}
+
+	p := goodliftTable[[3]bool{male, equipped, benchOnly}]
+	denominator := p.a - p.b*math.Exp(-p.c*bodyweightKg)
+	if denominator <= 0 {
+		return 0
+	}
+	return totalKg * 100 / denominator
+}
@@ -0,0 +1,81 @@
+package coefficients
+
+import (
+	"math"
+	"testing"
+)
+
+// TestScores compares the formulas with values computed independently to
+// four decimals, across both sexes and the Glossbrenner breakpoints.
+func TestScores(t *testing.T) {
+	tests := []struct {
+		sex                       string
+		bodyweight, total         float64
+		dots, wilks, glossbrenner float64
+	}{
+		{"M", 93, 700, 445.3758, 439.7331, 423.4387},
+		{"M", 59.2, 520.5, 444.2252, 449.4316, 438.1932},
+		{"M", 140, 900, 493.2179, 502.9260, 476.4685},
+		{"M", 180, 1000, 512.8745, 538.1732, 502.8337},
+		{"Mx", 93, 700, 445.3758, 439.7331, 423.4387},
+		{"F", 57, 400, 458.2781, 464.1577, 410.4259},
+		{"F", 84, 480, 441.6737, 428.0353, 374.2058},
+		{"F", 120, 550, 441.3031, 439.8110, 372.6744},
+	}
+	for _, tt := range tests {
+		for name, got := range map[string][2]float64{
+			"Dots":         {Dots(tt.sex, tt.bodyweight, tt.total), tt.dots},
+			"Wilks":        {Wilks(tt.sex, tt.bodyweight, tt.total), tt.wilks},
+			"Glossbrenner": {Glossbrenner(tt.sex, tt.bodyweight, tt.total), tt.glossbrenner},
+		} {
+			if math.Abs(got[0]-got[1]) > 1e-4 {
+				t.Errorf("%s(%s, %v, %v) = %.4f, want %.4f", name, tt.sex, tt.bodyweight, tt.total, got[0], got[1])
+			}
+		}
+	}
+}
+
+func TestGoodlift(t *testing.T) {
+	tests := []struct {
+		sex, equipment, event string
+		bodyweight, total     float64
+		want                  float64
+	}{
+		{"M", "Raw", "SBD", 93, 700, 91.5748},
+		{"M", "Wraps", "SBD", 93, 700, 91.5748},
+		{"M", "Single-ply", "SBD", 105, 950, 97.1024},
+		{"M", "Multi-ply", "SBD", 105, 950, 97.1024},
+		{"M", "Raw", "B", 83, 200, 100.4586},
+		{"F", "Raw", "SBD", 63, 450, 98.4524},
+		{"F", "Single-ply", "B", 72, 160, 89.5155},
+		{"M", "Raw", "D", 93, 300, 0},
+		{"M", "Raw", "SBD", 34, 300, 0},
+		{"F", "", "SBD", 63, 450, 0},
+	}
+	for _, tt := range tests {
+		if got := Goodlift(tt.sex, tt.equipment, tt.event, tt.bodyweight, tt.total); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("Goodlift(%s, %s, %s, %v, %v) = %.4f, want %.4f",
+				tt.sex, tt.equipment, tt.event, tt.bodyweight, tt.total, got, tt.want)
+		}
+	}
+}
+
+// TestUndefined tests that scores are 0 without a known sex, a bodyweight
+// or a total, and that bodyweights are clamped rather than extrapolated.
+func TestUndefined(t *testing.T) {
+	for name, got := range map[string]float64{
+		"unknown sex":          Dots("", 93, 700),
+		"no bodyweight":        Wilks("M", 0, 700),
+		"no total":             Glossbrenner("F", 60, 0),
+		"disqualified":         Dots("M", 93, -700),
+		"Goodlift unknown sex": Goodlift("X", "Raw", "SBD", 93, 700),
+	} {
+		if got != 0 {
+			t.Errorf("%s: %v, want 0", name, got)
+		}
+	}
+
+	if Dots("M", 250, 1000) != Dots("M", 210, 1000) || Wilks("F", 20, 300) != Wilks("F", 26.51, 300) {
+		t.Error("bodyweights outside the formula's range are not clamped")
+	}
+}
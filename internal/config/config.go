@@ -0,0 +1,239 @@
+// Package config holds the settings of liftmetrics. Settings are read from a
+// YAML or TOML file and the environment, and the command line overrides both.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings shared by all commands.
+type Config struct {
+	// DataDir holds the downloaded archive, the extracted CSV, the database
+	// and lifters.json.
+	DataDir string `yaml:"data_dir" toml:"data_dir"`
+	// DBPath is the SQLite database. It defaults to db/openipf.db in DataDir.
+	DBPath string `yaml:"db_path" toml:"db_path"`
+	// DataURL is the ZIP archive of the OpenIPF data.
+	DataURL string `yaml:"data_url" toml:"data_url"`
+	// WebsiteURL is the page that states the current data revision.
+	WebsiteURL string `yaml:"website_url" toml:"website_url"`
+	// Addr is the address the server listens on.
+	Addr string `yaml:"addr" toml:"addr"`
+	// ReadTimeout, WriteTimeout and IdleTimeout limit the connections of
+	// the server, and ShutdownTimeout how long it waits for in-flight
+	// requests when stopped. They are Go durations such as "30s"; "0"
+	// means no limit.
+	ReadTimeout     string `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    string `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     string `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout string `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// FrontendDir, if set, is read for templates and static files instead
+	// of the copies embedded in the binary.
+	FrontendDir string `yaml:"frontend_dir" toml:"frontend_dir"`
+	// MetricsDir, if set, holds the definitions of user-defined metrics.
+	MetricsDir string `yaml:"metrics_dir" toml:"metrics_dir"`
+	// SQLToken enables the SQL console over HTTP for requests bearing it.
+	SQLToken string `yaml:"sql_token" toml:"sql_token"`
+}
+
+// Default returns the settings used for anything not configured.
+func Default() Config {
+	return Config{
+		DataDir:    "data",
+		DataURL:    "https://openpowerlifting.gitlab.io/opl-csv/files/openipf-latest.zip",
+		WebsiteURL: "https://openpowerlifting.gitlab.io/opl-csv/bulk-csv.html",
+		Addr:       ":8080",
+
+		ReadTimeout:     "15s",
+		WriteTimeout:    "5m",
+		IdleTimeout:     "2m",
+		ShutdownTimeout: "30s",
+	}
+}
+
+// Setting describes one setting: its key in configuration files, its
+// command line flag and its environment variable.
+type Setting struct {
+	Key   string
+	Flag  string // empty if the setting has no flag
+	Env   string
+	Usage string
+	field func(*Config) *string
+}
+
+// Field returns the field of cfg that holds the setting.
+func (s Setting) Field(cfg *Config) *string {
+	return s.field(cfg)
+}
+
+// Settings lists every setting. BASE_DIR is kept for the data directory as
+// the container image has always set it.
+var Settings = []Setting{
+	{"data_dir", "data-dir", "BASE_DIR", "directory for the downloaded data and the database",
+		func(c *Config) *string { return &c.DataDir }},
+	{"db_path", "db", "LIFTMETRICS_DB", "path to the SQLite database (default <data-dir>/db/openipf.db)",
+		func(c *Config) *string { return &c.DBPath }},
+	{"data_url", "data-url", "LIFTMETRICS_DATA_URL", "URL of the OpenIPF ZIP archive",
+		func(c *Config) *string { return &c.DataURL }},
+	{"website_url", "website-url", "LIFTMETRICS_WEBSITE_URL", "URL of the page stating the current data revision",
+		func(c *Config) *string { return &c.WebsiteURL }},
+	{"addr", "addr", "LIFTMETRICS_ADDR", "address to listen on",
+		func(c *Config) *string { return &c.Addr }},
+	{"read_timeout", "read-timeout", "LIFTMETRICS_READ_TIMEOUT", "time allowed to read a request",
+		func(c *Config) *string { return &c.ReadTimeout }},
+	{"write_timeout", "write-timeout", "LIFTMETRICS_WRITE_TIMEOUT", "time allowed to write a response, including large exports",
+		func(c *Config) *string { return &c.WriteTimeout }},
+	{"idle_timeout", "idle-timeout", "LIFTMETRICS_IDLE_TIMEOUT", "time an idle keep-alive connection is kept open",
+		func(c *Config) *string { return &c.IdleTimeout }},
+	{"shutdown_timeout", "shutdown-timeout", "LIFTMETRICS_SHUTDOWN_TIMEOUT", "time in-flight requests are given to finish on shutdown",
+		func(c *Config) *string { return &c.ShutdownTimeout }},
+	{"frontend_dir", "frontend-dir", "LIFTMETRICS_FRONTEND_DIR", "serve templates and static files from this directory instead of the embedded ones",
+		func(c *Config) *string { return &c.FrontendDir }},
+	{"metrics_dir", "metrics-dir", "LIFTMETRICS_METRICS_DIR", "directory of user-defined metric definitions",
+		func(c *Config) *string { return &c.MetricsDir }},
+	// The token has no flag so that it does not show up in process lists.
+	{"sql_token", "", "LIFTMETRICS_SQL_TOKEN", "token enabling the SQL console over HTTP",
+		func(c *Config) *string { return &c.SQLToken }},
+}
+
+// Load returns the default settings overridden by the configuration file at
+// path, if path is not empty, and then by the environment as read by getenv.
+func Load(path string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.ApplyEnv(getenv)
+	return cfg, nil
+}
+
+// LoadFile overrides the settings present in the configuration file at path.
+// Files ending in .toml are read as TOML and all others as YAML. Unknown keys
+// are an error, and relative paths are taken relative to the file.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening config file: %w", err)
+	}
+	defer f.Close()
+
+	var file Config
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		dec := toml.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	} else {
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err = dec.Decode(&file); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&file.DataDir, &file.DBPath, &file.FrontendDir, &file.MetricsDir} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for _, s := range Settings {
+		if v := *s.Field(&file); v != "" {
+			*s.Field(c) = v
+		}
+	}
+	return nil
+}
+
+// ApplyEnv overrides the settings whose environment variables are set.
+func (c *Config) ApplyEnv(getenv func(string) string) {
+	for _, s := range Settings {
+		if v := getenv(s.Env); v != "" {
+			*s.Field(c) = v
+		}
+	}
+}
+
+// Validate reports every invalid setting, each prefixed with its key.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.DataDir == "" {
+		invalid("data_dir", "must not be empty")
+	}
+	for _, u := range []struct{ key, value string }{{"data_url", c.DataURL}, {"website_url", c.WebsiteURL}} {
+		parsed, err := url.Parse(u.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid(u.key, "%q is not an http or https URL", u.value)
+		}
+	}
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid("addr", "%q is not a host:port address", c.Addr)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		invalid("addr", "%q has an invalid port", c.Addr)
+	}
+	for _, d := range []struct{ key, value string }{
+		{"read_timeout", c.ReadTimeout}, {"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout}, {"shutdown_timeout", c.ShutdownTimeout},
+	} {
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			invalid(d.key, "%q is not a duration such as 30s", d.value)
+		}
+	}
+	for _, d := range []struct{ key, value string }{{"frontend_dir", c.FrontendDir}, {"metrics_dir", c.MetricsDir}} {
+		if d.value == "" {
+			continue
+		}
+		if info, err := os.Stat(d.value); err != nil || !info.IsDir() {
+			invalid(d.key, "%q is not a directory", d.value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Timeouts returns the server timeouts. It must only be called on a valid
+// configuration.
+func (c Config) Timeouts() (read, write, idle, shutdown time.Duration) {
+	read, _ = time.ParseDuration(c.ReadTimeout)
+	write, _ = time.ParseDuration(c.WriteTimeout)
+	idle, _ = time.ParseDuration(c.IdleTimeout)
+	shutdown, _ = time.ParseDuration(c.ShutdownTimeout)
+	return read, write, idle, shutdown
+}
+
+// ZipPath is where the downloaded archive is kept.
+func (c Config) ZipPath() string {
+	return filepath.Join(c.DataDir, "openipf-latest.zip")
+}
+
+// DatabasePath is the path of the SQLite database.
+func (c Config) DatabasePath() string {
+	if c.DBPath != "" {
+		return c.DBPath
+	}
+	return filepath.Join(c.DataDir, "db", "openipf.db")
+}
+
+// LifterNamesPath is the path of lifters.json.
+func (c Config) LifterNamesPath() string {
+	return filepath.Join(c.DataDir, "lifters.json")
+}
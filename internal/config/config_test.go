@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoad tests that the environment overrides the file, which overrides
+// the defaults, for both file formats.
+func TestLoad(t *testing.T) {
+	env := map[string]string{"LIFTMETRICS_ADDR": ":9090"}
+	for name, content := range map[string]string{
+		"config.yaml": "data_dir: store\naddr: ':8081'\nsql_token: secret\n",
+		"config.toml": "data_dir = 'store'\naddr = ':8081'\nsql_token = 'secret'\n",
+	} {
+		path := writeFile(t, name, content)
+		cfg, err := Load(path, func(key string) string { return env[key] })
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := Default()
+		want.DataDir = filepath.Join(filepath.Dir(path), "store")
+		want.Addr = ":9090"
+		want.SQLToken = "secret"
+		if cfg != want {
+			t.Errorf("%s: got %+v, want %+v", name, cfg, want)
+		}
+		if got := cfg.DatabasePath(); got != filepath.Join(want.DataDir, "db", "openipf.db") {
+			t.Errorf("%s: DatabasePath() = %s", name, got)
+		}
+	}
+
+	if _, err := Load(writeFile(t, "config.yml", "data_directory: x\n"), os.Getenv); err == nil {
+		t.Error("unknown YAML key accepted")
+	}
+	if _, err := Load(writeFile(t, "config.toml", "port = 1\n"), os.Getenv); err == nil {
+		t.Error("unknown TOML key accepted")
+	}
+	if _, err := Load(writeFile(t, "empty.yaml", ""), os.Getenv); err != nil {
+		t.Errorf("empty file: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+
+	cfg := Default()
+	cfg.DataDir = ""
+	cfg.DataURL = "ftp://example.com/data.zip"
+	cfg.Addr = "8080"
+	cfg.FrontendDir = filepath.Join(t.TempDir(), "missing")
+	cfg.WriteTimeout = "5"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, key := range []string{"data_dir:", "data_url:", "addr:", "frontend_dir:", "write_timeout:"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AggregateFilter restricts the rows returned from the aggregate tables.
+// Zero values mean no restriction. Not every table has every column; the
+// query functions document which fields they honour.
+type AggregateFilter struct {
+	Sex       string
+	Equipment string
+	Name      string
+	YearFrom  int
+	YearTo    int
+	// Limit, when positive, restricts the per-lifter tables to Limit
+	// lifters in name order, after skipping Offset of them.
+	Limit  int
+	Offset int
+}
+
+// WeightClassCount is a row of weight_class_distribution.
+type WeightClassCount struct {
+	Year        int    `json:"year"`
+	WeightClass string `json:"weightClass"`
+	Sex         string `json:"sex"`
+	Equipment   string `json:"equipment"`
+	Count       int    `json:"count"`
+}
+
+// AgeGroupStats is a row of age_group_performance. The averages, medians
+// and percentiles are over the entries with a successful lift and are null
+// if there are none.
+type AgeGroupStats struct {
+	Year           int      `json:"year"`
+	AgeClass       string   `json:"ageClass"`
+	Sex            string   `json:"sex"`
+	Equipment      string   `json:"equipment"`
+	AvgSquat       *float64 `json:"avgSquat"`
+	AvgBench       *float64 `json:"avgBench"`
+	AvgDeadlift    *float64 `json:"avgDeadlift"`
+	AvgTotal       *float64 `json:"avgTotal"`
+	MedianSquat    *float64 `json:"medianSquat"`
+	P10Squat       *float64 `json:"p10Squat"`
+	P25Squat       *float64 `json:"p25Squat"`
+	P75Squat       *float64 `json:"p75Squat"`
+	P90Squat       *float64 `json:"p90Squat"`
+	MedianBench    *float64 `json:"medianBench"`
+	P10Bench       *float64 `json:"p10Bench"`
+	P25Bench       *float64 `json:"p25Bench"`
+	P75Bench       *float64 `json:"p75Bench"`
+	P90Bench       *float64 `json:"p90Bench"`
+	MedianDeadlift *float64 `json:"medianDeadlift"`
+	P10Deadlift    *float64 `json:"p10Deadlift"`
+	P25Deadlift    *float64 `json:"p25Deadlift"`
+	P75Deadlift    *float64 `json:"p75Deadlift"`
+	P90Deadlift    *float64 `json:"p90Deadlift"`
+	MedianTotal    *float64 `json:"medianTotal"`
+	P10Total       *float64 `json:"p10Total"`
+	P25Total       *float64 `json:"p25Total"`
+	P75Total       *float64 `json:"p75Total"`
+	P90Total       *float64 `json:"p90Total"`
+}
+
+// PerformanceTrend is a row of performance_trends. Its medians and
+// percentiles are computed like those of AgeGroupStats.
+type PerformanceTrend struct {
+	Year           int      `json:"year"`
+	Sex            string   `json:"sex"`
+	Equipment      string   `json:"equipment"`
+	AvgSquat       *float64 `json:"avgSquat"`
+	AvgBench       *float64 `json:"avgBench"`
+	AvgDeadlift    *float64 `json:"avgDeadlift"`
+	AvgTotal       *float64 `json:"avgTotal"`
+	MedianSquat    *float64 `json:"medianSquat"`
+	P10Squat       *float64 `json:"p10Squat"`
+	P25Squat       *float64 `json:"p25Squat"`
+	P75Squat       *float64 `json:"p75Squat"`
+	P90Squat       *float64 `json:"p90Squat"`
+	MedianBench    *float64 `json:"medianBench"`
+	P10Bench       *float64 `json:"p10Bench"`
+	P25Bench       *float64 `json:"p25Bench"`
+	P75Bench       *float64 `json:"p75Bench"`
+	P90Bench       *float64 `json:"p90Bench"`
+	MedianDeadlift *float64 `json:"medianDeadlift"`
+	P10Deadlift    *float64 `json:"p10Deadlift"`
+	P25Deadlift    *float64 `json:"p25Deadlift"`
+	P75Deadlift    *float64 `json:"p75Deadlift"`
+	P90Deadlift    *float64 `json:"p90Deadlift"`
+	MedianTotal    *float64 `json:"medianTotal"`
+	P10Total       *float64 `json:"p10Total"`
+	P25Total       *float64 `json:"p25Total"`
+	P75Total       *float64 `json:"p75Total"`
+	P90Total       *float64 `json:"p90Total"`
+}
+
+// LifterAggregateSBD is a row of aggregated_metrics_sbd. Averages over meets
+// without a usable value are null.
+type LifterAggregateSBD struct {
+	Name                          string   `json:"name"`
+	Equipment                     string   `json:"equipment"`
+	AvgSuccessfulSquatAttempts    *float64 `json:"avgSuccessfulSquatAttempts"`
+	AvgSuccessfulBenchAttempts    *float64 `json:"avgSuccessfulBenchAttempts"`
+	AvgSuccessfulDeadliftAttempts *float64 `json:"avgSuccessfulDeadliftAttempts"`
+	AvgTotalSuccessfulAttempts    *float64 `json:"avgTotalSuccessfulAttempts"`
+	AvgSquat1Perc                 *float64 `json:"avgSquat1Perc"`
+	AvgSquat2Perc                 *float64 `json:"avgSquat2Perc"`
+	AvgSquat3Perc                 *float64 `json:"avgSquat3Perc"`
+	AvgBench1Perc                 *float64 `json:"avgBench1Perc"`
+	AvgBench2Perc                 *float64 `json:"avgBench2Perc"`
+	AvgBench3Perc                 *float64 `json:"avgBench3Perc"`
+	AvgDeadlift1Perc              *float64 `json:"avgDeadlift1Perc"`
+	AvgDeadlift2Perc              *float64 `json:"avgDeadlift2Perc"`
+	AvgDeadlift3Perc              *float64 `json:"avgDeadlift3Perc"`
+	AvgSquat1To2Kg                *float64 `json:"avgSquat1To2Kg"`
+	AvgSquat2To3Kg                *float64 `json:"avgSquat2To3Kg"`
+	AvgBench1To2Kg                *float64 `json:"avgBench1To2Kg"`
+	AvgBench2To3Kg                *float64 `json:"avgBench2To3Kg"`
+	AvgDeadlift1To2Kg             *float64 `json:"avgDeadlift1To2Kg"`
+	AvgDeadlift2To3Kg             *float64 `json:"avgDeadlift2To3Kg"`
+}
+
+// LifterAggregateBench is a row of aggregated_metrics_bench. Averages over
+// meets without a usable value are null.
+type LifterAggregateBench struct {
+	Name                       string   `json:"name"`
+	Equipment                  string   `json:"equipment"`
+	AvgSuccessfulBenchAttempts *float64 `json:"avgSuccessfulBenchAttempts"`
+	AvgBench1Perc              *float64 `json:"avgBench1Perc"`
+	AvgBench2Perc              *float64 `json:"avgBench2Perc"`
+	AvgBench3Perc              *float64 `json:"avgBench3Perc"`
+	AvgBench1To2Kg             *float64 `json:"avgBench1To2Kg"`
+	AvgBench2To3Kg             *float64 `json:"avgBench2To3Kg"`
+}
+
+// where builds a WHERE clause from the filter fields that apply to a table.
+func (f AggregateFilter) where(hasYear, hasSex, hasName bool) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if hasSex && f.Sex != "" {
+		conds = append(conds, "Sex = ?")
+		args = append(args, f.Sex)
+	}
+	if f.Equipment != "" {
+		conds = append(conds, "Equipment = ?")
+		args = append(args, f.Equipment)
+	}
+	if hasName && f.Name != "" {
+		conds = append(conds, "Name = ?")
+		args = append(args, f.Name)
+	}
+	if hasYear && f.YearFrom != 0 {
+		conds = append(conds, "Year >= ?")
+		args = append(args, f.YearFrom)
+	}
+	if hasYear && f.YearTo != 0 {
+		conds = append(conds, "Year <= ?")
+		args = append(args, f.YearTo)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// queryAggregate runs query and calls scan for every row, within the timeout
+// shared by all aggregate queries.
+func queryAggregate(ctx context.Context, db *sql.DB, op, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	return withTimeout(ctx, 10*time.Second, op, func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+}
+
+// GetWeightClassDistribution retrieves lifter counts per weight class. It
+// honours the Sex, Equipment and year range filters.
+func GetWeightClassDistribution(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]WeightClassCount, error) {
+	where, args := filter.where(true, true, false)
+	query := `SELECT Year, WeightClass, Sex, Equipment, Count FROM weight_class_distribution` + where +
+		` ORDER BY Year, Sex, Equipment, CAST(REPLACE(REPLACE(WeightClass, '+', ''), '-', '') AS REAL), WeightClass LIKE '+%'`
+
+	rows := []WeightClassCount{}
+	err := queryAggregate(ctx, db, "querying weight class distribution", query, args, func(r *sql.Rows) error {
+		var w WeightClassCount
+		if err := r.Scan(&w.Year, &w.WeightClass, &w.Sex, &w.Equipment, &w.Count); err != nil {
+			return err
+		}
+		rows = append(rows, w)
+		return nil
+	})
+	return rows, err
+}
+
+// GetAgeGroupPerformance retrieves the average, median and percentile lifts
+// per age class. It honours the Sex, Equipment and year range filters.
+func GetAgeGroupPerformance(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]AgeGroupStats, error) {
+	where, args := filter.where(true, true, false)
+	query := `SELECT Year, AgeClass, Sex, Equipment, AvgSquat, AvgBench, AvgDeadlift, AvgTotal, ` + liftDistributionColumns + `
+		FROM age_group_performance` + where + ` ORDER BY Year, Sex, Equipment, AgeClass`
+
+	rows := []AgeGroupStats{}
+	err := queryAggregate(ctx, db, "querying age group performance", query, args, func(r *sql.Rows) error {
+		var a AgeGroupStats
+		if err := r.Scan(&a.Year, &a.AgeClass, &a.Sex, &a.Equipment, &a.AvgSquat, &a.AvgBench, &a.AvgDeadlift, &a.AvgTotal,
+			&a.MedianSquat, &a.P10Squat, &a.P25Squat, &a.P75Squat, &a.P90Squat,
+			&a.MedianBench, &a.P10Bench, &a.P25Bench, &a.P75Bench, &a.P90Bench,
+			&a.MedianDeadlift, &a.P10Deadlift, &a.P25Deadlift, &a.P75Deadlift, &a.P90Deadlift,
+			&a.MedianTotal, &a.P10Total, &a.P25Total, &a.P75Total, &a.P90Total,
+		); err != nil {
+			return err
+		}
+		rows = append(rows, a)
+		return nil
+	})
+	return rows, err
+}
+
+// GetPerformanceTrends retrieves the average, median and percentile lifts
+// per year. It honours the Sex, Equipment and year range filters.
+func GetPerformanceTrends(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]PerformanceTrend, error) {
+	where, args := filter.where(true, true, false)
+	query := `SELECT Year, Sex, Equipment, AvgSquat, AvgBench, AvgDeadlift, AvgTotal, ` + liftDistributionColumns + `
+		FROM performance_trends` + where + ` ORDER BY Year, Sex, Equipment`
+
+	rows := []PerformanceTrend{}
+	err := queryAggregate(ctx, db, "querying performance trends", query, args, func(r *sql.Rows) error {
+		var p PerformanceTrend
+		if err := r.Scan(&p.Year, &p.Sex, &p.Equipment, &p.AvgSquat, &p.AvgBench, &p.AvgDeadlift, &p.AvgTotal,
+			&p.MedianSquat, &p.P10Squat, &p.P25Squat, &p.P75Squat, &p.P90Squat,
+			&p.MedianBench, &p.P10Bench, &p.P25Bench, &p.P75Bench, &p.P90Bench,
+			&p.MedianDeadlift, &p.P10Deadlift, &p.P25Deadlift, &p.P75Deadlift, &p.P90Deadlift,
+			&p.MedianTotal, &p.P10Total, &p.P25Total, &p.P75Total, &p.P90Total,
+		); err != nil {
+			return err
+		}
+		rows = append(rows, p)
+		return nil
+	})
+	return rows, err
+}
+
+// lifterPage adds the page of lifters of f to the WHERE clause and
+// arguments of the other filters on the per-lifter table.
+func (f AggregateFilter) lifterPage(table, where string, args []interface{}) (string, []interface{}) {
+	if f.Limit <= 0 {
+		return where, args
+	}
+	cond := "Name IN (SELECT DISTINCT Name FROM " + table + where + " ORDER BY Name LIMIT ? OFFSET ?)"
+	pageArgs := append(append(append([]interface{}{}, args...), args...), f.Limit, f.Offset)
+	if where == "" {
+		return " WHERE " + cond, pageArgs
+	}
+	return where + " AND " + cond, pageArgs
+}
+
+// GetAggregatedMetricsSBD retrieves per-lifter averages over full power meets.
+// It honours the Name and Equipment filters and pages by lifter.
+func GetAggregatedMetricsSBD(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]LifterAggregateSBD, error) {
+	where, args := filter.where(false, false, true)
+	where, args = filter.lifterPage("aggregated_metrics_sbd", where, args)
+	query := `SELECT Name, Equipment,
+			AvgSuccessfulSquatAttempts, AvgSuccessfulBenchAttempts, AvgSuccessfulDeadliftAttempts,
+			AvgTotalSuccessfulAttempts,
+			AvgSquat1Perc, AvgSquat2Perc, AvgSquat3Perc,
+			AvgBench1Perc, AvgBench2Perc, AvgBench3Perc,
+			AvgDeadlift1Perc, AvgDeadlift2Perc, AvgDeadlift3Perc,
+			AvgSquat1To2Kg, AvgSquat2To3Kg,
+			AvgBench1To2Kg, AvgBench2To3Kg,
+			AvgDeadlift1To2Kg, AvgDeadlift2To3Kg
+		FROM aggregated_metrics_sbd` + where + ` ORDER BY Name, Equipment`
+
+	rows := []LifterAggregateSBD{}
+	err := queryAggregate(ctx, db, "querying aggregated SBD metrics", query, args, func(r *sql.Rows) error {
+		var a LifterAggregateSBD
+		if err := r.Scan(&a.Name, &a.Equipment,
+			&a.AvgSuccessfulSquatAttempts, &a.AvgSuccessfulBenchAttempts, &a.AvgSuccessfulDeadliftAttempts,
+			&a.AvgTotalSuccessfulAttempts,
+			&a.AvgSquat1Perc, &a.AvgSquat2Perc, &a.AvgSquat3Perc,
+			&a.AvgBench1Perc, &a.AvgBench2Perc, &a.AvgBench3Perc,
+			&a.AvgDeadlift1Perc, &a.AvgDeadlift2Perc, &a.AvgDeadlift3Perc,
+			&a.AvgSquat1To2Kg, &a.AvgSquat2To3Kg,
+			&a.AvgBench1To2Kg, &a.AvgBench2To3Kg,
+			&a.AvgDeadlift1To2Kg, &a.AvgDeadlift2To3Kg,
+		); err != nil {
+			return err
+		}
+		rows = append(rows, a)
+		return nil
+	})
+	return rows, err
+}
+
+// GetAggregatedMetricsBench retrieves per-lifter averages over bench-only
+// meets. It honours the Name and Equipment filters and pages by lifter.
+func GetAggregatedMetricsBench(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]LifterAggregateBench, error) {
+	where, args := filter.where(false, false, true)
+	where, args = filter.lifterPage("aggregated_metrics_bench", where, args)
+	query := `SELECT Name, Equipment, AvgSuccessfulBenchAttempts,
+			AvgBench1Perc, AvgBench2Perc, AvgBench3Perc,
+			AvgBench1To2Kg, AvgBench2To3Kg
+		FROM aggregated_metrics_bench` + where + ` ORDER BY Name, Equipment`
+
+	rows := []LifterAggregateBench{}
+	err := queryAggregate(ctx, db, "querying aggregated bench metrics", query, args, func(r *sql.Rows) error {
+		var a LifterAggregateBench
+		if err := r.Scan(&a.Name, &a.Equipment, &a.AvgSuccessfulBenchAttempts,
+			&a.AvgBench1Perc, &a.AvgBench2Perc, &a.AvgBench3Perc,
+			&a.AvgBench1To2Kg, &a.AvgBench2To3Kg,
+		); err != nil {
+			return err
+		}
+		rows = append(rows, a)
+		return nil
+	})
+	return rows, err
+}
@@ -0,0 +1,384 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RecentMeets is how many of the lifter's latest meets the recent best
+	// of a lift is taken from.
+	RecentMeets = 3
+	// PlateIncrementKg is what recommended attempts are rounded to.
+	PlateIncrementKg = 2.5
+	// PriorWeight is how many attempts of the lifter the population success
+	// rate counts as when the two are blended into a make probability.
+	PriorWeight = 10
+	// MinBucketAttempts is how many population attempts a jump bucket needs
+	// for its success rate to be used instead of that of all jumps to the
+	// same attempt.
+	MinBucketAttempts = 20
+)
+
+// Sources of the attempt percentages of a LiftRecommendation.
+const (
+	SourceLifter     = "lifter"
+	SourcePopulation = "population"
+	SourceDefault    = "default"
+)
+
+// defaultOpenerPerc and defaultSecondPerc are the opener and second attempt,
+// as percentages of the third, used when there is no history at all.
+const (
+	defaultOpenerPerc = 91
+	defaultSecondPerc = 96
+)
+
+// AttemptRecommendation suggests the attempts of a lifter's next meet.
+type AttemptRecommendation struct {
+	Name      string `json:"name"`
+	Sex       string `json:"sex"`
+	Equipment string `json:"equipment"`
+	Event     string `json:"event"`
+	// TargetTotalKg is the total asked for, if any, and PlannedTotalKg the
+	// sum of the recommended third attempts.
+	TargetTotalKg  *float64             `json:"targetTotalKg"`
+	PlannedTotalKg float64              `json:"plannedTotalKg"`
+	Lifts          []LiftRecommendation `json:"lifts"`
+}
+
+// LiftRecommendation holds the recommended attempts at one lift and the data
+// they were derived from. The third attempt aims at TopKg, the recent best
+// or its share of the target total, and the opener and second attempt are
+// OpenerPerc and SecondPerc of it.
+type LiftRecommendation struct {
+	Lift         string  `json:"lift"`
+	RecentBestKg float64 `json:"recentBestKg"`
+	TopKg        float64 `json:"topKg"`
+	OpenerPerc   float64 `json:"openerPerc"`
+	SecondPerc   float64 `json:"secondPerc"`
+	// PercSource tells whether the percentages are the lifter's averages,
+	// derived from the population's average jumps or defaults.
+	PercSource string `json:"percSource"`
+	// LifterMeets counts the meets the lifter's averages are taken from.
+	LifterMeets     int                  `json:"lifterMeets"`
+	AvgFirstJumpKg  *float64             `json:"avgFirstJumpKg"`
+	AvgSecondJumpKg *float64             `json:"avgSecondJumpKg"`
+	Attempts        []RecommendedAttempt `json:"attempts"`
+}
+
+// RecommendedAttempt is one recommended attempt. MakeProbability blends the
+// population's success rate for jumps of the same size to the same attempt
+// with the lifter's own record at that attempt, weighing the population as
+// PriorWeight attempts. It is null without either.
+type RecommendedAttempt struct {
+	Attempt          int      `json:"attempt"`
+	Kg               float64  `json:"kg"`
+	JumpKg           *float64 `json:"jumpKg"`
+	JumpPercOfOpener *float64 `json:"jumpPercOfOpener"`
+	// Bucket is the jump bucket the population rate is taken from, or empty
+	// where it is that of all attempts with the number.
+	Bucket                string   `json:"bucket"`
+	MakeProbability       *float64 `json:"makeProbability"`
+	PopulationAttempts    int      `json:"populationAttempts"`
+	PopulationSuccessRate *float64 `json:"populationSuccessRate"`
+	LifterAttempts        int      `json:"lifterAttempts"`
+	LifterMade            int      `json:"lifterMade"`
+}
+
+// liftHistory is the lifter's history at one lift, latest meet first.
+type liftHistory struct {
+	bests                    []float64
+	openerPercs, secondPercs []float64
+	firstJumps, secondJumps  []float64
+}
+
+// GetAttemptRecommendation recommends attempts for the next meet of a
+// lifter in the equipment and event of filter, by default those of the
+// lifter's latest full power or bench only meet. With a positive
+// targetTotalKg, the third attempts are scaled to add up to it, rounded to
+// PlateIncrementKg.
+func GetAttemptRecommendation(ctx context.Context, db *sql.DB, name string, filter EntryFilter, targetTotalKg float64) (*AttemptRecommendation, error) {
+	defer observeQuery("GetAttemptRecommendation", time.Now())
+	rec := &AttemptRecommendation{Name: name}
+	history, err := getLiftHistory(ctx, db, rec, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	filter = EntryFilter{Equipment: rec.Equipment, Event: rec.Event}
+	lifter, err := GetLifterAttemptStrategy(ctx, db, name, filter)
+	if err != nil && !errors.Is(err, ErrNoRows) {
+		return nil, err
+	}
+	population, err := GetAttemptStrategy(ctx, db, AggregateFilter{Sex: rec.Sex, Equipment: rec.Equipment})
+	if err != nil {
+		return nil, err
+	}
+
+	if targetTotalKg > 0 {
+		rec.TargetTotalKg = &targetTotalKg
+	}
+	rec.Lifts = recommendLifts(history, lifter, population, targetTotalKg)
+	if len(rec.Lifts) == 0 {
+		return nil, ErrNoRows
+	}
+	for _, l := range rec.Lifts {
+		rec.PlannedTotalKg += l.Attempts[len(l.Attempts)-1].Kg
+	}
+	return rec, nil
+}
+
+// getLiftHistory reads the lifter's best lifts and attempt metrics in the
+// equipment and event of the latest meet matching filter, which it records
+// in rec together with the lifter's sex.
+func getLiftHistory(ctx context.Context, db *sql.DB, rec *AttemptRecommendation, filter EntryFilter) (map[string]*liftHistory, error) {
+	clause, args := filter.clause("r")
+	var columns []string
+	for _, lift := range Lifts {
+		columns = append(columns, fmt.Sprintf("m.Best3%[1]sKg, lm.%[1]s1Perc, lm.%[1]s2Perc, lm.%[1]s1To2Kg, lm.%[1]s2To3Kg", lift))
+	}
+	query := `
+	SELECT r.Sex, r.Equipment, r.Event, ` + strings.Join(columns, ", ") + `
+	FROM records r
+	JOIN max_lifts m ON m.ID = r.ID
+	LEFT JOIN lifter_metrics lm ON lm.ID = r.ID
+	WHERE r.Name = ?` + clause + `
+	ORDER BY r.Date DESC
+	`
+
+	history := make(map[string]*liftHistory)
+	for _, lift := range Lifts {
+		history[lift] = &liftHistory{}
+	}
+	err := withTimeout(ctx, 5*time.Second, "getting lift history", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{rec.Name}, args...)...)
+		if err != nil {
+			return fmt.Errorf("querying lift history: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var sex, equipment, event string
+			values := make([]sql.NullFloat64, 5*len(Lifts))
+			dest := []interface{}{&sex, &equipment, &event}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return fmt.Errorf("scanning lift history: %w", err)
+			}
+			// Only meets like the latest one count
+			if rec.Event == "" {
+				rec.Sex, rec.Equipment, rec.Event = sex, equipment, event
+			}
+			if equipment != rec.Equipment || event != rec.Event {
+				continue
+			}
+
+			for i, lift := range Lifts {
+				h, v := history[lift], values[5*i:]
+				if v[0].Float64 > 0 {
+					h.bests = append(h.bests, v[0].Float64)
+				}
+				for j, list := range []*[]float64{&h.openerPercs, &h.secondPercs, &h.firstJumps, &h.secondJumps} {
+					if v[j+1].Valid {
+						*list = append(*list, v[j+1].Float64)
+					}
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lift history rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.Event == "" {
+		return nil, ErrNoRows
+	}
+	return history, nil
+}
+
+// recommendLifts plans the attempts at each lift the lifter has a recent
+// best in, in the order of Lifts.
+func recommendLifts(history map[string]*liftHistory, lifter, population []AttemptStrategy, targetTotalKg float64) []LiftRecommendation {
+	var lifts []LiftRecommendation
+	var bestsTotal float64
+	for _, lift := range Lifts {
+		h := history[lift]
+		if len(h.bests) == 0 {
+			continue
+		}
+		l := LiftRecommendation{Lift: lift, LifterMeets: len(h.openerPercs)}
+		for i, best := range h.bests {
+			if i < RecentMeets {
+				l.RecentBestKg = math.Max(l.RecentBestKg, best)
+			}
+		}
+		l.TopKg = l.RecentBestKg
+		bestsTotal += l.RecentBestKg
+		l.AvgFirstJumpKg = average(h.firstJumps)
+		l.AvgSecondJumpKg = average(h.secondJumps)
+
+		pop := findStrategy(population, lift)
+		switch {
+		case len(h.openerPercs) > 0 && len(h.secondPercs) > 0:
+			l.OpenerPerc, l.SecondPerc, l.PercSource = *average(h.openerPercs), *average(h.secondPercs), SourceLifter
+		case pop != nil && jumpPerc(pop, 2) != nil && jumpPerc(pop, 3) != nil:
+			second, third := *jumpPerc(pop, 2), *jumpPerc(pop, 3)
+			l.OpenerPerc = 100 * 100 / (100 + second + third)
+			l.SecondPerc = l.OpenerPerc * (100 + second) / 100
+			l.PercSource = SourcePopulation
+		default:
+			l.OpenerPerc, l.SecondPerc, l.PercSource = defaultOpenerPerc, defaultSecondPerc, SourceDefault
+		}
+		lifts = append(lifts, l)
+	}
+
+	thirds := make([]float64, len(lifts))
+	for i := range lifts {
+		if targetTotalKg > 0 {
+			lifts[i].TopKg = lifts[i].RecentBestKg * targetTotalKg / bestsTotal
+		}
+		thirds[i] = lifts[i].TopKg
+	}
+	if targetTotalKg > 0 {
+		thirds = plateShares(thirds, targetTotalKg)
+	} else {
+		for i := range thirds {
+			thirds[i] = roundToPlates(thirds[i])
+		}
+	}
+
+	for i := range lifts {
+		l := &lifts[i]
+		opener := roundToPlates(l.TopKg * l.OpenerPerc / 100)
+		second := math.Max(opener, roundToPlates(l.TopKg*l.SecondPerc/100))
+		third := math.Max(second, thirds[i])
+		own := findStrategy(lifter, l.Lift)
+		pop := findStrategy(population, l.Lift)
+		previous := opener
+		for n, kg := range []float64{opener, second, third} {
+			a := RecommendedAttempt{Attempt: n + 1, Kg: kg}
+			if n > 0 {
+				jump, perc := kg-previous, (kg-previous)*100/opener
+				a.JumpKg, a.JumpPercOfOpener = &jump, &perc
+			}
+			estimateMake(&a, own, pop)
+			l.Attempts = append(l.Attempts, a)
+			previous = kg
+		}
+	}
+	return lifts
+}
+
+// estimateMake fills in the success rates behind attempt a and its make
+// probability from the strategies of the lifter and the population.
+func estimateMake(a *RecommendedAttempt, lifter, population *AttemptStrategy) {
+	if o := findOutcomes(population, a.Attempt); o != nil {
+		a.PopulationAttempts, a.PopulationSuccessRate = o.Attempts, o.SuccessRate
+		if a.JumpPercOfOpener != nil {
+			bucket := jumpBucket(*a.JumpPercOfOpener)
+			for _, b := range o.ByJumpSize {
+				if b.Bucket == bucket && b.Attempts >= MinBucketAttempts {
+					a.Bucket, a.PopulationAttempts, a.PopulationSuccessRate = bucket, b.Attempts, b.SuccessRate
+				}
+			}
+		}
+	}
+	if o := findOutcomes(lifter, a.Attempt); o != nil {
+		a.LifterAttempts, a.LifterMade = o.Attempts, o.Made
+	}
+
+	made, attempts := float64(a.LifterMade), float64(a.LifterAttempts)
+	if a.PopulationSuccessRate != nil {
+		made += PriorWeight * *a.PopulationSuccessRate
+		attempts += PriorWeight
+	}
+	if attempts > 0 {
+		p := made / attempts
+		a.MakeProbability = &p
+	}
+}
+
+func findStrategy(strategies []AttemptStrategy, lift string) *AttemptStrategy {
+	for i := range strategies {
+		if strategies[i].Lift == lift {
+			return &strategies[i]
+		}
+	}
+	return nil
+}
+
+func findOutcomes(s *AttemptStrategy, attempt int) *AttemptOutcomes {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Attempts {
+		if s.Attempts[i].Attempt == attempt {
+			return &s.Attempts[i]
+		}
+	}
+	return nil
+}
+
+// jumpPerc returns the average jump to attempt as a percentage of the
+// opener, or nil if unknown.
+func jumpPerc(s *AttemptStrategy, attempt int) *float64 {
+	if o := findOutcomes(s, attempt); o != nil {
+		return o.AvgJumpPercOfOpener
+	}
+	return nil
+}
+
+// average returns the mean of values, or nil if there are none.
+func average(values []float64) *float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return ratio(sum, len(values))
+}
+
+// roundToPlates rounds kg to the nearest PlateIncrementKg.
+func roundToPlates(kg float64) float64 {
+	return math.Round(kg/PlateIncrementKg) * PlateIncrementKg
+}
+
+// plateShares rounds each of kgs to PlateIncrementKg so that they add up to
+// total rounded the same way. Rounding each on its own can miss the total by
+// an increment or more; the difference goes to the weights rounding moved
+// furthest in the other direction.
+func plateShares(kgs []float64, total float64) []float64 {
+	rounded := make([]float64, len(kgs))
+	order := make([]int, len(kgs))
+	var sum float64
+	for i, kg := range kgs {
+		rounded[i] = roundToPlates(kg)
+		order[i] = i
+		sum += rounded[i]
+	}
+	// Most rounded down first
+	sort.SliceStable(order, func(a, b int) bool {
+		return kgs[order[a]]-rounded[order[a]] > kgs[order[b]]-rounded[order[b]]
+	})
+
+	steps := int(math.Round((roundToPlates(total) - sum) / PlateIncrementKg))
+	for n := 0; n < steps; n++ {
+		rounded[order[n%len(order)]] += PlateIncrementKg
+	}
+	for n := 0; n < -steps; n++ {
+		rounded[order[len(order)-1-n%len(order)]] -= PlateIncrementKg
+	}
+	return rounded
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestGetAttemptRecommendation(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{
+			Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Date: "2023-05-01",
+			Squat1Kg: 100, Squat2Kg: 105, Squat3Kg: -110, Best3SquatKg: 105,
+			Bench1Kg: 60, Bench2Kg: 62.5, Bench3Kg: 65, Best3BenchKg: 65,
+		},
+		{
+			Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Date: "2024-03-01",
+			Squat1Kg: 100, Squat2Kg: -110, Squat3Kg: 110, Best3SquatKg: 110,
+			Bench1Kg: 60, Bench2Kg: -65, Bench3Kg: -65, Best3BenchKg: 60,
+		},
+	})
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := GetAttemptRecommendation(ctx, database, "Anna Berg", EntryFilter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Sex != "F" || rec.Equipment != "Raw" || rec.Event != "SBD" || len(rec.Lifts) != 2 || rec.TargetTotalKg != nil {
+		t.Fatalf("GetAttemptRecommendation = %+v", rec)
+	}
+	squat := rec.Lifts[0]
+	if squat.Lift != "Squat" || squat.RecentBestKg != 110 || squat.PercSource != SourceLifter || squat.LifterMeets != 2 {
+		t.Errorf("squat %+v", squat)
+	}
+	if got := attemptKgs(squat); got != [3]float64{100, 107.5, 110} {
+		t.Errorf("squat attempts %v", got)
+	}
+	if p := squat.Attempts[0].MakeProbability; p == nil || squat.Attempts[0].LifterAttempts != 2 {
+		t.Errorf("squat opener %+v", squat.Attempts[0])
+	}
+	if rec.PlannedTotalKg != 175 {
+		t.Errorf("planned total %v, want 175", rec.PlannedTotalKg)
+	}
+
+	rec, err = GetAttemptRecommendation(ctx, database, "Anna Berg", EntryFilter{}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.PlannedTotalKg != 200 || math.Abs(rec.Lifts[0].TopKg-110*200.0/175) > 1e-9 {
+		t.Errorf("with target: planned total %v, squat top %v", rec.PlannedTotalKg, rec.Lifts[0].TopKg)
+	}
+
+	if _, err := GetAttemptRecommendation(ctx, database, "Anna Berg", EntryFilter{Equipment: "Wraps"}, 0); !errors.Is(err, ErrNoRows) {
+		t.Errorf("no meets in equipment: %v, want ErrNoRows", err)
+	}
+}
+
+// TestRecommendLifts tests percentages derived from the population's jumps
+// and make probabilities from jump buckets and the lifter's record.
+func TestRecommendLifts(t *testing.T) {
+	rate := func(x float64) *float64 { return &x }
+	history := map[string]*liftHistory{
+		"Squat": {bests: []float64{200, 190}}, "Bench": {}, "Deadlift": {},
+	}
+	population := []AttemptStrategy{{
+		Lift: "Squat",
+		Attempts: []AttemptOutcomes{
+			{Attempt: 1, Attempts: 100, SuccessRate: rate(0.9)},
+			{Attempt: 2, Attempts: 100, SuccessRate: rate(0.7), AvgJumpPercOfOpener: rate(5),
+				ByJumpSize: []JumpSizeOutcomes{{Bucket: "5-7.5%", Attempts: 30, SuccessRate: rate(0.8)}}},
+			{Attempt: 3, Attempts: 100, SuccessRate: rate(0.6), AvgJumpPercOfOpener: rate(2.5),
+				ByJumpSize: []JumpSizeOutcomes{{Bucket: "2.5-5%", Attempts: 5, SuccessRate: rate(1)}}},
+		},
+	}}
+	lifter := []AttemptStrategy{{Lift: "Squat", Attempts: []AttemptOutcomes{{Attempt: 3, Attempts: 2}}}}
+
+	lifts := recommendLifts(history, lifter, population, 0)
+	if len(lifts) != 1 || lifts[0].PercSource != SourcePopulation || math.Abs(lifts[0].OpenerPerc-100/1.075) > 1e-9 {
+		t.Fatalf("recommendLifts = %+v", lifts)
+	}
+	if got := attemptKgs(lifts[0]); got != [3]float64{185, 195, 200} {
+		t.Errorf("attempts %v", got)
+	}
+
+	second, third := lifts[0].Attempts[1], lifts[0].Attempts[2]
+	if second.Bucket != "5-7.5%" || second.PopulationAttempts != 30 || *second.MakeProbability != 0.8 {
+		t.Errorf("second attempt %+v", second)
+	}
+	// Too few attempts in the bucket, so the rate of all third attempts
+	// counts, blended with two misses of the lifter
+	if third.Bucket != "" || third.LifterAttempts != 2 || *third.MakeProbability != 0.5 {
+		t.Errorf("third attempt %+v", third)
+	}
+
+	lifts = recommendLifts(history, nil, nil, 0)
+	if lifts[0].PercSource != SourceDefault || lifts[0].Attempts[0].MakeProbability != nil {
+		t.Errorf("without any history %+v", lifts[0])
+	}
+}
+
+// TestPlateShares tests that rounded shares add up to the rounded total.
+func TestPlateShares(t *testing.T) {
+	for _, tt := range []struct {
+		kgs   []float64
+		total float64
+		want  []float64
+	}{
+		// 102.5 each would make 307.5
+		{[]float64{310.0 / 3, 310.0 / 3, 310.0 / 3}, 310, []float64{105, 102.5, 102.5}},
+		// 103.75 each rounds up to 105, making 315
+		{[]float64{103.75, 103.75, 103.75, 103.75}, 415, []float64{105, 105, 102.5, 102.5}},
+		{[]float64{126, 74}, 200, []float64{125, 75}},
+		{[]float64{180, 120.9, 200.1}, 501, []float64{180, 120, 200}},
+	} {
+		if got := plateShares(tt.kgs, tt.total); !slices.Equal(got, tt.want) {
+			t.Errorf("plateShares(%v, %v) = %v, want %v", tt.kgs, tt.total, got, tt.want)
+		}
+	}
+}
+
+func attemptKgs(l LiftRecommendation) (kgs [3]float64) {
+	for i, a := range l.Attempts {
+		kgs[i] = a.Kg
+	}
+	return kgs
+}
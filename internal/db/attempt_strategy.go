@@ -0,0 +1,360 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JumpBuckets are the jump sizes, as a percentage of the opener, that
+// success rates are broken down by. Each bucket includes its upper bound;
+// jumps down are in "drop" and those above the last bound in ">10%".
+var JumpBuckets = []struct {
+	Label string
+	Max   float64
+}{
+	{"0%", 0}, {"0-2.5%", 2.5}, {"2.5-5%", 5}, {"5-7.5%", 7.5}, {"7.5-10%", 10},
+}
+
+// jumpBucket returns the label of the bucket of JumpBuckets holding a jump
+// of perc percent of the opener.
+func jumpBucket(perc float64) string {
+	if perc < 0 {
+		return "drop"
+	}
+	for _, b := range JumpBuckets {
+		if perc <= b.Max {
+			return b.Label
+		}
+	}
+	return ">10%"
+}
+
+// AttemptStrategy describes how attempts at one lift were chosen and how
+// the choices turned out. Rates are fractions between 0 and 1 and, like
+// averages, null when there is nothing to compute them from.
+type AttemptStrategy struct {
+	Lift      string            `json:"lift"`
+	Attempts  []AttemptOutcomes `json:"attempts"`
+	AfterMiss MissFollowUp      `json:"afterMiss"`
+	Openers   OpenerStats       `json:"openers"`
+}
+
+// AttemptOutcomes are the outcomes of the attempts with one number.
+type AttemptOutcomes struct {
+	Attempt     int      `json:"attempt"`
+	Attempts    int      `json:"attempts"`
+	Made        int      `json:"made"`
+	SuccessRate *float64 `json:"successRate"`
+	// Jumps counts the attempts following the attempt numbered before
+	// them. Their average size is given as a percentage of the opener and
+	// of the best successful lift of the meet.
+	Jumps               int      `json:"jumps"`
+	AvgJumpPercOfOpener *float64 `json:"avgJumpPercOfOpener"`
+	AvgJumpPercOfFinal  *float64 `json:"avgJumpPercOfFinal"`
+	// ByJumpSize breaks the jumps down by their size in JumpBuckets.
+	ByJumpSize []JumpSizeOutcomes `json:"byJumpSize"`
+}
+
+// JumpSizeOutcomes are the outcomes of the jumps in one of JumpBuckets.
+type JumpSizeOutcomes struct {
+	Bucket      string   `json:"bucket"`
+	Attempts    int      `json:"attempts"`
+	Made        int      `json:"made"`
+	SuccessRate *float64 `json:"successRate"`
+}
+
+// MissFollowUp describes the attempts following a missed attempt: retries
+// of the same weight and jumps to a heavier one.
+type MissFollowUp struct {
+	Misses           int      `json:"misses"`
+	Retries          int      `json:"retries"`
+	RetriesMade      int      `json:"retriesMade"`
+	Jumps            int      `json:"jumps"`
+	JumpsMade        int      `json:"jumpsMade"`
+	RetryRate        *float64 `json:"retryRate"`
+	RetrySuccessRate *float64 `json:"retrySuccessRate"`
+	JumpSuccessRate  *float64 `json:"jumpSuccessRate"`
+}
+
+// OpenerStats compares openers with the best successful lift of the
+// lifter's previous meet in the same equipment.
+type OpenerStats struct {
+	Entries                  int      `json:"entries"`
+	AvgBelowPreviousBestKg   *float64 `json:"avgBelowPreviousBestKg"`
+	AvgBelowPreviousBestPerc *float64 `json:"avgBelowPreviousBestPerc"`
+}
+
+// strategyCounts are the additive counts and sums that AttemptStrategy is
+// computed from, for one lift, attempt number and jump bucket.
+type strategyCounts struct {
+	Lift    string
+	Attempt int
+	Bucket  string
+
+	Attempts, Made                          int
+	Jumps, JumpsWithFinal                   int
+	JumpPercOfOpenerSum, JumpPercOfFinalSum float64
+	AfterMiss, Retries, RetriesMade         int
+	MissJumps, MissJumpsMade                int
+	Openers                                 int
+	OpenerBelowKgSum, OpenerBelowPercSum    float64
+}
+
+// strategyCountColumns are the columns of attempt_strategy holding
+// strategyCounts, after Lift, Attempt and Bucket.
+const strategyCountColumns = `Attempts, Made, Jumps, JumpsWithFinal, JumpPercOfOpenerSum, JumpPercOfFinalSum,
+	AfterMiss, Retries, RetriesMade, MissJumps, MissJumpsMade, Openers, OpenerBelowKgSum, OpenerBelowPercSum`
+
+// strategyCountsQuery returns the query computing strategyCounts from the
+// attempts of the records matching scope, grouped by groupBy and then by
+// lift, attempt number and bucket. Previous meets are looked up among the
+// records in scope, and filter, which may use the alias e for records,
+// then restricts the entries counted.
+func strategyCountsQuery(scope, filter string, groupBy ...string) string {
+	var bests []string
+	for _, lift := range Lifts {
+		column := "Best3" + lift + "Kg"
+		bests = append(bests, fmt.Sprintf(`SELECT ID AS EntryID, '%[1]s' AS Lift, %[2]s AS FinalKg,
+				LAG(%[2]s) OVER (PARTITION BY Name, Equipment ORDER BY Date) AS PreviousBestKg
+			FROM records WHERE %[2]s > 0 AND %[3]s`, lift, column, scope))
+	}
+
+	bucket := "CASE WHEN JumpPercOfOpener IS NULL THEN '' WHEN JumpPercOfOpener < 0 THEN 'drop'"
+	for _, b := range JumpBuckets {
+		bucket += fmt.Sprintf(" WHEN JumpPercOfOpener <= %g THEN '%s'", b.Max, b.Label)
+	}
+	bucket += " ELSE '>10%' END"
+
+	group := strings.Join(append(groupBy, "Lift", "Attempt", "Bucket"), ", ")
+	return `
+		WITH bests AS (
+			` + strings.Join(bests, "\n\t\t\tUNION ALL\n\t\t\t") + `
+		), facts AS (
+			SELECT
+				CAST(strftime('%Y', e.Date) AS INTEGER) AS Year, e.Sex, e.Equipment,
+				a.Lift, a.Attempt, a.Outcome = '` + AttemptMade + `' AS Made, a.JumpKg, a.Retry,
+				COALESCE(LAG(a.Outcome) OVER w = '` + AttemptMissed + `' AND a.JumpKg IS NOT NULL, 0) AS AfterMiss,
+				a.JumpKg * 100 / MAX(CASE WHEN a.Attempt = 1 THEN a.Kg END) OVER (PARTITION BY a.EntryID, a.Lift) AS JumpPercOfOpener,
+				a.JumpKg * 100 / b.FinalKg AS JumpPercOfFinal,
+				CASE WHEN a.Attempt = 1 THEN b.PreviousBestKg - a.Kg END AS OpenerBelowKg,
+				CASE WHEN a.Attempt = 1 THEN (b.PreviousBestKg - a.Kg) * 100 / b.PreviousBestKg END AS OpenerBelowPerc
+			FROM records e
+			JOIN attempts a ON a.EntryID = e.ID
+			LEFT JOIN bests b ON b.EntryID = a.EntryID AND b.Lift = a.Lift
+			WHERE ` + scope + filter + `
+			WINDOW w AS (PARTITION BY a.EntryID, a.Lift ORDER BY a.Attempt)
+		)
+		SELECT ` + group + `,
+			COUNT(*), SUM(Made),
+			COUNT(JumpPercOfOpener), COUNT(JumpPercOfFinal),
+			COALESCE(SUM(JumpPercOfOpener), 0), COALESCE(SUM(JumpPercOfFinal), 0),
+			SUM(AfterMiss), SUM(AfterMiss AND Retry), SUM(AfterMiss AND Retry AND Made),
+			SUM(AfterMiss AND JumpKg > 0), SUM(AfterMiss AND JumpKg > 0 AND Made),
+			COUNT(OpenerBelowKg), COALESCE(SUM(OpenerBelowKg), 0), COALESCE(SUM(OpenerBelowPerc), 0)
+		FROM (SELECT *, ` + bucket + ` AS Bucket FROM facts)
+		GROUP BY ` + group
+}
+
+// scan reads the columns of strategyCountColumns after Lift, Attempt and
+// Bucket.
+func (c *strategyCounts) scan(rows *sql.Rows) error {
+	return rows.Scan(&c.Lift, &c.Attempt, &c.Bucket,
+		&c.Attempts, &c.Made, &c.Jumps, &c.JumpsWithFinal, &c.JumpPercOfOpenerSum, &c.JumpPercOfFinalSum,
+		&c.AfterMiss, &c.Retries, &c.RetriesMade, &c.MissJumps, &c.MissJumpsMade,
+		&c.Openers, &c.OpenerBelowKgSum, &c.OpenerBelowPercSum)
+}
+
+// AttemptStrategyCounts precomputes the attempt strategy of every year, sex
+// and equipment for GetAttemptStrategy.
+type AttemptStrategyCounts struct{}
+
+func (a *AttemptStrategyCounts) Name() string { return "attempt_strategy" }
+
+func (a *AttemptStrategyCounts) Inputs() []string { return []string{"records", "attempts"} }
+
+func (a *AttemptStrategyCounts) Outputs() []string { return []string{"attempt_strategy"} }
+
+func (a *AttemptStrategyCounts) Calculate(ctx context.Context, tx *sql.Tx) error {
+	query := `INSERT INTO attempt_strategy (Year, Sex, Equipment, Lift, Attempt, Bucket, ` + strategyCountColumns + `)` +
+		strategyCountsQuery("1", "", "Year", "Sex", "Equipment")
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM attempt_strategy`); err != nil {
+		return fmt.Errorf("clearing attempt strategy: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("calculating attempt strategy: %w", err)
+	}
+	return nil
+}
+
+// GetAttemptStrategy retrieves the attempt strategy of the lifters matching
+// the Sex, Equipment and year range filters.
+func GetAttemptStrategy(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]AttemptStrategy, error) {
+	where, args := filter.where(true, true, false)
+	var sums []string
+	for _, column := range strings.Split(strategyCountColumns, ",") {
+		sums = append(sums, "SUM("+strings.TrimSpace(column)+")")
+	}
+	query := `SELECT Lift, Attempt, Bucket, ` + strings.Join(sums, ", ") + `
+		FROM attempt_strategy` + where + ` GROUP BY Lift, Attempt, Bucket`
+
+	var counts []strategyCounts
+	err := queryAggregate(ctx, db, "querying attempt strategy", query, args, func(r *sql.Rows) error {
+		var c strategyCounts
+		if err := c.scan(r); err != nil {
+			return err
+		}
+		counts = append(counts, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildAttemptStrategy(counts), nil
+}
+
+// GetLifterAttemptStrategy computes the attempt strategy of a lifter over
+// the entries matching filter.
+func GetLifterAttemptStrategy(ctx context.Context, db *sql.DB, name string, filter EntryFilter) ([]AttemptStrategy, error) {
+	defer observeQuery("GetLifterAttemptStrategy", time.Now())
+	clause, filterArgs := filter.clause("e")
+	// The scope appears once in each part of bests and once in facts
+	query := strategyCountsQuery("Name = ?", clause)
+	var args []interface{}
+	for range Lifts {
+		args = append(args, name)
+	}
+	args = append(append(args, name), filterArgs...)
+
+	var counts []strategyCounts
+	err := withTimeout(ctx, 5*time.Second, "getting lifter attempt strategy", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("querying lifter attempt strategy: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c strategyCounts
+			if err := c.scan(rows); err != nil {
+				return fmt.Errorf("scanning lifter attempt strategy: %w", err)
+			}
+			counts = append(counts, c)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lifter attempt strategy rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(counts) == 0 {
+		return nil, ErrNoRows
+	}
+	return buildAttemptStrategy(counts), nil
+}
+
+// buildAttemptStrategy folds counts into one AttemptStrategy per lift, in
+// the order of Lifts.
+func buildAttemptStrategy(counts []strategyCounts) []AttemptStrategy {
+	type lift struct {
+		strategy AttemptStrategy
+		attempts map[int]*AttemptOutcomes
+		// sums of the jump percentages per attempt, and of the openers
+		jumpSums                map[int][2]float64
+		withFinal               map[int]int
+		belowKgSum, belowPctSum float64
+	}
+	lifts := make(map[string]*lift)
+	for _, c := range counts {
+		l := lifts[c.Lift]
+		if l == nil {
+			l = &lift{
+				strategy:  AttemptStrategy{Lift: c.Lift},
+				attempts:  make(map[int]*AttemptOutcomes),
+				jumpSums:  make(map[int][2]float64),
+				withFinal: make(map[int]int),
+			}
+			lifts[c.Lift] = l
+		}
+		a := l.attempts[c.Attempt]
+		if a == nil {
+			a = &AttemptOutcomes{Attempt: c.Attempt, ByJumpSize: []JumpSizeOutcomes{}}
+			l.attempts[c.Attempt] = a
+		}
+
+		a.Attempts += c.Attempts
+		a.Made += c.Made
+		a.Jumps += c.Jumps
+		sums := l.jumpSums[c.Attempt]
+		l.jumpSums[c.Attempt] = [2]float64{sums[0] + c.JumpPercOfOpenerSum, sums[1] + c.JumpPercOfFinalSum}
+		l.withFinal[c.Attempt] += c.JumpsWithFinal
+		if c.Bucket != "" {
+			a.ByJumpSize = append(a.ByJumpSize, JumpSizeOutcomes{
+				Bucket: c.Bucket, Attempts: c.Attempts, Made: c.Made, SuccessRate: ratio(float64(c.Made), c.Attempts),
+			})
+		}
+
+		m := &l.strategy.AfterMiss
+		m.Misses += c.AfterMiss
+		m.Retries += c.Retries
+		m.RetriesMade += c.RetriesMade
+		m.Jumps += c.MissJumps
+		m.JumpsMade += c.MissJumpsMade
+		l.strategy.Openers.Entries += c.Openers
+		l.belowKgSum += c.OpenerBelowKgSum
+		l.belowPctSum += c.OpenerBelowPercSum
+	}
+
+	strategies := []AttemptStrategy{}
+	for _, name := range Lifts {
+		l := lifts[name]
+		if l == nil {
+			continue
+		}
+		s := l.strategy
+		for attempt := 1; attempt <= 4; attempt++ {
+			a := l.attempts[attempt]
+			if a == nil {
+				continue
+			}
+			a.SuccessRate = ratio(float64(a.Made), a.Attempts)
+			a.AvgJumpPercOfOpener = ratio(l.jumpSums[attempt][0], a.Jumps)
+			a.AvgJumpPercOfFinal = ratio(l.jumpSums[attempt][1], l.withFinal[attempt])
+			sortJumpSizes(a.ByJumpSize)
+			s.Attempts = append(s.Attempts, *a)
+		}
+		s.AfterMiss.RetryRate = ratio(float64(s.AfterMiss.Retries), s.AfterMiss.Misses)
+		s.AfterMiss.RetrySuccessRate = ratio(float64(s.AfterMiss.RetriesMade), s.AfterMiss.Retries)
+		s.AfterMiss.JumpSuccessRate = ratio(float64(s.AfterMiss.JumpsMade), s.AfterMiss.Jumps)
+		s.Openers.AvgBelowPreviousBestKg = ratio(l.belowKgSum, s.Openers.Entries)
+		s.Openers.AvgBelowPreviousBestPerc = ratio(l.belowPctSum, s.Openers.Entries)
+		strategies = append(strategies, s)
+	}
+	return strategies
+}
+
+// sortJumpSizes orders outcomes like JumpBuckets, with drops first and the
+// largest jumps last.
+func sortJumpSizes(outcomes []JumpSizeOutcomes) {
+	rank := map[string]int{"drop": -1, ">10%": len(JumpBuckets)}
+	for i, b := range JumpBuckets {
+		rank[b.Label] = i
+	}
+	sort.Slice(outcomes, func(i, j int) bool { return rank[outcomes[i].Bucket] < rank[outcomes[j].Bucket] })
+}
+
+// ratio returns sum/n, or nil if n is 0.
+func ratio(sum float64, n int) *float64 {
+	if n == 0 {
+		return nil
+	}
+	r := sum / float64(n)
+	return &r
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestAttemptStrategy tests the attempt strategy of a lifter over two meets,
+// and that the population figures precomputed for the same entries agree.
+func TestAttemptStrategy(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{
+			Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Date: "2023-05-01",
+			Squat1Kg: 100, Squat2Kg: 105, Squat3Kg: -110, Best3SquatKg: 105,
+		},
+		{
+			Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Date: "2024-03-01",
+			Squat1Kg: 100, Squat2Kg: -110, Squat3Kg: 110, Squat4Kg: 112.5, Best3SquatKg: 110,
+		},
+		{
+			Name: "Ole Dahl", Sex: "M", Event: "SBD", Equipment: "Raw", Date: "2024-03-01",
+			Squat1Kg: 200, Squat2Kg: -220, Squat3Kg: -220, Best3SquatKg: 200,
+		},
+	})
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetLifterAttemptStrategy(ctx, database, "Anna Berg", EntryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Lift != "Squat" || len(got[0].Attempts) != 4 {
+		t.Fatalf("GetLifterAttemptStrategy = %+v", got)
+	}
+	squat := got[0]
+
+	near := func(name string, got *float64, want float64) {
+		t.Helper()
+		if got == nil || math.Abs(*got-want) > 1e-4 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	second := squat.Attempts[1]
+	if second.Attempts != 2 || second.Made != 1 || second.Jumps != 2 {
+		t.Errorf("second attempts %+v", second)
+	}
+	near("second success rate", second.SuccessRate, 0.5)
+	near("second jump % of opener", second.AvgJumpPercOfOpener, 7.5)
+	near("second jump % of final", second.AvgJumpPercOfFinal, (500.0/105+1000.0/110)/2)
+	rate := func(x float64) *float64 { return &x }
+	wantBuckets := []JumpSizeOutcomes{{"2.5-5%", 1, 1, rate(1)}, {"7.5-10%", 1, 0, rate(0)}}
+	if !reflect.DeepEqual(second.ByJumpSize, wantBuckets) {
+		t.Errorf("second attempts by jump size %+v", second.ByJumpSize)
+	}
+	if first := squat.Attempts[0]; first.AvgJumpPercOfOpener != nil || len(first.ByJumpSize) != 0 {
+		t.Errorf("openers have jumps: %+v", first)
+	}
+	if third := squat.Attempts[2].ByJumpSize; len(third) != 2 || third[0].Bucket != "0%" {
+		t.Errorf("third attempts by jump size %+v", third)
+	}
+
+	miss := squat.AfterMiss
+	if miss.Misses != 1 || miss.Retries != 1 || miss.RetriesMade != 1 || miss.Jumps != 0 || miss.JumpSuccessRate != nil {
+		t.Errorf("after miss %+v", miss)
+	}
+	if squat.Openers.Entries != 1 {
+		t.Errorf("openers %+v", squat.Openers)
+	}
+	near("opener below previous best", squat.Openers.AvgBelowPreviousBestKg, 5)
+	near("opener below previous best %", squat.Openers.AvgBelowPreviousBestPerc, 500.0/105)
+
+	population, err := GetAttemptStrategy(ctx, database, AggregateFilter{Sex: "F"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(population, got) {
+		t.Errorf("GetAttemptStrategy = %+v, want %+v", population, got)
+	}
+	population, err = GetAttemptStrategy(ctx, database, AggregateFilter{Sex: "M"})
+	if err != nil || len(population) != 1 || population[0].AfterMiss.Retries != 1 || population[0].AfterMiss.RetriesMade != 0 {
+		t.Errorf("GetAttemptStrategy(M) = %+v, %v", population, err)
+	}
+
+	if _, err := GetLifterAttemptStrategy(ctx, database, "Anna Berg", EntryFilter{Equipment: "Wraps"}); !errors.Is(err, ErrNoRows) {
+		t.Errorf("filtered out entries: %v, want ErrNoRows", err)
+	}
+}
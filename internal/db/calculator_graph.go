@@ -0,0 +1,413 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"liftmetrics/internal/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of a CalculatorRun.
+const (
+	RunOK      = "ok"
+	RunFailed  = "failed"
+	RunSkipped = "skipped"
+)
+
+// CalculatorRun records one run of a calculator.
+type CalculatorRun struct {
+	Name      string        `json:"name"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	// Status is ok, failed, or skipped when a calculator it depends on
+	// failed.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+var calculatorDuration = metrics.Default.NewGauge("liftmetrics_calculator_duration_seconds",
+	"Duration of the last run of each metric calculator.", "calculator")
+
+// calculatorGraph orders calculators by the tables they read and write.
+//
+// For every table, the calculators using it are ranked: first those that
+// write it without reading it, then those that update it (read and write),
+// then those that only read it, and within each rank by registration order.
+// A calculator depends on every calculator ranked before it on a shared
+// table, unless both only read it. Each table thus gets its final contents
+// before it is read, and its writers never run at the same time.
+type calculatorGraph struct {
+	calculators []MetricCalculator
+	// deps[i] and dependents[i] are the direct dependencies and dependents
+	// of calculators[i].
+	deps       [][]int
+	dependents [][]int
+	// order is a topological order that prefers registration order.
+	order []int
+}
+
+// Ranks of a calculator on a table.
+const (
+	rankWrites = iota
+	rankUpdates
+	rankReads
+)
+
+func newCalculatorGraph(calculators []MetricCalculator) (*calculatorGraph, error) {
+	g := &calculatorGraph{
+		calculators: calculators,
+		deps:        make([][]int, len(calculators)),
+		dependents:  make([][]int, len(calculators)),
+	}
+
+	type access struct{ calc, rank int }
+	tables := make(map[string][]access)
+	names := make(map[string]bool)
+	for i, c := range calculators {
+		if names[c.Name()] {
+			return nil, fmt.Errorf("calculator %s registered twice", c.Name())
+		}
+		names[c.Name()] = true
+
+		inputs := make(map[string]bool)
+		for _, t := range c.Inputs() {
+			inputs[t] = true
+		}
+		outputs := make(map[string]bool)
+		for _, t := range c.Outputs() {
+			outputs[t] = true
+			rank := rankWrites
+			if inputs[t] {
+				rank = rankUpdates
+			}
+			tables[t] = append(tables[t], access{i, rank})
+		}
+		for t := range inputs {
+			if !outputs[t] {
+				tables[t] = append(tables[t], access{i, rankReads})
+			}
+		}
+	}
+
+	edges := make(map[[2]int]bool)
+	for _, accesses := range tables {
+		sort.Slice(accesses, func(a, b int) bool {
+			if accesses[a].rank != accesses[b].rank {
+				return accesses[a].rank < accesses[b].rank
+			}
+			return accesses[a].calc < accesses[b].calc
+		})
+		for b := range accesses {
+			for a := 0; a < b; a++ {
+				if accesses[b].rank == rankReads && accesses[a].rank == rankReads {
+					continue
+				}
+				from, to := accesses[a].calc, accesses[b].calc
+				if !edges[[2]int{from, to}] {
+					edges[[2]int{from, to}] = true
+					g.deps[to] = append(g.deps[to], from)
+					g.dependents[from] = append(g.dependents[from], to)
+				}
+			}
+		}
+	}
+
+	// Kahn's algorithm, taking the first ready calculator each time
+	pending := make([]int, len(calculators))
+	for i := range calculators {
+		pending[i] = len(g.deps[i])
+	}
+	done := make([]bool, len(calculators))
+	for len(g.order) < len(calculators) {
+		next := -1
+		for i := range calculators {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, c := range calculators {
+				if !done[i] {
+					cycle = append(cycle, c.Name())
+				}
+			}
+			return nil, fmt.Errorf("calculators depend on each other: %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		g.order = append(g.order, next)
+		for _, d := range g.dependents[next] {
+			pending[d]--
+		}
+	}
+	return g, nil
+}
+
+// withDependents returns the named calculator and everything depending on
+// it, in run order.
+func (g *calculatorGraph) withDependents(name string) ([]int, error) {
+	start := -1
+	for i, c := range g.calculators {
+		if c.Name() == name {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no calculator named %q", name)
+	}
+
+	selected := make([]bool, len(g.calculators))
+	stack := []int{start}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if selected[i] {
+			continue
+		}
+		selected[i] = true
+		stack = append(stack, g.dependents[i]...)
+	}
+
+	var subset []int
+	for _, i := range g.order {
+		if selected[i] {
+			subset = append(subset, i)
+		}
+	}
+	return subset, nil
+}
+
+// run runs the given calculators, which are in run order, records their
+// runs and returns the errors of those that failed.
+func (fc *FeatureCalculator) run(ctx context.Context, db *sql.DB, g *calculatorGraph, calcs []int) error {
+	fc.mu.Lock()
+	fc.runs = nil
+	fc.mu.Unlock()
+
+	var err error
+	if fc.Parallelism <= 1 {
+		err = fc.runInTransaction(ctx, db, g, calcs)
+	} else {
+		err = fc.runConcurrently(ctx, db, g, calcs)
+	}
+
+	// The record of the runs outlives a rolled back transaction
+	if recordErr := recordCalculatorRuns(context.WithoutCancel(ctx), db, fc.Runs()); recordErr != nil {
+		err = errors.Join(err, recordErr)
+	}
+	return err
+}
+
+// runInTransaction runs the calculators one after the other in a single
+// transaction. The first failure rolls everything back.
+func (fc *FeatureCalculator) runInTransaction(ctx context.Context, db *sql.DB, g *calculatorGraph, calcs []int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for n, i := range calcs {
+		if err := fc.calculate(ctx, tx, g.calculators[i]); err != nil {
+			for _, j := range calcs[n+1:] {
+				fc.record(CalculatorRun{Name: g.calculators[j].Name(), Status: RunSkipped})
+			}
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// runConcurrently runs up to fc.Parallelism calculators at a time, each in
+// its own transaction, starting each once its dependencies are committed.
+// Calculators depending on a failed one are skipped; the others still run.
+func (fc *FeatureCalculator) runConcurrently(ctx context.Context, db *sql.DB, g *calculatorGraph, calcs []int) error {
+	selected := make(map[int]bool, len(calcs))
+	for _, i := range calcs {
+		selected[i] = true
+	}
+	pending := make(map[int]int, len(calcs))
+	for _, i := range calcs {
+		for _, d := range g.deps[i] {
+			if selected[d] {
+				pending[i]++
+			}
+		}
+	}
+
+	type result struct {
+		calc int
+		err  error
+	}
+	results := make(chan result)
+	failed := make(map[int]bool)
+	var errs []error
+	var ready []int
+	for _, i := range calcs {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	running, finished := 0, 0
+	for finished < len(calcs) {
+		for len(ready) > 0 && running < fc.Parallelism {
+			i := ready[0]
+			ready = ready[1:]
+			if failed[i] {
+				// A dependency failed: skip it without running it
+				fc.record(CalculatorRun{Name: g.calculators[i].Name(), Status: RunSkipped})
+				finished++
+				for _, d := range g.dependents[i] {
+					if selected[d] {
+						failed[d] = true
+						if pending[d]--; pending[d] == 0 {
+							ready = append(ready, d)
+						}
+					}
+				}
+				continue
+			}
+			running++
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results <- result{i, fc.calculateInTransaction(ctx, db, g.calculators[i])}
+			}(i)
+		}
+		if running == 0 {
+			continue
+		}
+
+		r := <-results
+		running--
+		finished++
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+		for _, d := range g.dependents[r.calc] {
+			if !selected[d] {
+				continue
+			}
+			if r.err != nil {
+				failed[d] = true
+			}
+			if pending[d]--; pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// calculateInTransaction runs calc in a transaction of its own.
+func (fc *FeatureCalculator) calculateInTransaction(ctx context.Context, db *sql.DB, calc MetricCalculator) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("%s: beginning transaction: %w", calc.Name(), err)
+		fc.record(CalculatorRun{Name: calc.Name(), StartedAt: time.Now(), Status: RunFailed, Error: err.Error()})
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fc.calculate(ctx, tx, calc); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: committing transaction: %w", calc.Name(), err)
+	}
+	return nil
+}
+
+// calculate runs calc within fc.Timeout and records the run.
+func (fc *FeatureCalculator) calculate(ctx context.Context, tx *sql.Tx, calc MetricCalculator) error {
+	if fc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fc.Timeout)
+		defer cancel()
+	}
+
+	run := CalculatorRun{Name: calc.Name(), StartedAt: time.Now(), Status: RunOK}
+	err := calc.Calculate(ctx, tx)
+	run.Duration = time.Since(run.StartedAt)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%s: %w", calc.Name(), ErrQueryTimeout)
+	}
+	if err != nil {
+		run.Status, run.Error = RunFailed, err.Error()
+	}
+	fc.record(run)
+	calculatorDuration.Set(run.Duration.Seconds(), run.Name)
+	return err
+}
+
+func (fc *FeatureCalculator) record(run CalculatorRun) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.runs = append(fc.runs, run)
+}
+
+// recordCalculatorRuns stores the latest run of each calculator.
+func recordCalculatorRuns(ctx context.Context, db *sql.DB, runs []CalculatorRun) error {
+	for _, run := range runs {
+		var startedAt string
+		if !run.StartedAt.IsZero() {
+			startedAt = run.StartedAt.UTC().Format(time.RFC3339Nano)
+		}
+		_, err := db.ExecContext(ctx, `
+		INSERT INTO calculator_runs (Name, StartedAt, DurationMs, Status, Error) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (Name) DO UPDATE SET
+			StartedAt = excluded.StartedAt, DurationMs = excluded.DurationMs,
+			Status = excluded.Status, Error = excluded.Error
+		`, run.Name, startedAt, float64(run.Duration.Microseconds())/1000, run.Status, run.Error)
+		if err != nil {
+			return fmt.Errorf("recording run of %s: %w", run.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetCalculatorRuns returns the latest recorded run of each calculator,
+// ordered by name.
+func GetCalculatorRuns(ctx context.Context, db *sql.DB) ([]CalculatorRun, error) {
+	var runs []CalculatorRun
+	err := withTimeout(ctx, 5*time.Second, "getting calculator runs", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, `SELECT Name, StartedAt, DurationMs, Status, Error FROM calculator_runs ORDER BY Name`)
+		if err != nil {
+			return fmt.Errorf("querying calculator runs: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var run CalculatorRun
+			var startedAt string
+			var durationMs float64
+			if err := rows.Scan(&run.Name, &startedAt, &durationMs, &run.Status, &run.Error); err != nil {
+				return fmt.Errorf("scanning calculator run: %w", err)
+			}
+			if startedAt != "" {
+				if run.StartedAt, err = time.Parse(time.RFC3339Nano, startedAt); err != nil {
+					return fmt.Errorf("calculator run of %s: %w", run.Name, err)
+				}
+			}
+			run.Duration = time.Duration(durationMs * float64(time.Millisecond))
+			runs = append(runs, run)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
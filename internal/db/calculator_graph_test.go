@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCalculator declares tables but only calls calculate, if set.
+type fakeCalculator struct {
+	name            string
+	inputs, outputs []string
+	calculate       func(ctx context.Context, tx *sql.Tx) error
+}
+
+func (f *fakeCalculator) Name() string      { return f.name }
+func (f *fakeCalculator) Inputs() []string  { return f.inputs }
+func (f *fakeCalculator) Outputs() []string { return f.outputs }
+
+func (f *fakeCalculator) Calculate(ctx context.Context, tx *sql.Tx) error {
+	if f.calculate == nil {
+		return nil
+	}
+	return f.calculate(ctx, tx)
+}
+
+func runNames(runs []CalculatorRun, status string) []string {
+	var names []string
+	for _, run := range runs {
+		if run.Status == status {
+			names = append(names, run.Name)
+		}
+	}
+	return names
+}
+
+// TestCalculatorOrder tests that calculators run after those writing the
+// tables they read, whatever order they are registered in, and that
+// cycles are rejected.
+func TestCalculatorOrder(t *testing.T) {
+	fc := &FeatureCalculator{}
+	fc.AddCalculator(&AggregatedMetrics{})
+	fc.AddCalculator(&LiftDifferences{})
+	fc.AddCalculator(&PerformanceTrends{})
+	fc.AddCalculator(&SuccessfulAttempts{})
+	order, err := fc.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"performance_trends", "successful_attempts", "lift_differences", "aggregated_metrics"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order %v, want %v", order, want)
+	}
+
+	fc = &FeatureCalculator{}
+	fc.AddCalculator(&fakeCalculator{name: "a", inputs: []string{"x"}, outputs: []string{"y"}})
+	fc.AddCalculator(&fakeCalculator{name: "b", inputs: []string{"y"}, outputs: []string{"x"}})
+	if _, err := fc.Order(); err == nil || !strings.Contains(err.Error(), "depend on each other") {
+		t.Errorf("cycle: got %v", err)
+	}
+}
+
+// TestRecompute tests that Recompute runs a calculator and its dependents
+// only, and that the runs are recorded.
+func TestRecompute(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Squat1Kg: 100, Squat2Kg: 110, Squat3Kg: -115, Date: "2024-03-01"},
+	})
+	ctx := context.Background()
+
+	fc := NewFeatureCalculator()
+	if err := fc.UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+	if got := runNames(fc.Runs(), RunOK); len(got) != 11 {
+		t.Errorf("UpdateAllMetrics ran %v", got)
+	}
+
+	if err := fc.Recompute(ctx, database, "successful_attempts"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"successful_attempts", "lift_differences", "aggregated_metrics"}
+	if got := runNames(fc.Runs(), RunOK); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recompute ran %v, want %v", got, want)
+	}
+	var squat2To3 float64
+	if err := database.QueryRow(`SELECT Squat2To3Kg FROM lifter_metrics`).Scan(&squat2To3); err != nil || squat2To3 != 5 {
+		t.Errorf("Squat2To3Kg = %v, %v", squat2To3, err)
+	}
+
+	if err := fc.Recompute(ctx, database, "nonexistent"); err == nil {
+		t.Error("unknown calculator accepted")
+	}
+
+	runs, err := GetCalculatorRuns(ctx, database)
+	if err != nil || len(runs) != 11 {
+		t.Fatalf("GetCalculatorRuns = %d runs, %v", len(runs), err)
+	}
+	for _, run := range runs {
+		if run.Status != RunOK || run.StartedAt.IsZero() {
+			t.Errorf("recorded run %+v", run)
+		}
+	}
+}
+
+// TestRunConcurrently tests that independent calculators run at the same
+// time and that only the dependents of a failed calculator are skipped.
+func TestRunConcurrently(t *testing.T) {
+	database, err := CreateDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var running, peak int32
+	slow := func(ctx context.Context, tx *sql.Tx) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	failing := func(ctx context.Context, tx *sql.Tx) error { return errors.New("boom") }
+
+	fc := &FeatureCalculator{Parallelism: 4}
+	fc.AddCalculator(&fakeCalculator{name: "a", outputs: []string{"x"}, calculate: slow})
+	fc.AddCalculator(&fakeCalculator{name: "b", outputs: []string{"y"}, calculate: slow})
+	fc.AddCalculator(&fakeCalculator{name: "c", outputs: []string{"z"}, calculate: failing})
+	fc.AddCalculator(&fakeCalculator{name: "d", inputs: []string{"x", "y"}, outputs: []string{"w"}, calculate: slow})
+	fc.AddCalculator(&fakeCalculator{name: "e", inputs: []string{"z"}, outputs: []string{"v"}})
+	fc.AddCalculator(&fakeCalculator{name: "f", inputs: []string{"v"}})
+
+	err = fc.UpdateAllMetrics(context.Background(), database)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("error %v, want boom", err)
+	}
+	if peak < 2 {
+		t.Errorf("at most %d calculators ran at once", peak)
+	}
+	runs := fc.Runs()
+	if got := runNames(runs, RunOK); len(got) != 3 || got[2] != "d" {
+		t.Errorf("succeeded: %v", got)
+	}
+	if got := runNames(runs, RunFailed); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("failed: %v", got)
+	}
+	if got := runNames(runs, RunSkipped); !reflect.DeepEqual(got, []string{"e", "f"}) {
+		t.Errorf("skipped: %v", got)
+	}
+}
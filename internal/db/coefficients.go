@@ -0,0 +1,79 @@
+package db
+
+import (
+	"fmt"
+	"math"
+
+	"liftmetrics/internal/coefficients"
+)
+
+// CoefficientTolerance is how far a computed coefficient may be from the
+// value in the CSV, which is rounded to two decimals, and still match.
+const CoefficientTolerance = 0.05
+
+// CoefficientCheck compares the computed values of one coefficient with
+// those of the CSV.
+type CoefficientCheck struct {
+	Name string
+	// Checked counts the records with a value in the CSV, and Mismatched
+	// those among them off by more than CoefficientTolerance.
+	Checked    int
+	Mismatched int
+	// MaxDeviation is the largest difference found.
+	MaxDeviation float64
+	// Filled counts the records without a value in the CSV that were given
+	// the computed one.
+	Filled int
+}
+
+// String summarises the check in one line.
+func (c CoefficientCheck) String() string {
+	return fmt.Sprintf("%s: %d of %d records off by more than %g (at most %.2f), %d filled in",
+		c.Name, c.Mismatched, c.Checked, CoefficientTolerance, c.MaxDeviation, c.Filled)
+}
+
+// completeCoefficients checks the Dots, Wilks, Glossbrenner and Goodlift of
+// records against the coefficients package and fills in those the CSV left
+// blank, rounded to two decimals like the rest.
+func completeCoefficients(records []*Record) []CoefficientCheck {
+	scores := []struct {
+		name    string
+		field   func(r *Record) *float64
+		compute func(r *Record) float64
+	}{
+		{"Dots", func(r *Record) *float64 { return &r.Dots }, func(r *Record) float64 {
+			return coefficients.Dots(r.Sex, r.BodyweightKg, r.TotalKg)
+		}},
+		{"Wilks", func(r *Record) *float64 { return &r.Wilks }, func(r *Record) float64 {
+			return coefficients.Wilks(r.Sex, r.BodyweightKg, r.TotalKg)
+		}},
+		{"Glossbrenner", func(r *Record) *float64 { return &r.Glossbrenner }, func(r *Record) float64 {
+			return coefficients.Glossbrenner(r.Sex, r.BodyweightKg, r.TotalKg)
+		}},
+		{"Goodlift", func(r *Record) *float64 { return &r.Goodlift }, func(r *Record) float64 {
+			return coefficients.Goodlift(r.Sex, r.Equipment, r.Event, r.BodyweightKg, r.TotalKg)
+		}},
+	}
+
+	checks := make([]CoefficientCheck, len(scores))
+	for i, score := range scores {
+		check := CoefficientCheck{Name: score.name}
+		for _, r := range records {
+			value, computed := score.field(r), score.compute(r)
+			switch {
+			case *value > 0:
+				check.Checked++
+				deviation := math.Abs(*value - computed)
+				check.MaxDeviation = math.Max(check.MaxDeviation, deviation)
+				if deviation > CoefficientTolerance {
+					check.Mismatched++
+				}
+			case computed > 0:
+				*value = math.Round(computed*100) / 100
+				check.Filled++
+			}
+		}
+		checks[i] = check
+	}
+	return checks
+}
@@ -0,0 +1,37 @@
+package db
+
+import "testing"
+
+// TestCompleteCoefficients tests that published coefficients are checked
+// within the tolerance and that blank ones are filled in.
+func TestCompleteCoefficients(t *testing.T) {
+	records := []*Record{
+		// Published values for a 700 kg total at 93 kg
+		{Sex: "M", Equipment: "Raw", Event: "SBD", BodyweightKg: 93, TotalKg: 700, Dots: 445.38, Wilks: 439.73, Glossbrenner: 423.44, Goodlift: 91.57},
+		{Sex: "M", Equipment: "Raw", Event: "SBD", BodyweightKg: 93, TotalKg: 700, Dots: 450, Wilks: 439.73, Glossbrenner: 423.44},
+		{Sex: "F", Equipment: "Raw", Event: "SBD", BodyweightKg: 0, TotalKg: 400},
+	}
+
+	checks := completeCoefficients(records)
+	want := []CoefficientCheck{
+		{Name: "Dots", Checked: 2, Mismatched: 1},
+		{Name: "Wilks", Checked: 2},
+		{Name: "Glossbrenner", Checked: 2},
+		{Name: "Goodlift", Checked: 1, Filled: 1},
+	}
+	for i, check := range checks {
+		check.MaxDeviation = 0
+		if check != want[i] {
+			t.Errorf("check %+v, want %+v", check, want[i])
+		}
+	}
+	if checks[0].MaxDeviation < 4.6 || checks[0].MaxDeviation > 4.7 {
+		t.Errorf("Dots deviation %v", checks[0].MaxDeviation)
+	}
+	if records[1].Goodlift != 91.57 {
+		t.Errorf("Goodlift filled in as %v, want 91.57", records[1].Goodlift)
+	}
+	if records[2].Dots != 0 {
+		t.Errorf("Dots filled in without a bodyweight: %v", records[2].Dots)
+	}
+}
@@ -63,7 +63,7 @@ func CreateDatabase(dbFilePath string, deleteExisting bool) (*sql.DB, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", dbFilePath)
+	db, err := sql.Open(driverName, dbFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -77,6 +77,13 @@ func CreateDatabase(dbFilePath string, deleteExisting bool) (*sql.DB, error) {
 }
 
 func createTables(db *sql.DB) error {
+	// Only tables created here are known to match SchemaVersion
+	var existing int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'records'`).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("failed to check for existing tables: %w", err)
+	}
+
 	createTableSQL := `
     CREATE TABLE IF NOT EXISTS records (
         ID TEXT PRIMARY KEY,
@@ -167,6 +174,71 @@ func createTables(db *sql.DB) error {
         FOREIGN KEY (ID) REFERENCES records(ID)
     );
 
+    CREATE TABLE IF NOT EXISTS attempts (
+        EntryID TEXT,
+        Lift TEXT,
+        Attempt INTEGER,
+        Kg REAL,
+        Outcome TEXT,
+        JumpKg REAL,
+        JumpPerc REAL,
+        Retry INTEGER,
+        PRIMARY KEY (EntryID, Lift, Attempt),
+        FOREIGN KEY (EntryID) REFERENCES records(ID)
+    );
+
+    CREATE TABLE IF NOT EXISTS attempt_strategy (
+        Year INTEGER,
+        Sex TEXT,
+        Equipment TEXT,
+        Lift TEXT,
+        Attempt INTEGER,
+        Bucket TEXT,
+        Attempts INTEGER,
+        Made INTEGER,
+        Jumps INTEGER,
+        JumpsWithFinal INTEGER,
+        JumpPercOfOpenerSum REAL,
+        JumpPercOfFinalSum REAL,
+        AfterMiss INTEGER,
+        Retries INTEGER,
+        RetriesMade INTEGER,
+        MissJumps INTEGER,
+        MissJumpsMade INTEGER,
+        Openers INTEGER,
+        OpenerBelowKgSum REAL,
+        OpenerBelowPercSum REAL,
+        PRIMARY KEY (Year, Sex, Equipment, Lift, Attempt, Bucket)
+    );
+
+    CREATE TABLE IF NOT EXISTS progression_priors (
+        Sex TEXT,
+        Equipment TEXT,
+        Lift TEXT,
+        TrainingYear INTEGER,
+        Pairs INTEGER,
+        MedianRateKg REAL,
+        IqrChangeKg REAL,
+        PRIMARY KEY (Sex, Equipment, Lift, TrainingYear)
+    );
+
+    CREATE TABLE IF NOT EXISTS peer_ranks (
+        ID TEXT,
+        Basis TEXT,
+        Year INTEGER,
+        GlobalRank INTEGER,
+        GlobalPeers INTEGER,
+        GlobalPercentile REAL,
+        CountryRank INTEGER,
+        CountryPeers INTEGER,
+        CountryPercentile REAL,
+        FederationRank INTEGER,
+        FederationPeers INTEGER,
+        FederationPercentile REAL,
+        PRIMARY KEY (ID, Basis),
+        FOREIGN KEY (ID) REFERENCES records(ID)
+    );
+
     CREATE TABLE IF NOT EXISTS aggregated_metrics_sbd (
         Name TEXT,
         Equipment TEXT,
@@ -205,44 +277,113 @@ func createTables(db *sql.DB) error {
     );
 
     CREATE TABLE IF NOT EXISTS weight_class_distribution (
+        Year INTEGER,
         WeightClass TEXT,
         Sex TEXT,
+        Equipment TEXT,
         Count INTEGER,
-        PRIMARY KEY (WeightClass, Sex)
+        PRIMARY KEY (Year, WeightClass, Sex, Equipment)
     );
 
     CREATE TABLE IF NOT EXISTS age_group_performance (
+        Year INTEGER,
         AgeClass TEXT,
         Sex TEXT,
+        Equipment TEXT,
         AvgSquat REAL,
         AvgBench REAL,
         AvgDeadlift REAL,
         AvgTotal REAL,
-        PRIMARY KEY (AgeClass, Sex)
+        MedianSquat REAL,
+        P10Squat REAL,
+        P25Squat REAL,
+        P75Squat REAL,
+        P90Squat REAL,
+        MedianBench REAL,
+        P10Bench REAL,
+        P25Bench REAL,
+        P75Bench REAL,
+        P90Bench REAL,
+        MedianDeadlift REAL,
+        P10Deadlift REAL,
+        P25Deadlift REAL,
+        P75Deadlift REAL,
+        P90Deadlift REAL,
+        MedianTotal REAL,
+        P10Total REAL,
+        P25Total REAL,
+        P75Total REAL,
+        P90Total REAL,
+        PRIMARY KEY (Year, AgeClass, Sex, Equipment)
     );
 
     CREATE TABLE IF NOT EXISTS performance_trends (
         Year INTEGER,
         Sex TEXT,
+        Equipment TEXT,
         AvgSquat REAL,
         AvgBench REAL,
         AvgDeadlift REAL,
         AvgTotal REAL,
-        PRIMARY KEY (Year, Sex)
+        MedianSquat REAL,
+        P10Squat REAL,
+        P25Squat REAL,
+        P75Squat REAL,
+        P90Squat REAL,
+        MedianBench REAL,
+        P10Bench REAL,
+        P25Bench REAL,
+        P75Bench REAL,
+        P90Bench REAL,
+        MedianDeadlift REAL,
+        P10Deadlift REAL,
+        P25Deadlift REAL,
+        P75Deadlift REAL,
+        P90Deadlift REAL,
+        MedianTotal REAL,
+        P10Total REAL,
+        P25Total REAL,
+        P75Total REAL,
+        P90Total REAL,
+        PRIMARY KEY (Year, Sex, Equipment)
+    );
+
+    CREATE TABLE IF NOT EXISTS metadata (
+        Key TEXT PRIMARY KEY,
+        Value TEXT
+    );
+
+    CREATE TABLE IF NOT EXISTS calculator_runs (
+        Name TEXT PRIMARY KEY,
+        StartedAt TEXT,
+        DurationMs REAL,
+        Status TEXT,
+        Error TEXT
     );
 
     CREATE INDEX IF NOT EXISTS idx_lifter_metrics_name_date_equipment ON lifter_metrics(Name, Date, Equipment);
+    CREATE INDEX IF NOT EXISTS idx_attempts_lift_attempt ON attempts(Lift, Attempt);
     CREATE INDEX IF NOT EXISTS idx_records_name_date_equipment ON records(Name, Date, Equipment);
     CREATE INDEX IF NOT EXISTS idx_records_weightclass_sex ON records(WeightClassKg, Sex);
     CREATE INDEX IF NOT EXISTS idx_records_ageclass_sex ON records(AgeClass, Sex);
     CREATE INDEX IF NOT EXISTS idx_records_date_sex ON records(Date, Sex);
     `
 
-	_, err := db.Exec(createTableSQL)
+	_, err = db.Exec(createTableSQL)
 	if err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	// An older database keeps the version it records, or stays unversioned
+	// so that it is reported as needing a rebuild
+	if existing > 0 {
+		return nil
+	}
+	_, err = db.Exec(`INSERT OR IGNORE INTO metadata (Key, Value) VALUES ('SchemaVersion', ?)`, SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
 	return nil
 }
 
@@ -265,6 +406,11 @@ func PopulateDatabase(db *sql.DB, records []*Record) error {
 	}
 	defer stmt.Close()
 
+	// Checking the published coefficients against our own formulas and filling in the blank ones
+	for _, check := range completeCoefficients(records) {
+		fmt.Println(check)
+	}
+
 	bar := progressbar.NewOptions(len(records), progressbar.OptionSetPredictTime(false))
 
 	for _, record := range records {
@@ -305,5 +451,5 @@ func PopulateDatabase(db *sql.DB, records []*Record) error {
 }
 
 func OpenDatabase(dbPath string) (*sql.DB, error) {
-	return sql.Open("sqlite3", dbPath)
+	return sql.Open(driverName, dbPath)
 }
@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"liftmetrics/internal/metrics"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -15,50 +18,155 @@ var (
 	ErrQueryTimeout = errors.New("database query timed out")
 )
 
+// queryDuration records how long the query functions of this file take.
+var queryDuration = metrics.Default.NewHistogram("liftmetrics_db_query_duration_seconds",
+	"Duration of database queries by function.", metrics.DefBuckets, "function")
+
+// observeQuery records the duration of a call to function begun at start.
+// Use it as defer observeQuery("Name", time.Now()).
+func observeQuery(function string, start time.Time) {
+	queryDuration.Observe(time.Since(start).Seconds(), function)
+}
+
 // LifterName represents the basic information of a lifter.
 type LifterName struct {
 	Name string `json:"name"`
 }
 
+// EntryFilter restricts lifter queries to one equipment category and/or
+// event. Empty fields mean no restriction.
+type EntryFilter struct {
+	Equipment string
+	Event     string
+}
+
+// Equipment categories and events as spelled in the OpenPowerlifting data.
+var (
+	EquipmentCategories = []string{"Raw", "Wraps", "Single-ply", "Multi-ply", "Unlimited", "Straps"}
+	Events              = []string{"SBD", "BD", "SD", "SB", "S", "B", "D"}
+)
+
+// Validate returns an error if the filter names an unknown equipment
+// category or event.
+func (f EntryFilter) Validate() error {
+	if f.Equipment != "" && !slices.Contains(EquipmentCategories, f.Equipment) {
+		return fmt.Errorf("unknown equipment %q, expected one of %s", f.Equipment, strings.Join(EquipmentCategories, ", "))
+	}
+	if f.Event != "" && !slices.Contains(Events, f.Event) {
+		return fmt.Errorf("unknown event %q, expected one of %s", f.Event, strings.Join(Events, ", "))
+	}
+	return nil
+}
+
+// clause returns the SQL conditions for the filter on the records table with
+// the given alias, each prefixed with AND.
+func (f EntryFilter) clause(alias string) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	if f.Equipment != "" {
+		b.WriteString(" AND " + alias + ".Equipment = ?")
+		args = append(args, f.Equipment)
+	}
+	if f.Event != "" {
+		b.WriteString(" AND " + alias + ".Event = ?")
+		args = append(args, f.Event)
+	}
+	return b.String(), args
+}
+
 // LifterDetails represents detailed information about a lifter's performance in a meet.
+// Percentages and jumps are 0 where the attempts they are computed from are missing.
+// The peer ranks place the entry among the entries of its peers, as in
+// EntryPeerRank, and are null where it is not ranked.
 type LifterDetails struct {
-	Name                       string  `json:"name"`
-	Age                        float64 `json:"age"`
-	Date                       string  `json:"date"`
-	MeetName                   string  `json:"meetName"`
-	SuccessfulSquatAttempts    int     `json:"successfulSquatAttempts"`
-	SuccessfulBenchAttempts    int     `json:"successfulBenchAttempts"`
-	SuccessfulDeadliftAttempts int     `json:"successfulDeadliftAttempts"`
-	TotalSuccessfulAttempts    int     `json:"totalSuccessfulAttempts"`
-	Squat1Perc                 float64 `json:"squat1Perc"`
-	Squat2Perc                 float64 `json:"squat2Perc"`
-	Squat3Perc                 float64 `json:"squat3Perc"`
-	Bench1Perc                 float64 `json:"bench1Perc"`
-	Bench2Perc                 float64 `json:"bench2Perc"`
-	Bench3Perc                 float64 `json:"bench3Perc"`
-	Deadlift1Perc              float64 `json:"deadlift1Perc"`
-	Deadlift2Perc              float64 `json:"deadlift2Perc"`
-	Deadlift3Perc              float64 `json:"deadlift3Perc"`
-	Squat1To2Kg                float64 `json:"squat1To2Kg"`
-	Squat2To3Kg                float64 `json:"squat2To3Kg"`
-	Bench1To2Kg                float64 `json:"bench1To2Kg"`
-	Bench2To3Kg                float64 `json:"bench2To3Kg"`
-	Deadlift1To2Kg             float64 `json:"deadlift1To2Kg"`
-	Deadlift2To3Kg             float64 `json:"deadlift2To3Kg"`
+	Name                       string   `json:"name"`
+	Age                        float64  `json:"age"`
+	Date                       string   `json:"date"`
+	MeetName                   string   `json:"meetName"`
+	Equipment                  string   `json:"equipment"`
+	Event                      string   `json:"event"`
+	SuccessfulSquatAttempts    int      `json:"successfulSquatAttempts"`
+	SuccessfulBenchAttempts    int      `json:"successfulBenchAttempts"`
+	SuccessfulDeadliftAttempts int      `json:"successfulDeadliftAttempts"`
+	TotalSuccessfulAttempts    int      `json:"totalSuccessfulAttempts"`
+	Squat1Perc                 float64  `json:"squat1Perc"`
+	Squat2Perc                 float64  `json:"squat2Perc"`
+	Squat3Perc                 float64  `json:"squat3Perc"`
+	Bench1Perc                 float64  `json:"bench1Perc"`
+	Bench2Perc                 float64  `json:"bench2Perc"`
+	Bench3Perc                 float64  `json:"bench3Perc"`
+	Deadlift1Perc              float64  `json:"deadlift1Perc"`
+	Deadlift2Perc              float64  `json:"deadlift2Perc"`
+	Deadlift3Perc              float64  `json:"deadlift3Perc"`
+	Squat1To2Kg                float64  `json:"squat1To2Kg"`
+	Squat2To3Kg                float64  `json:"squat2To3Kg"`
+	Bench1To2Kg                float64  `json:"bench1To2Kg"`
+	Bench2To3Kg                float64  `json:"bench2To3Kg"`
+	Deadlift1To2Kg             float64  `json:"deadlift1To2Kg"`
+	Deadlift2To3Kg             float64  `json:"deadlift2To3Kg"`
+	GlobalRank                 *int     `json:"globalRank"`
+	GlobalPeers                *int     `json:"globalPeers"`
+	GlobalPercentile           *float64 `json:"globalPercentile"`
+	CountryRank                *int     `json:"countryRank"`
+	CountryPeers               *int     `json:"countryPeers"`
+	CountryPercentile          *float64 `json:"countryPercentile"`
+	FederationRank             *int     `json:"federationRank"`
+	FederationPeers            *int     `json:"federationPeers"`
+	FederationPercentile       *float64 `json:"federationPercentile"`
 }
 
 // LifterPerformance represents a lifter's performance at a specific meet.
 type LifterPerformance struct {
-	Date     string  `json:"date"`
-	Squat    float64 `json:"squat"`
-	Bench    float64 `json:"bench"`
-	Deadlift float64 `json:"deadlift"`
-	Total    float64 `json:"total"`
+	Date      string  `json:"date"`
+	Equipment string  `json:"equipment"`
+	Event     string  `json:"event"`
+	Squat     float64 `json:"squat"`
+	Bench     float64 `json:"bench"`
+	Deadlift  float64 `json:"deadlift"`
+	Total     float64 `json:"total"`
+}
+
+// LifterProfile represents a summary of a lifter's career. Bests are given
+// per equipment category and event, so that, for example, an equipped squat
+// is never reported as a raw lifter's best.
+type LifterProfile struct {
+	Name          string        `json:"name"`
+	Sex           string        `json:"sex"`
+	Country       string        `json:"country"`
+	Federation    string        `json:"federation"`
+	WeightClassKg string        `json:"weightClassKg"`
+	AgeClass      string        `json:"ageClass"`
+	Division      string        `json:"division"`
+	FirstMeetDate string        `json:"firstMeetDate"`
+	LastMeetDate  string        `json:"lastMeetDate"`
+	Meets         int           `json:"meets"`
+	Equipment     []string      `json:"equipment"`
+	Events        []string      `json:"events"`
+	Bests         []LifterBests `json:"bests"`
+}
+
+// LifterBests holds a lifter's best results within one equipment category
+// and event.
+type LifterBests struct {
+	Name           string  `json:"name"`
+	Equipment      string  `json:"equipment"`
+	Event          string  `json:"event"`
+	Meets          int     `json:"meets"`
+	BestSquatKg    float64 `json:"bestSquatKg"`
+	BestBenchKg    float64 `json:"bestBenchKg"`
+	BestDeadliftKg float64 `json:"bestDeadliftKg"`
+	BestTotalKg    float64 `json:"bestTotalKg"`
+	BestDots       float64 `json:"bestDots"`
 }
 
-// LifterStats represents aggregated statistics for a lifter.
+// LifterStats represents aggregated statistics for a lifter within one
+// equipment category and event. Average jumps are over the meets where
+// both attempts were taken, and 0 if there are none.
 type LifterStats struct {
 	Name               string  `json:"name"`
+	Equipment          string  `json:"equipment"`
+	Event              string  `json:"event"`
+	Meets              int     `json:"meets"`
 	AvgSquatSuccess    float64 `json:"avgSquatSuccess"`
 	AvgBenchSuccess    float64 `json:"avgBenchSuccess"`
 	AvgDeadliftSuccess float64 `json:"avgDeadliftSuccess"`
@@ -82,18 +190,8 @@ func withTimeout(ctx context.Context, timeout time.Duration, op string, fn func(
 	return err
 }
 
-// queryWithTimeout executes a query with a timeout
-func queryWithTimeout(ctx context.Context, db *sql.DB, timeout time.Duration, op, query string, args ...interface{}) (*sql.Rows, error) {
-	var rows *sql.Rows
-	err := withTimeout(ctx, timeout, op, func(ctx context.Context) error {
-		var err error
-		rows, err = db.QueryContext(ctx, query, args...)
-		return err
-	})
-	return rows, err
-}
-
 func FilterRecentRecords(ctx context.Context, db *sql.DB) error {
+	defer observeQuery("FilterRecentRecords", time.Now())
 	// First, determine the most recent year in the dataset
 	var mostRecentYear int
 	err := db.QueryRowContext(ctx, "SELECT MAX(CAST(substr(Date, 1, 4) AS INTEGER)) FROM records").Scan(&mostRecentYear)
@@ -126,6 +224,7 @@ func FilterRecentRecords(ctx context.Context, db *sql.DB) error {
 }
 
 func UpdateWeightClasses(ctx context.Context, db *sql.DB) error {
+	defer observeQuery("UpdateWeightClasses", time.Now())
 	query := `
     UPDATE records
     SET WeightClassKg = CASE
@@ -200,6 +299,7 @@ func UpdateWeightClasses(ctx context.Context, db *sql.DB) error {
 }
 
 func GetAllLifters(ctx context.Context, db *sql.DB) ([]LifterName, error) {
+	defer observeQuery("GetAllLifters", time.Now())
 	query := `SELECT DISTINCT Name FROM records ORDER BY Name`
 
 	rows, err := db.QueryContext(ctx, query)
@@ -233,34 +333,40 @@ func GetAllLifters(ctx context.Context, db *sql.DB) ([]LifterName, error) {
 	return lifters, nil
 }
 
-// GetLifterDetails retrieves detailed information about a specific lifter's performances.
-func GetLifterDetails(ctx context.Context, db *sql.DB, name string) ([]LifterDetails, error) {
+// GetLifterDetails retrieves detailed information about a specific lifter's
+// performances, ordered by equipment and event and then most recent first.
+func GetLifterDetails(ctx context.Context, db *sql.DB, name string, filter EntryFilter) ([]LifterDetails, error) {
+	defer observeQuery("GetLifterDetails", time.Now())
+	clause, args := filter.clause("r")
 	query := `
         SELECT 
-            r.Name, r.Age, r.Date, r.MeetName, 
+            r.Name, r.Age, r.Date, r.MeetName, r.Equipment, r.Event,
             lm.SuccessfulSquatAttempts, lm.SuccessfulBenchAttempts, 
             lm.SuccessfulDeadliftAttempts, lm.TotalSuccessfulAttempts,
-            lm.Squat1Perc, lm.Squat2Perc, lm.Squat3Perc,
-            lm.Bench1Perc, lm.Bench2Perc, lm.Bench3Perc,
-            lm.Deadlift1Perc, lm.Deadlift2Perc, lm.Deadlift3Perc,
-            lm.Squat1To2Kg, lm.Squat2To3Kg,
-            lm.Bench1To2Kg, lm.Bench2To3Kg,
-            lm.Deadlift1To2Kg, lm.Deadlift2To3Kg
+            COALESCE(lm.Squat1Perc, 0), COALESCE(lm.Squat2Perc, 0), COALESCE(lm.Squat3Perc, 0),
+            COALESCE(lm.Bench1Perc, 0), COALESCE(lm.Bench2Perc, 0), COALESCE(lm.Bench3Perc, 0),
+            COALESCE(lm.Deadlift1Perc, 0), COALESCE(lm.Deadlift2Perc, 0), COALESCE(lm.Deadlift3Perc, 0),
+            COALESCE(lm.Squat1To2Kg, 0), COALESCE(lm.Squat2To3Kg, 0),
+            COALESCE(lm.Bench1To2Kg, 0), COALESCE(lm.Bench2To3Kg, 0),
+            COALESCE(lm.Deadlift1To2Kg, 0), COALESCE(lm.Deadlift2To3Kg, 0),
+            ` + prefixColumns("pr", peerRankColumns) + `
         FROM 
             records r
         JOIN 
             lifter_metrics lm ON r.ID = lm.ID
+        LEFT JOIN
+            peer_ranks pr ON pr.ID = r.ID AND pr.Basis = '` + RankBasisEntry + `'
         WHERE 
-            r.Name = ?
+            r.Name = ?` + clause + `
         ORDER BY 
-            r.Date DESC
+            r.Equipment, r.Event, r.Date DESC
     `
 
 	// Set a timeout for the query
 	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	rows, err := db.QueryContext(queryCtx, query, name)
+	rows, err := db.QueryContext(queryCtx, query, append([]interface{}{name}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("querying lifter details: %w", err)
 	}
@@ -274,7 +380,7 @@ func GetLifterDetails(ctx context.Context, db *sql.DB, name string) ([]LifterDet
 		default:
 			var d LifterDetails
 			err := rows.Scan(
-				&d.Name, &d.Age, &d.Date, &d.MeetName,
+				&d.Name, &d.Age, &d.Date, &d.MeetName, &d.Equipment, &d.Event,
 				&d.SuccessfulSquatAttempts, &d.SuccessfulBenchAttempts,
 				&d.SuccessfulDeadliftAttempts, &d.TotalSuccessfulAttempts,
 				&d.Squat1Perc, &d.Squat2Perc, &d.Squat3Perc,
@@ -283,6 +389,9 @@ func GetLifterDetails(ctx context.Context, db *sql.DB, name string) ([]LifterDet
 				&d.Squat1To2Kg, &d.Squat2To3Kg,
 				&d.Bench1To2Kg, &d.Bench2To3Kg,
 				&d.Deadlift1To2Kg, &d.Deadlift2To3Kg,
+				&d.GlobalRank, &d.GlobalPeers, &d.GlobalPercentile,
+				&d.CountryRank, &d.CountryPeers, &d.CountryPercentile,
+				&d.FederationRank, &d.FederationPeers, &d.FederationPercentile,
 			)
 			if err != nil {
 				return nil, fmt.Errorf("scanning lifter details: %w", err)
@@ -302,32 +411,41 @@ func GetLifterDetails(ctx context.Context, db *sql.DB, name string) ([]LifterDet
 	return details, nil
 }
 
-// GetLifterPerformanceOverTime retrieves a lifter's performance over time.
-func GetLifterPerformanceOverTime(ctx context.Context, db *sql.DB, lifterName string) ([]LifterPerformance, error) {
+// GetLifterPerformanceOverTime retrieves a lifter's performance over time,
+// ordered by equipment and event and then oldest first.
+func GetLifterPerformanceOverTime(ctx context.Context, db *sql.DB, lifterName string, filter EntryFilter) ([]LifterPerformance, error) {
+	defer observeQuery("GetLifterPerformanceOverTime", time.Now())
+	clause, args := filter.clause("r")
 	query := `
-    SELECT Date, Best3SquatKg, Best3BenchKg, Best3DeadliftKg, TotalKg
-    FROM records
-    WHERE Name = ?
-    ORDER BY Date
+    SELECT Date, Equipment, Event, Best3SquatKg, Best3BenchKg, Best3DeadliftKg, TotalKg
+    FROM records r
+    WHERE Name = ?` + clause + `
+    ORDER BY Equipment, Event, Date
     `
 
-	rows, err := queryWithTimeout(ctx, db, 5*time.Second, "getting lifter performance", query, lifterName)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
 	var performances []LifterPerformance
-	for rows.Next() {
-		var p LifterPerformance
-		if err := rows.Scan(&p.Date, &p.Squat, &p.Bench, &p.Deadlift, &p.Total); err != nil {
-			return nil, fmt.Errorf("scanning lifter performance: %w", err)
+	err := withTimeout(ctx, 5*time.Second, "getting lifter performance", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{lifterName}, args...)...)
+		if err != nil {
+			return fmt.Errorf("querying lifter performance: %w", err)
 		}
-		performances = append(performances, p)
-	}
+		defer rows.Close()
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating over lifter performance rows: %w", err)
+		for rows.Next() {
+			var p LifterPerformance
+			if err := rows.Scan(&p.Date, &p.Equipment, &p.Event, &p.Squat, &p.Bench, &p.Deadlift, &p.Total); err != nil {
+				return fmt.Errorf("scanning lifter performance: %w", err)
+			}
+			performances = append(performances, p)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lifter performance rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if len(performances) == 0 {
@@ -337,47 +455,195 @@ func GetLifterPerformanceOverTime(ctx context.Context, db *sql.DB, lifterName st
 	return performances, nil
 }
 
-// GetLifterStats retrieves statistics for a specific lifter.
-func GetLifterStats(ctx context.Context, db *sql.DB, lifterName string) (LifterStats, error) {
+// GetLifterProfile retrieves a summary of a lifter's career, restricted to the
+// entries matching filter. The descriptive fields are taken from the lifter's
+// most recent such entry.
+func GetLifterProfile(ctx context.Context, db *sql.DB, lifterName string, filter EntryFilter) (LifterProfile, error) {
+	profiles, err := GetLifterProfiles(ctx, db, []string{lifterName}, filter)
+	if err != nil {
+		return LifterProfile{}, err
+	}
+	profile, ok := profiles[lifterName]
+	if !ok {
+		return LifterProfile{}, ErrNoRows
+	}
+	return profile, nil
+}
+
+// GetLifterProfiles retrieves the profiles of several lifters at once, keyed
+// by name. Lifters without matching entries are left out.
+func GetLifterProfiles(ctx context.Context, db *sql.DB, names []string, filter EntryFilter) (map[string]LifterProfile, error) {
+	defer observeQuery("GetLifterProfiles", time.Now())
+	clause, args := filter.clause("r")
+	profiles := make(map[string]LifterProfile, len(names))
+
+	err := withTimeout(ctx, 5*time.Second, "getting lifter profiles", func(ctx context.Context) error {
+		return inChunks(names, func(chunk []interface{}, placeholders string) error {
+			query := `
+			SELECT
+				l.Name, l.Sex, l.Country, l.Federation, l.WeightClassKg, l.AgeClass, l.Division,
+				a.FirstMeetDate, a.LastMeetDate, a.Meets, a.Equipment, a.Events
+			FROM (
+				SELECT r.*, ROW_NUMBER() OVER (PARTITION BY r.Name ORDER BY r.Date DESC) AS Recency
+				FROM records r
+				WHERE r.Name IN (` + placeholders + `)` + clause + `
+			) l
+			JOIN (
+				SELECT
+					r.Name,
+					MIN(r.Date) AS FirstMeetDate, MAX(r.Date) AS LastMeetDate, COUNT(*) AS Meets,
+					group_concat(DISTINCT r.Equipment) AS Equipment, group_concat(DISTINCT r.Event) AS Events
+				FROM records r
+				WHERE r.Name IN (` + placeholders + `)` + clause + `
+				GROUP BY r.Name
+			) a ON a.Name = l.Name
+			WHERE l.Recency = 1
+			`
+			queryArgs := append(append(append(append([]interface{}{}, chunk...), args...), chunk...), args...)
+
+			rows, err := db.QueryContext(ctx, query, queryArgs...)
+			if err != nil {
+				return fmt.Errorf("querying lifter profiles: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var profile LifterProfile
+				var equipment, events string
+				if err := rows.Scan(
+					&profile.Name, &profile.Sex, &profile.Country, &profile.Federation,
+					&profile.WeightClassKg, &profile.AgeClass, &profile.Division,
+					&profile.FirstMeetDate, &profile.LastMeetDate, &profile.Meets,
+					&equipment, &events,
+				); err != nil {
+					return fmt.Errorf("scanning lifter profile: %w", err)
+				}
+				profile.Equipment = strings.Split(equipment, ",")
+				profile.Events = strings.Split(events, ",")
+				profile.Bests = []LifterBests{}
+				profiles[profile.Name] = profile
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterating over lifter profile rows: %w", err)
+			}
+			return getLifterBests(ctx, db, profiles, chunk, placeholders, clause, args)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// getLifterBests adds the bests per equipment category and event of the
+// lifters in chunk to their profiles.
+func getLifterBests(ctx context.Context, db *sql.DB, profiles map[string]LifterProfile, chunk []interface{}, placeholders, clause string, args []interface{}) error {
+	query := `
+	SELECT
+		r.Name, r.Equipment, r.Event, COUNT(*) AS Meets,
+		MAX(r.Best3SquatKg), MAX(r.Best3BenchKg), MAX(r.Best3DeadliftKg), MAX(r.TotalKg), MAX(r.Dots)
+	FROM records r
+	WHERE r.Name IN (` + placeholders + `)` + clause + `
+	GROUP BY r.Name, r.Equipment, r.Event
+	ORDER BY r.Name, r.Equipment, r.Event
+	`
+	rows, err := db.QueryContext(ctx, query, append(append([]interface{}{}, chunk...), args...)...)
+	if err != nil {
+		return fmt.Errorf("querying lifter bests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bests LifterBests
+		if err := rows.Scan(
+			&bests.Name, &bests.Equipment, &bests.Event, &bests.Meets,
+			&bests.BestSquatKg, &bests.BestBenchKg, &bests.BestDeadliftKg, &bests.BestTotalKg, &bests.BestDots,
+		); err != nil {
+			return fmt.Errorf("scanning lifter bests: %w", err)
+		}
+		if profile, ok := profiles[bests.Name]; ok {
+			profile.Bests = append(profile.Bests, bests)
+			profiles[bests.Name] = profile
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating over lifter bests rows: %w", err)
+	}
+	return nil
+}
+
+// GetLifterStats retrieves statistics for a specific lifter, one set per
+// equipment category and event so that, for example, bench-only meets do not
+// mix with full power meets.
+func GetLifterStats(ctx context.Context, db *sql.DB, lifterName string, filter EntryFilter) ([]LifterStats, error) {
+	defer observeQuery("GetLifterStats", time.Now())
+	clause, args := filter.clause("r")
 	query := `
 	SELECT 
-		r.Name, 
+		r.Name,
+		r.Equipment,
+		r.Event,
+		COUNT(*) as Meets,
 		AVG(lm.SuccessfulSquatAttempts) as AvgSquatSuccess,
 		AVG(lm.SuccessfulBenchAttempts) as AvgBenchSuccess,
 		AVG(lm.SuccessfulDeadliftAttempts) as AvgDeadliftSuccess,
-		AVG(lm.Squat1To2Kg) as AvgSquat1To2Kg,
-		AVG(lm.Squat2To3Kg) as AvgSquat2To3Kg,
-		AVG(lm.Bench1To2Kg) as AvgBench1To2Kg,
-		AVG(lm.Bench2To3Kg) as AvgBench2To3Kg,
-		AVG(lm.Deadlift1To2Kg) as AvgDeadlift1To2Kg,
-		AVG(lm.Deadlift2To3Kg) as AvgDeadlift2To3Kg
+		COALESCE(AVG(lm.Squat1To2Kg), 0) as AvgSquat1To2Kg,
+		COALESCE(AVG(lm.Squat2To3Kg), 0) as AvgSquat2To3Kg,
+		COALESCE(AVG(lm.Bench1To2Kg), 0) as AvgBench1To2Kg,
+		COALESCE(AVG(lm.Bench2To3Kg), 0) as AvgBench2To3Kg,
+		COALESCE(AVG(lm.Deadlift1To2Kg), 0) as AvgDeadlift1To2Kg,
+		COALESCE(AVG(lm.Deadlift2To3Kg), 0) as AvgDeadlift2To3Kg
 	FROM records r
 	JOIN lifter_metrics lm ON r.ID = lm.ID
-	WHERE r.Name = ?
-	GROUP BY r.Name
+	WHERE r.Name = ?` + clause + `
+	GROUP BY r.Name, r.Equipment, r.Event
+	ORDER BY r.Equipment, r.Event
 	`
 
-	var stats LifterStats
+	var stats []LifterStats
 	err := withTimeout(ctx, 5*time.Second, "getting lifter stats", func(ctx context.Context) error {
-		return db.QueryRowContext(ctx, query, lifterName).Scan(
-			&stats.Name,
-			&stats.AvgSquatSuccess,
-			&stats.AvgBenchSuccess,
-			&stats.AvgDeadliftSuccess,
-			&stats.AvgSquat1To2Kg,
-			&stats.AvgSquat2To3Kg,
-			&stats.AvgBench1To2Kg,
-			&stats.AvgBench2To3Kg,
-			&stats.AvgDeadlift1To2Kg,
-			&stats.AvgDeadlift2To3Kg,
-		)
-	})
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{lifterName}, args...)...)
+		if err != nil {
+			return fmt.Errorf("querying lifter stats: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var st LifterStats
+			if err := rows.Scan(
+				&st.Name,
+				&st.Equipment,
+				&st.Event,
+				&st.Meets,
+				&st.AvgSquatSuccess,
+				&st.AvgBenchSuccess,
+				&st.AvgDeadliftSuccess,
+				&st.AvgSquat1To2Kg,
+				&st.AvgSquat2To3Kg,
+				&st.AvgBench1To2Kg,
+				&st.AvgBench2To3Kg,
+				&st.AvgDeadlift1To2Kg,
+				&st.AvgDeadlift2To3Kg,
+			); err != nil {
+				return fmt.Errorf("scanning lifter stats: %w", err)
+			}
+			stats = append(stats, st)
+		}
 
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return LifterStats{}, ErrNoRows
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lifter stats rows: %w", err)
 		}
-		return LifterStats{}, fmt.Errorf("querying lifter stats: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
 	}
 
 	return stats, nil
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	_ "github.com/mattn/go-sqlite3" // Import for side-effects to register the SQLite driver
 	"os"
 	"path/filepath"
@@ -112,3 +115,78 @@ func TestPopulateDatabase(t *testing.T) {
 	}
 
 }
+
+// newTestDatabase creates a populated database in a temporary directory.
+func newTestDatabase(t *testing.T, records []*Record) *sql.DB {
+	t.Helper()
+
+	database, err := CreateDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if err := PopulateDatabase(database, records); err != nil {
+		t.Fatalf("PopulateDatabase failed: %v", err)
+	}
+
+	return database
+}
+
+// TestDataRevision tests the recorded schema version and that the recorded
+// revision changes on every rebuild.
+func TestDataRevision(t *testing.T) {
+	database, err := CreateDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if version, err := GetSchemaVersion(ctx, database); err != nil || version != SchemaVersion {
+		t.Errorf("GetSchemaVersion = %d, %v; want %d", version, err, SchemaVersion)
+	}
+	if _, err := GetDataRevision(ctx, database); !errors.Is(err, ErrNoRows) {
+		t.Fatalf("Expected ErrNoRows before a revision is set, got %v", err)
+	}
+
+	if err := SetDataRevision(ctx, database, "abc123"); err != nil {
+		t.Fatalf("SetDataRevision failed: %v", err)
+	}
+	first, err := GetDataRevision(ctx, database)
+	if err != nil || first.Revision != "abc123" || first.BuiltAt == "" {
+		t.Fatalf("GetDataRevision = %+v, %v", first, err)
+	}
+
+	if err := SetDataRevision(ctx, database, "abc123"); err != nil {
+		t.Fatalf("SetDataRevision failed: %v", err)
+	}
+	second, _ := GetDataRevision(ctx, database)
+	if second.String() == first.String() {
+		t.Errorf("Rebuilding from the same revision kept the token %s", first)
+	}
+}
+
+// TestSchemaVersionUnversioned tests that reopening a database that predates
+// versioning does not stamp it with the current version.
+func TestSchemaVersionUnversioned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := CreateDatabase(path, false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	if _, err := database.Exec(`DELETE FROM metadata WHERE Key = 'SchemaVersion'`); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	database, err = CreateDatabase(path, false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	defer database.Close()
+
+	if version, err := GetSchemaVersion(context.Background(), database); !errors.Is(err, ErrNoRows) {
+		t.Errorf("GetSchemaVersion = %d, %v; want ErrNoRows", version, err)
+	}
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Entry is one lifter's result at one meet, as stored in the records table.
+type Entry struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Sex             string  `json:"sex"`
+	Event           string  `json:"event"`
+	Equipment       string  `json:"equipment"`
+	Age             float64 `json:"age"`
+	AgeClass        string  `json:"ageClass"`
+	Division        string  `json:"division"`
+	BodyweightKg    float64 `json:"bodyweightKg"`
+	WeightClassKg   string  `json:"weightClassKg"`
+	Squat1Kg        float64 `json:"squat1Kg"`
+	Squat2Kg        float64 `json:"squat2Kg"`
+	Squat3Kg        float64 `json:"squat3Kg"`
+	Best3SquatKg    float64 `json:"best3SquatKg"`
+	Bench1Kg        float64 `json:"bench1Kg"`
+	Bench2Kg        float64 `json:"bench2Kg"`
+	Bench3Kg        float64 `json:"bench3Kg"`
+	Best3BenchKg    float64 `json:"best3BenchKg"`
+	Deadlift1Kg     float64 `json:"deadlift1Kg"`
+	Deadlift2Kg     float64 `json:"deadlift2Kg"`
+	Deadlift3Kg     float64 `json:"deadlift3Kg"`
+	Best3DeadliftKg float64 `json:"best3DeadliftKg"`
+	TotalKg         float64 `json:"totalKg"`
+	Place           string  `json:"place"`
+	Dots            float64 `json:"dots"`
+	Wilks           float64 `json:"wilks"`
+	Glossbrenner    float64 `json:"glossbrenner"`
+	Goodlift        float64 `json:"goodlift"`
+	Tested          string  `json:"tested"`
+	Country         string  `json:"country"`
+	Federation      string  `json:"federation"`
+	Date            string  `json:"date"`
+	MeetCountry     string  `json:"meetCountry"`
+	MeetState       string  `json:"meetState"`
+	MeetTown        string  `json:"meetTown"`
+	MeetName        string  `json:"meetName"`
+}
+
+// MeetKey identifies a meet. The data has no meet IDs, so a meet is the set
+// of entries sharing a federation, date and meet name.
+type MeetKey struct {
+	Federation string
+	Date       string
+	Name       string
+}
+
+// Meet describes a meet.
+type Meet struct {
+	Federation string `json:"federation"`
+	Date       string `json:"date"`
+	Name       string `json:"name"`
+	Country    string `json:"country"`
+	State      string `json:"state"`
+	Town       string `json:"town"`
+}
+
+// Meet returns the meet the entry was made at.
+func (e Entry) Meet() Meet {
+	return Meet{
+		Federation: e.Federation,
+		Date:       e.Date,
+		Name:       e.MeetName,
+		Country:    e.MeetCountry,
+		State:      e.MeetState,
+		Town:       e.MeetTown,
+	}
+}
+
+// Key returns the key of the meet.
+func (m Meet) Key() MeetKey {
+	return MeetKey{Federation: m.Federation, Date: m.Date, Name: m.Name}
+}
+
+// AttemptMetrics is the row of lifter_metrics for one entry. Percentages and
+// jumps are null where the attempts they are computed from are missing.
+type AttemptMetrics struct {
+	SuccessfulSquatAttempts    int      `json:"successfulSquatAttempts"`
+	SuccessfulBenchAttempts    int      `json:"successfulBenchAttempts"`
+	SuccessfulDeadliftAttempts int      `json:"successfulDeadliftAttempts"`
+	TotalSuccessfulAttempts    int      `json:"totalSuccessfulAttempts"`
+	Squat1Perc                 *float64 `json:"squat1Perc"`
+	Squat2Perc                 *float64 `json:"squat2Perc"`
+	Squat3Perc                 *float64 `json:"squat3Perc"`
+	Bench1Perc                 *float64 `json:"bench1Perc"`
+	Bench2Perc                 *float64 `json:"bench2Perc"`
+	Bench3Perc                 *float64 `json:"bench3Perc"`
+	Deadlift1Perc              *float64 `json:"deadlift1Perc"`
+	Deadlift2Perc              *float64 `json:"deadlift2Perc"`
+	Deadlift3Perc              *float64 `json:"deadlift3Perc"`
+	Squat1To2Kg                *float64 `json:"squat1To2Kg"`
+	Squat2To3Kg                *float64 `json:"squat2To3Kg"`
+	Bench1To2Kg                *float64 `json:"bench1To2Kg"`
+	Bench2To3Kg                *float64 `json:"bench2To3Kg"`
+	Deadlift1To2Kg             *float64 `json:"deadlift1To2Kg"`
+	Deadlift2To3Kg             *float64 `json:"deadlift2To3Kg"`
+}
+
+// maxChunk is the most values bound in one IN list.
+const maxChunk = 500
+
+// inChunks calls fn for consecutive chunks of values, passing the chunk as
+// query arguments and a matching list of placeholders.
+func inChunks(values []string, fn func(chunk []interface{}, placeholders string) error) error {
+	for start := 0; start < len(values); start += maxChunk {
+		end := start + maxChunk
+		if end > len(values) {
+			end = len(values)
+		}
+		chunk := make([]interface{}, 0, end-start)
+		for _, v := range values[start:end] {
+			chunk = append(chunk, v)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+		if err := fn(chunk, placeholders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const entryColumns = `r.ID, r.Name, r.Sex, r.Event, r.Equipment, r.Age, r.AgeClass, r.Division,
+	r.BodyweightKg, r.WeightClassKg, r.Squat1Kg, r.Squat2Kg, r.Squat3Kg, r.Best3SquatKg,
+	r.Bench1Kg, r.Bench2Kg, r.Bench3Kg, r.Best3BenchKg, r.Deadlift1Kg, r.Deadlift2Kg, r.Deadlift3Kg,
+	r.Best3DeadliftKg, r.TotalKg, r.Place, r.Dots, r.Wilks, r.Glossbrenner, r.Goodlift, r.Tested,
+	r.Country, r.Federation, r.Date, r.MeetCountry, r.MeetState, r.MeetTown, r.MeetName`
+
+// queryEntries runs a query selecting entryColumns and passes each entry to fn.
+func queryEntries(ctx context.Context, db *sql.DB, query string, args []interface{}, fn func(Entry)) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("querying entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(
+			&e.ID, &e.Name, &e.Sex, &e.Event, &e.Equipment, &e.Age, &e.AgeClass, &e.Division,
+			&e.BodyweightKg, &e.WeightClassKg, &e.Squat1Kg, &e.Squat2Kg, &e.Squat3Kg, &e.Best3SquatKg,
+			&e.Bench1Kg, &e.Bench2Kg, &e.Bench3Kg, &e.Best3BenchKg, &e.Deadlift1Kg, &e.Deadlift2Kg, &e.Deadlift3Kg,
+			&e.Best3DeadliftKg, &e.TotalKg, &e.Place, &e.Dots, &e.Wilks, &e.Glossbrenner, &e.Goodlift, &e.Tested,
+			&e.Country, &e.Federation, &e.Date, &e.MeetCountry, &e.MeetState, &e.MeetTown, &e.MeetName,
+		); err != nil {
+			return fmt.Errorf("scanning entry: %w", err)
+		}
+		fn(e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating over entry rows: %w", err)
+	}
+	return nil
+}
+
+// GetEntries retrieves entries by ID, keyed by ID. Unknown IDs are left out.
+func GetEntries(ctx context.Context, db *sql.DB, ids []string) (map[string]Entry, error) {
+	entries := make(map[string]Entry, len(ids))
+	err := withTimeout(ctx, 5*time.Second, "getting entries", func(ctx context.Context) error {
+		return inChunks(ids, func(chunk []interface{}, placeholders string) error {
+			query := "SELECT " + entryColumns + " FROM records r WHERE r.ID IN (" + placeholders + ")"
+			return queryEntries(ctx, db, query, chunk, func(e Entry) {
+				entries[e.ID] = e
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetLifterEntries retrieves the entries of several lifters matching filter,
+// keyed by name and most recent first.
+func GetLifterEntries(ctx context.Context, db *sql.DB, names []string, filter EntryFilter) (map[string][]Entry, error) {
+	clause, args := filter.clause("r")
+	entries := make(map[string][]Entry, len(names))
+	err := withTimeout(ctx, 10*time.Second, "getting lifter entries", func(ctx context.Context) error {
+		return inChunks(names, func(chunk []interface{}, placeholders string) error {
+			query := "SELECT " + entryColumns + " FROM records r WHERE r.Name IN (" + placeholders + ")" + clause +
+				" ORDER BY r.Name, r.Date DESC"
+			return queryEntries(ctx, db, query, append(chunk, args...), func(e Entry) {
+				entries[e.Name] = append(entries[e.Name], e)
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetMeetEntries retrieves the entries of several meets, keyed by meet and
+// ordered by Dots, best first.
+func GetMeetEntries(ctx context.Context, db *sql.DB, meets []MeetKey) (map[MeetKey][]Entry, error) {
+	entries := make(map[MeetKey][]Entry, len(meets))
+	err := withTimeout(ctx, 10*time.Second, "getting meet entries", func(ctx context.Context) error {
+		// A meet has three key columns, so bind a third as many meets per query.
+		for start := 0; start < len(meets); start += maxChunk / 3 {
+			end := start + maxChunk/3
+			if end > len(meets) {
+				end = len(meets)
+			}
+
+			var conditions []string
+			var args []interface{}
+			for _, m := range meets[start:end] {
+				conditions = append(conditions, "(r.Federation = ? AND r.Date = ? AND r.MeetName = ?)")
+				args = append(args, m.Federation, m.Date, m.Name)
+			}
+			query := "SELECT " + entryColumns + " FROM records r WHERE " + strings.Join(conditions, " OR ") +
+				" ORDER BY r.Dots DESC, r.Name"
+			err := queryEntries(ctx, db, query, args, func(e Entry) {
+				key := e.Meet().Key()
+				entries[key] = append(entries[key], e)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetAttemptMetrics retrieves the attempt metrics of several entries, keyed
+// by entry ID. Entries without metrics are left out.
+func GetAttemptMetrics(ctx context.Context, db *sql.DB, ids []string) (map[string]AttemptMetrics, error) {
+	metrics := make(map[string]AttemptMetrics, len(ids))
+	err := withTimeout(ctx, 5*time.Second, "getting attempt metrics", func(ctx context.Context) error {
+		return inChunks(ids, func(chunk []interface{}, placeholders string) error {
+			query := `
+			SELECT ID,
+				SuccessfulSquatAttempts, SuccessfulBenchAttempts, SuccessfulDeadliftAttempts, TotalSuccessfulAttempts,
+				Squat1Perc, Squat2Perc, Squat3Perc, Bench1Perc, Bench2Perc, Bench3Perc,
+				Deadlift1Perc, Deadlift2Perc, Deadlift3Perc,
+				Squat1To2Kg, Squat2To3Kg, Bench1To2Kg, Bench2To3Kg, Deadlift1To2Kg, Deadlift2To3Kg
+			FROM lifter_metrics
+			WHERE ID IN (` + placeholders + `)`
+
+			rows, err := db.QueryContext(ctx, query, chunk...)
+			if err != nil {
+				return fmt.Errorf("querying attempt metrics: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var id string
+				var m AttemptMetrics
+				if err := rows.Scan(&id,
+					&m.SuccessfulSquatAttempts, &m.SuccessfulBenchAttempts, &m.SuccessfulDeadliftAttempts, &m.TotalSuccessfulAttempts,
+					&m.Squat1Perc, &m.Squat2Perc, &m.Squat3Perc, &m.Bench1Perc, &m.Bench2Perc, &m.Bench3Perc,
+					&m.Deadlift1Perc, &m.Deadlift2Perc, &m.Deadlift3Perc,
+					&m.Squat1To2Kg, &m.Squat2To3Kg, &m.Bench1To2Kg, &m.Bench2To3Kg, &m.Deadlift1To2Kg, &m.Deadlift2To3Kg,
+				); err != nil {
+					return fmt.Errorf("scanning attempt metrics: %w", err)
+				}
+				metrics[id] = m
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("iterating over attempt metrics rows: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
@@ -0,0 +1,406 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is the error returned when an entry query names an unknown
+// field or operator, has a value of the wrong type or carries a bad cursor.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// FieldType is the type of a queryable entry field. It decides which
+// operators apply and how values are parsed.
+type FieldType string
+
+const (
+	TextField   FieldType = "text"
+	NumberField FieldType = "number"
+	DateField   FieldType = "date"
+)
+
+// EntryField is a records column that entry queries may filter, sort and
+// select on.
+type EntryField struct {
+	Name   string    `json:"name"`
+	Type   FieldType `json:"type"`
+	column string
+}
+
+// Operators lists the comparison operators that apply to the field.
+func (f EntryField) Operators() []string {
+	if f.Type == TextField {
+		return []string{"eq", "ne", "in", "prefix", "contains"}
+	}
+	return []string{"eq", "ne", "lt", "lte", "gt", "gte", "in"}
+}
+
+// EntryFields is the whitelist of fields entry queries may use, in the order
+// they are returned when a query does not select fields.
+var EntryFields = []EntryField{
+	{"id", TextField, "ID"},
+	{"name", TextField, "Name"},
+	{"sex", TextField, "Sex"},
+	{"event", TextField, "Event"},
+	{"equipment", TextField, "Equipment"},
+	{"age", NumberField, "Age"},
+	{"ageClass", TextField, "AgeClass"},
+	{"birthYearClass", TextField, "BirthYearClass"},
+	{"division", TextField, "Division"},
+	{"bodyweightKg", NumberField, "BodyweightKg"},
+	{"weightClassKg", TextField, "WeightClassKg"},
+	{"squat1Kg", NumberField, "Squat1Kg"},
+	{"squat2Kg", NumberField, "Squat2Kg"},
+	{"squat3Kg", NumberField, "Squat3Kg"},
+	{"best3SquatKg", NumberField, "Best3SquatKg"},
+	{"bench1Kg", NumberField, "Bench1Kg"},
+	{"bench2Kg", NumberField, "Bench2Kg"},
+	{"bench3Kg", NumberField, "Bench3Kg"},
+	{"best3BenchKg", NumberField, "Best3BenchKg"},
+	{"deadlift1Kg", NumberField, "Deadlift1Kg"},
+	{"deadlift2Kg", NumberField, "Deadlift2Kg"},
+	{"deadlift3Kg", NumberField, "Deadlift3Kg"},
+	{"best3DeadliftKg", NumberField, "Best3DeadliftKg"},
+	{"totalKg", NumberField, "TotalKg"},
+	{"place", TextField, "Place"},
+	{"dots", NumberField, "Dots"},
+	{"wilks", NumberField, "Wilks"},
+	{"glossbrenner", NumberField, "Glossbrenner"},
+	{"goodlift", NumberField, "Goodlift"},
+	{"tested", TextField, "Tested"},
+	{"country", TextField, "Country"},
+	{"state", TextField, "State"},
+	{"federation", TextField, "Federation"},
+	{"parentFederation", TextField, "ParentFederation"},
+	{"date", DateField, "Date"},
+	{"meetCountry", TextField, "MeetCountry"},
+	{"meetState", TextField, "MeetState"},
+	{"meetTown", TextField, "MeetTown"},
+	{"meetName", TextField, "MeetName"},
+}
+
+func lookupEntryField(name string) (EntryField, error) {
+	for _, f := range EntryFields {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return EntryField{}, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+}
+
+// Condition compares a field with a value. Op is one of the field's
+// Operators; for "in" the value is a comma-separated list.
+type Condition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// SortKey orders entries by a field.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a comma-separated list of fields, each optionally prefixed
+// with "-" for descending order, e.g. "-dots,name".
+func ParseSort(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := SortKey{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, err := lookupEntryField(key.Field); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// EntryQuery selects entries from the records table. All conditions must
+// hold. Entries are ordered by Sort and then by id, which makes the order
+// total so that Cursor, the NextCursor of a previous page, resumes exactly
+// after that page.
+type EntryQuery struct {
+	Fields     []string
+	Conditions []Condition
+	Sort       []SortKey
+	Limit      int
+	Cursor     string
+}
+
+// EntryPage is one page of an entry query. Rows are keyed by field name and
+// NextCursor is empty on the last page.
+type EntryPage struct {
+	Fields     []string                 `json:"fields"`
+	Rows       []map[string]interface{} `json:"rows"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+}
+
+// QueryEntries runs q. Field names, operators and sort keys are checked
+// against EntryFields and only ever reach the SQL as whitelisted column
+// names; every value is passed as a parameter. Invalid queries return an
+// error wrapping ErrInvalidQuery.
+func QueryEntries(ctx context.Context, db *sql.DB, q EntryQuery) (EntryPage, error) {
+	selected, err := selectedFields(q.Fields)
+	if err != nil {
+		return EntryPage{}, err
+	}
+
+	var keys []EntryField
+	var desc []bool
+	for _, s := range q.Sort {
+		f, err := lookupEntryField(s.Field)
+		if err != nil {
+			return EntryPage{}, err
+		}
+		keys = append(keys, f)
+		desc = append(desc, s.Desc)
+	}
+	keys = append(keys, EntryFields[0])
+	desc = append(desc, false)
+
+	var where []string
+	var args []interface{}
+	for _, cond := range q.Conditions {
+		clause, condArgs, err := conditionSQL(cond)
+		if err != nil {
+			return EntryPage{}, err
+		}
+		where = append(where, clause)
+		args = append(args, condArgs...)
+	}
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor, keys)
+		if err != nil {
+			return EntryPage{}, err
+		}
+		clause, cursorArgs := keysetSQL(keys, desc, after)
+		where = append(where, clause)
+		args = append(args, cursorArgs...)
+	}
+
+	var columns, order []string
+	for _, f := range selected {
+		columns = append(columns, f.column)
+	}
+	for i, f := range keys {
+		columns = append(columns, f.column)
+		if desc[i] {
+			order = append(order, f.column+" DESC")
+		} else {
+			order = append(order, f.column)
+		}
+	}
+
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM records"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + strings.Join(order, ", ") + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	page := EntryPage{Rows: []map[string]interface{}{}}
+	for _, f := range selected {
+		page.Fields = append(page.Fields, f.Name)
+	}
+
+	scanned := append(append([]EntryField{}, selected...), keys...)
+	var last []interface{}
+	err = withTimeout(ctx, 10*time.Second, "querying entries", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("querying entries: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if len(page.Rows) == q.Limit {
+				page.NextCursor, err = encodeCursor(last)
+				return err
+			}
+
+			values := make([]interface{}, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i, f := range scanned {
+				if f.Type == NumberField {
+					dest[i] = new(float64)
+				} else {
+					dest[i] = new(string)
+				}
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return fmt.Errorf("scanning entry: %w", err)
+			}
+			for i := range dest {
+				switch v := dest[i].(type) {
+				case *float64:
+					values[i] = *v
+				case *string:
+					values[i] = *v
+				}
+			}
+
+			row := make(map[string]interface{}, len(selected))
+			for i, f := range selected {
+				row[f.Name] = values[i]
+			}
+			page.Rows = append(page.Rows, row)
+			last = values[len(selected):]
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over entry rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return EntryPage{}, err
+	}
+
+	return page, nil
+}
+
+// selectedFields resolves the requested field names, defaulting to all.
+func selectedFields(names []string) ([]EntryField, error) {
+	if len(names) == 0 {
+		return EntryFields, nil
+	}
+
+	var fields []EntryField
+	for _, name := range names {
+		f, err := lookupEntryField(name)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// conditionSQL returns the WHERE clause and arguments for cond.
+func conditionSQL(cond Condition) (string, []interface{}, error) {
+	f, err := lookupEntryField(cond.Field)
+	if err != nil {
+		return "", nil, err
+	}
+	if !slices.Contains(f.Operators(), cond.Op) {
+		return "", nil, fmt.Errorf("%w: operator %q does not apply to %s field %q; use one of %s",
+			ErrInvalidQuery, cond.Op, f.Type, f.Name, strings.Join(f.Operators(), ", "))
+	}
+
+	if cond.Op == "in" {
+		var args []interface{}
+		for _, v := range strings.Split(cond.Value, ",") {
+			arg, err := parseFieldValue(f, v)
+			if err != nil {
+				return "", nil, err
+			}
+			args = append(args, arg)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+		return f.column + " IN (" + placeholders + ")", args, nil
+	}
+
+	arg, err := parseFieldValue(f, cond.Value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch cond.Op {
+	case "prefix":
+		return f.column + ` LIKE ? ESCAPE '\'`, []interface{}{escapeLike(cond.Value) + "%"}, nil
+	case "contains":
+		return f.column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(cond.Value) + "%"}, nil
+	}
+
+	operators := map[string]string{"eq": "=", "ne": "!=", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="}
+	return f.column + " " + operators[cond.Op] + " ?", []interface{}{arg}, nil
+}
+
+// parseFieldValue converts a query string value to the field's type.
+func parseFieldValue(f EntryField, value string) (interface{}, error) {
+	switch f.Type {
+	case NumberField:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a number, got %q", ErrInvalidQuery, f.Name, value)
+		}
+		return n, nil
+	case DateField:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("%w: %s must be a date like 2024-03-01, got %q", ErrInvalidQuery, f.Name, value)
+		}
+	}
+	return value, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// keysetSQL returns a clause matching the rows that sort after the row whose
+// sort key values are after, e.g. for (dots DESC, id):
+// (Dots < ?) OR (Dots = ? AND ID > ?).
+func keysetSQL(keys []EntryField, desc []bool, after []interface{}) (string, []interface{}) {
+	var terms []string
+	var args []interface{}
+	for i := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].column+" = ?")
+			args = append(args, after[j])
+		}
+		op := ">"
+		if desc[i] {
+			op = "<"
+		}
+		parts = append(parts, keys[i].column+" "+op+" ?")
+		args = append(args, after[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// encodeCursor packs the sort key values of the last row of a page.
+func encodeCursor(values []interface{}) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("encoding cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor unpacks a cursor and checks it matches the sort keys.
+func decodeCursor(cursor string, keys []EntryField) ([]interface{}, error) {
+	invalid := fmt.Errorf("%w: cursor does not belong to this query", ErrInvalidQuery)
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != len(keys) {
+		return nil, invalid
+	}
+	for i, f := range keys {
+		_, isNumber := values[i].(float64)
+		_, isString := values[i].(string)
+		if (f.Type == NumberField && !isNumber) || (f.Type != NumberField && !isString) {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
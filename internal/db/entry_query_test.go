@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestQueryEntries tests typed filters, multi-field sorting and cursor
+// pagination over ties.
+func TestQueryEntries(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{Name: "Anna Berg", Sex: "F", Equipment: "Raw", BodyweightKg: 62, Country: "Denmark", Dots: 400, Date: "2022-03-01"},
+		{Name: "Bo Dahl", Sex: "M", Equipment: "Raw", BodyweightKg: 82, Country: "Denmark", Dots: 450, Date: "2023-05-01"},
+		{Name: "Carl Ek", Sex: "M", Equipment: "Raw", BodyweightKg: 80, Country: "Denmark", Dots: 450, Date: "2024-06-01"},
+		{Name: "Dan Fors", Sex: "M", Equipment: "Raw", BodyweightKg: 81, Country: "Denmark", Dots: 420, Date: "2024-07-01"},
+		{Name: "Erik 100%", Sex: "M", Equipment: "Single-ply", BodyweightKg: 82, Country: "Sweden", Dots: 500, Date: "2024-08-01"},
+	})
+	ctx := context.Background()
+
+	t.Run("Filters and sort", func(t *testing.T) {
+		page, err := QueryEntries(ctx, database, EntryQuery{
+			Fields: []string{"name", "dots"},
+			Conditions: []Condition{
+				{Field: "weightClassKg", Op: "eq", Value: "-83"},
+				{Field: "equipment", Op: "in", Value: "Raw,Wraps"},
+				{Field: "date", Op: "gte", Value: "2023-01-01"},
+			},
+			Sort:  []SortKey{{Field: "dots", Desc: true}, {Field: "name"}},
+			Limit: 10,
+		})
+		if err != nil {
+			t.Fatalf("QueryEntries failed: %v", err)
+		}
+
+		var names []string
+		for _, row := range page.Rows {
+			names = append(names, row["name"].(string))
+		}
+		if want := []string{"Bo Dahl", "Carl Ek", "Dan Fors"}; !equalStrings(names, want) {
+			t.Errorf("Got %v, want %v", names, want)
+		}
+		if len(page.Rows) == 0 || page.Rows[0]["dots"] != 450.0 || page.NextCursor != "" {
+			t.Errorf("Unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("Cursor pagination", func(t *testing.T) {
+		q := EntryQuery{Fields: []string{"name"}, Sort: []SortKey{{Field: "dots", Desc: true}}, Limit: 2}
+		var names []string
+		for pages := 0; pages < 5; pages++ {
+			page, err := QueryEntries(ctx, database, q)
+			if err != nil {
+				t.Fatalf("QueryEntries failed: %v", err)
+			}
+			for _, row := range page.Rows {
+				names = append(names, row["name"].(string))
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+
+		if len(names) != 5 || names[0] != "Erik 100%" || names[3] != "Dan Fors" || names[4] != "Anna Berg" {
+			t.Errorf("Unexpected order across pages: %v", names)
+		}
+		seen := map[string]bool{}
+		for _, name := range names {
+			if seen[name] {
+				t.Errorf("%s returned twice: %v", name, names)
+			}
+			seen[name] = true
+		}
+	})
+
+	t.Run("Like patterns are escaped", func(t *testing.T) {
+		page, err := QueryEntries(ctx, database, EntryQuery{
+			Conditions: []Condition{{Field: "name", Op: "contains", Value: "0%"}},
+			Limit:      10,
+		})
+		if err != nil {
+			t.Fatalf("QueryEntries failed: %v", err)
+		}
+		if len(page.Rows) != 1 || page.Rows[0]["name"] != "Erik 100%" {
+			t.Errorf("Unexpected rows: %v", page.Rows)
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		q    EntryQuery
+	}{
+		{"Unknown field", EntryQuery{Conditions: []Condition{{Field: "Name; DROP TABLE records", Op: "eq", Value: "x"}}}},
+		{"Operator for wrong type", EntryQuery{Conditions: []Condition{{Field: "name", Op: "gt", Value: "x"}}}},
+		{"Value of wrong type", EntryQuery{Conditions: []Condition{{Field: "dots", Op: "gt", Value: "lots"}}}},
+		{"Bad date", EntryQuery{Conditions: []Condition{{Field: "date", Op: "gt", Value: "2024"}}}},
+		{"Unknown sort field", EntryQuery{Sort: []SortKey{{Field: "rowid"}}}},
+		{"Cursor of another sort", EntryQuery{Sort: []SortKey{{Field: "name"}}, Cursor: "WzQ1MCwiYSJd"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.q.Limit = 10
+			if _, err := QueryEntries(ctx, database, tc.q); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Expected ErrInvalidQuery, got %v", err)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,17 +4,40 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 )
 
-// MetricCalculator defines the interface for metric calculation operations
+// MetricCalculator defines the interface for metric calculation operations.
+// Calculators declare the tables they read and write so that the
+// FeatureCalculator can order them and tell which depend on which.
 type MetricCalculator interface {
+	// Name identifies the calculator in its runs and for Recompute.
+	Name() string
+	// Inputs are the tables the calculator reads.
+	Inputs() []string
+	// Outputs are the tables the calculator writes.
+	Outputs() []string
 	Calculate(ctx context.Context, tx *sql.Tx) error
 }
 
 // FeatureCalculator manages the calculation of all metrics
 type FeatureCalculator struct {
 	calculators []MetricCalculator
+
+	// Parallelism is how many calculators may run at once. With 1, the
+	// default, they run one after the other in a single transaction, so a
+	// failure leaves the metrics as they were. With more, independent
+	// calculators run concurrently, each in its own transaction; this
+	// only pays off on a backend that allows concurrent writers, which
+	// SQLite does not.
+	Parallelism int
+	// Timeout bounds each calculator.
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	runs []CalculatorRun
 }
 
 // NewFeatureCalculator creates a new FeatureCalculator with the default set of calculators
@@ -22,13 +45,19 @@ func NewFeatureCalculator() *FeatureCalculator {
 	return &FeatureCalculator{
 		calculators: []MetricCalculator{
 			&MaxSuccessfulAttempts{},
+			&Attempts{},
 			&SuccessfulAttempts{},
 			&LiftDifferences{},
+			&AttemptStrategyCounts{},
 			&AggregatedMetrics{},
 			&WeightClassDistribution{},
 			&AgeGroupPerformance{},
 			&PerformanceTrends{},
+			&ProgressionPriors{},
+			&PeerRanks{},
 		},
+		Parallelism: 1,
+		Timeout:     5 * time.Minute,
 	}
 }
 
@@ -37,33 +66,59 @@ func (fc *FeatureCalculator) AddCalculator(calc MetricCalculator) {
 	fc.calculators = append(fc.calculators, calc)
 }
 
-// UpdateAllMetrics runs all registered calculators
+// UpdateAllMetrics runs all registered calculators in dependency order
 func (fc *FeatureCalculator) UpdateAllMetrics(ctx context.Context, db *sql.DB) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute) // Adjust timeout as needed
-	defer cancel()
-
-	tx, err := db.BeginTx(ctx, nil)
+	g, err := newCalculatorGraph(fc.calculators)
 	if err != nil {
-		return fmt.Errorf("beginning transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	return fc.run(ctx, db, g, g.order)
+}
 
-	for _, calc := range fc.calculators {
-		if err := calc.Calculate(ctx, tx); err != nil {
-			return err
-		}
+// Recompute runs the named calculator and every calculator that depends on
+// its outputs, directly or not, leaving the other metrics as they are.
+func (fc *FeatureCalculator) Recompute(ctx context.Context, db *sql.DB, name string) error {
+	g, err := newCalculatorGraph(fc.calculators)
+	if err != nil {
+		return err
 	}
+	selected, err := g.withDependents(name)
+	if err != nil {
+		return err
+	}
+	return fc.run(ctx, db, g, selected)
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("committing transaction: %w", err)
+// Order returns the names of the calculators in the order they run.
+func (fc *FeatureCalculator) Order() ([]string, error) {
+	g, err := newCalculatorGraph(fc.calculators)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(g.order))
+	for i, c := range g.order {
+		names[i] = g.calculators[c].Name()
 	}
+	return names, nil
+}
 
-	return nil
+// Runs returns the runs of the calculators of the last UpdateAllMetrics or
+// Recompute, in the order they finished.
+func (fc *FeatureCalculator) Runs() []CalculatorRun {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return append([]CalculatorRun(nil), fc.runs...)
 }
 
 // MaxSuccessfulAttempts fetches the maximum successful attempts
 type MaxSuccessfulAttempts struct{}
 
+func (m *MaxSuccessfulAttempts) Name() string { return "max_successful_attempts" }
+
+func (m *MaxSuccessfulAttempts) Inputs() []string { return []string{"records"} }
+
+func (m *MaxSuccessfulAttempts) Outputs() []string { return []string{"max_lifts"} }
+
 func (m *MaxSuccessfulAttempts) Calculate(ctx context.Context, tx *sql.Tx) error {
 	query := `
 		INSERT OR REPLACE INTO max_lifts (
@@ -86,12 +141,76 @@ func (m *MaxSuccessfulAttempts) Calculate(ctx context.Context, tx *sql.Tx) error
 	return nil
 }
 
+// Lifts are the values of attempts.Lift, named like the columns of records.
+var Lifts = []string{"Squat", "Bench", "Deadlift"}
+
+// Outcomes of an attempt.
+const (
+	AttemptMade   = "made"
+	AttemptMissed = "missed"
+)
+
+// Attempts derives the attempts table from the attempt columns of records,
+// with a row for every attempt taken, fourth attempts included. An attempt
+// is taken if its column is not 0; a negative weight is a miss.
+//
+// The jump of an attempt is from the attempt numbered before it and is null
+// if the lifter skipped that one. An attempt is a retry if it repeats the
+// weight of a missed previous attempt.
+type Attempts struct{}
+
+func (a *Attempts) Name() string { return "attempts" }
+
+func (a *Attempts) Inputs() []string { return []string{"records"} }
+
+func (a *Attempts) Outputs() []string { return []string{"attempts"} }
+
+func (a *Attempts) Calculate(ctx context.Context, tx *sql.Tx) error {
+	var taken []string
+	for _, lift := range Lifts {
+		for attempt := 1; attempt <= 4; attempt++ {
+			column := fmt.Sprintf("%s%dKg", lift, attempt)
+			taken = append(taken, fmt.Sprintf(
+				"SELECT ID AS EntryID, '%s' AS Lift, %d AS Attempt, %s AS Value FROM records WHERE %s != 0",
+				lift, attempt, column, column))
+		}
+	}
+
+	query := `
+		INSERT INTO attempts (EntryID, Lift, Attempt, Kg, Outcome, JumpKg, JumpPerc, Retry)
+		SELECT
+			EntryID, Lift, Attempt, ABS(Value),
+			CASE WHEN Value > 0 THEN '` + AttemptMade + `' ELSE '` + AttemptMissed + `' END,
+			ABS(Value) - ABS(Previous),
+			(ABS(Value) - ABS(Previous)) / ABS(Previous) * 100,
+			COALESCE(Previous < 0 AND ABS(Value) = ABS(Previous), 0)
+		FROM (
+			SELECT *, CASE WHEN LAG(Attempt) OVER w = Attempt - 1 THEN LAG(Value) OVER w END AS Previous
+			FROM (` + strings.Join(taken, "\n\t\t\tUNION ALL ") + `)
+			WINDOW w AS (PARTITION BY EntryID, Lift ORDER BY Attempt)
+		)
+	`
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM attempts`); err != nil {
+		return fmt.Errorf("clearing attempts: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("calculating attempts: %w", err)
+	}
+	return nil
+}
+
 // SuccessfulAttempts calculates the number of successful attempts
 type SuccessfulAttempts struct{}
 
+func (s *SuccessfulAttempts) Name() string { return "successful_attempts" }
+
+func (s *SuccessfulAttempts) Inputs() []string { return []string{"records", "attempts"} }
+
+func (s *SuccessfulAttempts) Outputs() []string { return []string{"lifter_metrics"} }
+
 func (s *SuccessfulAttempts) Calculate(ctx context.Context, tx *sql.Tx) error {
-	// If the attempt is negative, it means the lifter failed to complete the lift, or did not attempt it.
-	// If the attempt is 0, it means the lifter has crossed their attempt.
+	// Fourth attempts do not count towards the total, so they are left out
 	query := `
 		INSERT OR REPLACE INTO lifter_metrics (
 			ID, Name, Date, Equipment,
@@ -99,27 +218,22 @@ func (s *SuccessfulAttempts) Calculate(ctx context.Context, tx *sql.Tx) error {
 			SuccessfulBenchAttempts,
 			SuccessfulDeadliftAttempts,
 			TotalSuccessfulAttempts
-		) SELECT 
-			ID, Name, Date, Equipment,
-			(CASE WHEN Squat1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Squat2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Squat3Kg > 0 THEN 1 ELSE 0 END) AS SuccessfulSquatAttempts,
-			(CASE WHEN Bench1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Bench2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Bench3Kg > 0 THEN 1 ELSE 0 END) AS SuccessfulBenchAttempts,
-			(CASE WHEN Deadlift1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Deadlift2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Deadlift3Kg > 0 THEN 1 ELSE 0 END) AS SuccessfulDeadliftAttempts,
-			(CASE WHEN Squat1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Squat2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Squat3Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Bench1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Bench2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Bench3Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Deadlift1Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Deadlift2Kg > 0 THEN 1 ELSE 0 END) +
-			(CASE WHEN Deadlift3Kg > 0 THEN 1 ELSE 0 END) AS TotalSuccessfulAttempts
-		FROM records
+		) SELECT
+			r.ID, r.Name, r.Date, r.Equipment,
+			COALESCE(a.Squat, 0),
+			COALESCE(a.Bench, 0),
+			COALESCE(a.Deadlift, 0),
+			COALESCE(a.Squat + a.Bench + a.Deadlift, 0)
+		FROM records r
+		LEFT JOIN (
+			SELECT EntryID,
+				SUM(Lift = 'Squat') AS Squat,
+				SUM(Lift = 'Bench') AS Bench,
+				SUM(Lift = 'Deadlift') AS Deadlift
+			FROM attempts
+			WHERE Outcome = '` + AttemptMade + `' AND Attempt <= 3
+			GROUP BY EntryID
+		) a ON a.EntryID = r.ID
 	`
 
 	_, err := tx.ExecContext(ctx, query)
@@ -129,32 +243,47 @@ func (s *SuccessfulAttempts) Calculate(ctx context.Context, tx *sql.Tx) error {
 	return nil
 }
 
-// LiftDifferences calculates the differences between lifts
+// LiftDifferences calculates the differences between lifts: each of the
+// first three attempts as a percentage of the heaviest of them, and the
+// jumps from the first to the second and from the second to the third.
+// They are null where the attempts are missing.
 type LiftDifferences struct{}
 
+func (l *LiftDifferences) Name() string { return "lift_differences" }
+
+func (l *LiftDifferences) Inputs() []string { return []string{"attempts", "lifter_metrics"} }
+
+func (l *LiftDifferences) Outputs() []string { return []string{"lifter_metrics"} }
+
 func (l *LiftDifferences) Calculate(ctx context.Context, tx *sql.Tx) error {
+	// One column per lift and attempt, pivoted from the rows of attempts
+	var set, pivot []string
+	for _, lift := range Lifts {
+		for attempt := 1; attempt <= 3; attempt++ {
+			column := fmt.Sprintf("%s%dPerc", lift, attempt)
+			set = append(set, fmt.Sprintf("%[1]s = d.%[1]s", column))
+			pivot = append(pivot, fmt.Sprintf("MAX(CASE WHEN Lift = '%s' AND Attempt = %d THEN Perc END) AS %s", lift, attempt, column))
+		}
+		for attempt := 2; attempt <= 3; attempt++ {
+			column := fmt.Sprintf("%s%dTo%dKg", lift, attempt-1, attempt)
+			set = append(set, fmt.Sprintf("%[1]s = d.%[1]s", column))
+			pivot = append(pivot, fmt.Sprintf("MAX(CASE WHEN Lift = '%s' AND Attempt = %d THEN JumpKg END) AS %s", lift, attempt, column))
+		}
+	}
+
 	query := `
 		UPDATE lifter_metrics
-		SET 
-			Squat1Perc = CASE WHEN MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) = 0 THEN 0 ELSE ABS(r.Squat1Kg) / MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) * 100 END,
-			Squat2Perc = CASE WHEN MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) = 0 THEN 0 ELSE ABS(r.Squat2Kg) / MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) * 100 END,
-			Squat3Perc = CASE WHEN MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) = 0 THEN 0 ELSE ABS(r.Squat3Kg) / MAX(ABS(r.Squat1Kg), ABS(r.Squat2Kg), ABS(r.Squat3Kg)) * 100 END,
-			Bench1Perc = CASE WHEN MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) = 0 THEN 0 ELSE ABS(r.Bench1Kg) / MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) * 100 END,
-			Bench2Perc = CASE WHEN MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) = 0 THEN 0 ELSE ABS(r.Bench2Kg) / MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) * 100 END,
-			Bench3Perc = CASE WHEN MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) = 0 THEN 0 ELSE ABS(r.Bench3Kg) / MAX(ABS(r.Bench1Kg), ABS(r.Bench2Kg), ABS(r.Bench3Kg)) * 100 END,
-			Deadlift1Perc = CASE WHEN MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) = 0 THEN 0 ELSE ABS(r.Deadlift1Kg) / MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) * 100 END,
-			Deadlift2Perc = CASE WHEN MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) = 0 THEN 0 ELSE ABS(r.Deadlift2Kg) / MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) * 100 END,
-			Deadlift3Perc = CASE WHEN MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) = 0 THEN 0 ELSE ABS(r.Deadlift3Kg) / MAX(ABS(r.Deadlift1Kg), ABS(r.Deadlift2Kg), ABS(r.Deadlift3Kg)) * 100 END,
-			Squat1To2Kg = ABS(r.Squat2Kg) - ABS(r.Squat1Kg),
-			Squat2To3Kg = ABS(r.Squat3Kg) - ABS(r.Squat2Kg),
-			Bench1To2Kg = ABS(r.Bench2Kg) - ABS(r.Bench1Kg),
-			Bench2To3Kg = ABS(r.Bench3Kg) - ABS(r.Bench2Kg),
-			Deadlift1To2Kg = ABS(r.Deadlift2Kg) - ABS(r.Deadlift1Kg),
-			Deadlift2To3Kg = ABS(r.Deadlift3Kg) - ABS(r.Deadlift2Kg)
-		FROM records r
-		WHERE lifter_metrics.ID = r.ID 
-		  AND lifter_metrics.Date = r.Date 
-		  AND lifter_metrics.Equipment = r.Equipment
+		SET ` + strings.Join(set, ", ") + `
+		FROM (
+			SELECT EntryID, ` + strings.Join(pivot, ",\n\t\t\t\t") + `
+			FROM (
+				SELECT EntryID, Lift, Attempt, JumpKg, Kg * 100 / MAX(Kg) OVER (PARTITION BY EntryID, Lift) AS Perc
+				FROM attempts
+				WHERE Attempt <= 3
+			)
+			GROUP BY EntryID
+		) d
+		WHERE lifter_metrics.ID = d.EntryID
 	`
 
 	_, err := tx.ExecContext(ctx, query)
@@ -167,6 +296,14 @@ func (l *LiftDifferences) Calculate(ctx context.Context, tx *sql.Tx) error {
 // AggregatedMetrics calculates aggregated metrics
 type AggregatedMetrics struct{}
 
+func (a *AggregatedMetrics) Name() string { return "aggregated_metrics" }
+
+func (a *AggregatedMetrics) Inputs() []string { return []string{"records", "lifter_metrics"} }
+
+func (a *AggregatedMetrics) Outputs() []string {
+	return []string{"aggregated_metrics_sbd", "aggregated_metrics_bench"}
+}
+
 func (a *AggregatedMetrics) Calculate(ctx context.Context, tx *sql.Tx) error {
 	if err := a.calculateSBD(ctx, tx); err != nil {
 		return err
@@ -249,17 +386,24 @@ func (a *AggregatedMetrics) calculateBench(ctx context.Context, tx *sql.Tx) erro
 	return nil
 }
 
-// WeightClassDistribution calculates the distribution of lifters across weight classes
+// WeightClassDistribution calculates the distribution of lifters across weight classes per year and equipment
 type WeightClassDistribution struct{}
 
+func (w *WeightClassDistribution) Name() string { return "weight_class_distribution" }
+
+func (w *WeightClassDistribution) Inputs() []string { return []string{"records"} }
+
+func (w *WeightClassDistribution) Outputs() []string { return []string{"weight_class_distribution"} }
+
 func (w *WeightClassDistribution) Calculate(ctx context.Context, tx *sql.Tx) error {
 	query := `
 		INSERT OR REPLACE INTO weight_class_distribution (
-			WeightClass, Sex, Count
+			Year, WeightClass, Sex, Equipment, Count
 		) SELECT 
-			WeightClassKg, Sex, COUNT(DISTINCT Name) as Count
+			CAST(strftime('%Y', Date) AS INTEGER) as Year,
+			WeightClassKg, Sex, Equipment, COUNT(DISTINCT Name) as Count
 		FROM records
-		GROUP BY WeightClassKg, Sex
+		GROUP BY Year, WeightClassKg, Sex, Equipment
 	`
 
 	_, err := tx.ExecContext(ctx, query)
@@ -269,23 +413,64 @@ func (w *WeightClassDistribution) Calculate(ctx context.Context, tx *sql.Tx) err
 	return nil
 }
 
-// AgeGroupPerformance calculates average performance metrics for different age groups
+// liftDistributionColumns lists the median and percentile columns that
+// age_group_performance and performance_trends have for each lift, and
+// liftDistributionSelect computes them from records.
+var liftDistributionColumns, liftDistributionSelect = liftDistribution()
+
+// liftDistribution builds liftDistributionColumns and
+// liftDistributionSelect. Lifts that were not
+// successful, zero or negative, are left out so that they do not drag the
+// percentiles down, as they are from the averages.
+func liftDistribution() (columns, expressions string) {
+	lifts := []struct{ name, column string }{
+		{"Squat", "Best3SquatKg"}, {"Bench", "Best3BenchKg"}, {"Deadlift", "Best3DeadliftKg"}, {"Total", "TotalKg"},
+	}
+	quantiles := []struct {
+		name     string
+		fraction float64
+	}{
+		{"Median", 0.5}, {"P10", 0.1}, {"P25", 0.25}, {"P75", 0.75}, {"P90", 0.9},
+	}
+
+	var names, exprs []string
+	for _, lift := range lifts {
+		value := fmt.Sprintf("CASE WHEN %[1]s > 0 THEN %[1]s END", lift.column)
+		for _, q := range quantiles {
+			names = append(names, q.name+lift.name)
+			exprs = append(exprs, fmt.Sprintf("percentile_cont(%s, %g)", value, q.fraction))
+		}
+	}
+	return strings.Join(names, ", "), strings.Join(exprs, ",\n\t\t\t")
+}
+
+// AgeGroupPerformance calculates average, median and percentile performance metrics for different age groups per year and equipment
 type AgeGroupPerformance struct{}
 
+func (a *AgeGroupPerformance) Name() string { return "age_group_performance" }
+
+func (a *AgeGroupPerformance) Inputs() []string { return []string{"records"} }
+
+func (a *AgeGroupPerformance) Outputs() []string { return []string{"age_group_performance"} }
+
 func (a *AgeGroupPerformance) Calculate(ctx context.Context, tx *sql.Tx) error {
 	query := `
 		INSERT OR REPLACE INTO age_group_performance (
-			AgeClass, Sex, AvgSquat, AvgBench, AvgDeadlift, AvgTotal
+			Year, AgeClass, Sex, Equipment, AvgSquat, AvgBench, AvgDeadlift, AvgTotal,
+			` + liftDistributionColumns + `
 		) SELECT 
+			CAST(strftime('%Y', Date) AS INTEGER) as Year,
 			AgeClass,
 			Sex,
-			AVG(Best3SquatKg) as AvgSquat,
-			AVG(Best3BenchKg) as AvgBench,
-			AVG(Best3DeadliftKg) as AvgDeadlift,
-			AVG(TotalKg) as AvgTotal
+			Equipment,
+			AVG(CASE WHEN Best3SquatKg > 0 THEN Best3SquatKg END) as AvgSquat,
+			AVG(CASE WHEN Best3BenchKg > 0 THEN Best3BenchKg END) as AvgBench,
+			AVG(CASE WHEN Best3DeadliftKg > 0 THEN Best3DeadliftKg END) as AvgDeadlift,
+			AVG(CASE WHEN TotalKg > 0 THEN TotalKg END) as AvgTotal,
+			` + liftDistributionSelect + `
 		FROM records
 		WHERE AgeClass != ''
-		GROUP BY AgeClass, Sex
+		GROUP BY Year, AgeClass, Sex, Equipment
 	`
 
 	_, err := tx.ExecContext(ctx, query)
@@ -295,22 +480,32 @@ func (a *AgeGroupPerformance) Calculate(ctx context.Context, tx *sql.Tx) error {
 	return nil
 }
 
-// PerformanceTrends calculates year-over-year performance trends
+// PerformanceTrends calculates year-over-year performance trends per equipment,
+// with the median and percentiles of each lift next to its average
 type PerformanceTrends struct{}
 
+func (p *PerformanceTrends) Name() string { return "performance_trends" }
+
+func (p *PerformanceTrends) Inputs() []string { return []string{"records"} }
+
+func (p *PerformanceTrends) Outputs() []string { return []string{"performance_trends"} }
+
 func (p *PerformanceTrends) Calculate(ctx context.Context, tx *sql.Tx) error {
 	query := `
 		INSERT OR REPLACE INTO performance_trends (
-			Year, Sex, AvgSquat, AvgBench, AvgDeadlift, AvgTotal
+			Year, Sex, Equipment, AvgSquat, AvgBench, AvgDeadlift, AvgTotal,
+			` + liftDistributionColumns + `
 		) SELECT 
-			strftime('%Y', Date) as Year,
+			CAST(strftime('%Y', Date) AS INTEGER) as Year,
 			Sex,
-			AVG(Best3SquatKg) as AvgSquat,
-			AVG(Best3BenchKg) as AvgBench,
-			AVG(Best3DeadliftKg) as AvgDeadlift,
-			AVG(TotalKg) as AvgTotal
+			Equipment,
+			AVG(CASE WHEN Best3SquatKg > 0 THEN Best3SquatKg END) as AvgSquat,
+			AVG(CASE WHEN Best3BenchKg > 0 THEN Best3BenchKg END) as AvgBench,
+			AVG(CASE WHEN Best3DeadliftKg > 0 THEN Best3DeadliftKg END) as AvgDeadlift,
+			AVG(CASE WHEN TotalKg > 0 THEN TotalKg END) as AvgTotal,
+			` + liftDistributionSelect + `
 		FROM records
-		GROUP BY Year, Sex
+		GROUP BY Year, Sex, Equipment
 		ORDER BY Year
 	`
 
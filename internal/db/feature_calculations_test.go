@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestAttempts tests the attempts table and the metrics built on it: jumps
+// only between consecutive attempts, retries of missed weights, and fourth
+// attempts left out of the counts.
+func TestAttempts(t *testing.T) {
+	database := newTestDatabase(t, []*Record{{
+		Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Date: "2024-03-01",
+		Squat1Kg: 100, Squat2Kg: -110, Squat3Kg: 110, Squat4Kg: 112.5,
+		Bench1Kg: 60, Bench3Kg: -65,
+		Deadlift1Kg: 150, Deadlift2Kg: 160, Deadlift3Kg: -170,
+	}})
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := database.Query(`SELECT Lift, Attempt, Kg, Outcome, JumpKg, JumpPerc, Retry FROM attempts ORDER BY Lift, Attempt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type attempt struct {
+		Lift     string
+		Attempt  int
+		Kg       float64
+		Outcome  string
+		JumpKg   *float64
+		JumpPerc *float64
+		Retry    bool
+	}
+	var got []attempt
+	for rows.Next() {
+		var a attempt
+		if err := rows.Scan(&a.Lift, &a.Attempt, &a.Kg, &a.Outcome, &a.JumpKg, &a.JumpPerc, &a.Retry); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, a)
+	}
+	f := func(x float64) *float64 { return &x }
+	want := []attempt{
+		{"Bench", 1, 60, AttemptMade, nil, nil, false},
+		{"Bench", 3, 65, AttemptMissed, nil, nil, false},
+		{"Deadlift", 1, 150, AttemptMade, nil, nil, false},
+		{"Deadlift", 2, 160, AttemptMade, f(10), f(100.0 / 15), false},
+		{"Deadlift", 3, 170, AttemptMissed, f(10), f(6.25), false},
+		{"Squat", 1, 100, AttemptMade, nil, nil, false},
+		{"Squat", 2, 110, AttemptMissed, f(10), f(10), false},
+		{"Squat", 3, 110, AttemptMade, f(0), f(0), true},
+		{"Squat", 4, 112.5, AttemptMade, f(2.5), f(2.5 / 1.1), false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Errorf("row %d: %+v", i, got[i])
+		}
+	}
+
+	var id string
+	if err := database.QueryRow(`SELECT ID FROM records`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := GetAttemptMetrics(ctx, database, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics[id]
+	if m.SuccessfulSquatAttempts != 2 || m.SuccessfulBenchAttempts != 1 || m.TotalSuccessfulAttempts != 5 {
+		t.Errorf("successful attempts %+v", m)
+	}
+	if m.Bench2To3Kg != nil || m.Bench2Perc != nil || *m.Deadlift2To3Kg != 10 || *m.Squat1Perc != 100/1.1 {
+		t.Errorf("differences %+v", m)
+	}
+}
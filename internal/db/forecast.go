@@ -0,0 +1,377 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"liftmetrics/internal/forecast"
+)
+
+// MaxTrainingYear is the training age, in whole years since a lifter's
+// first meet, from which on progression priors are pooled.
+const MaxTrainingYear = 10
+
+// ForecastLifts are the lifts forecast, Lifts and the total.
+var ForecastLifts = append(append([]string(nil), Lifts...), "Total")
+
+// liftSeriesSQL selects every positive best lift and full power total of
+// records as rows of Name, Sex, Equipment, Event, Date, Lift and Kg.
+var liftSeriesSQL = func() string {
+	var parts []string
+	for _, lift := range Lifts {
+		parts = append(parts, fmt.Sprintf(`SELECT Name, Sex, Equipment, Event, Date, '%[1]s' AS Lift, Best3%[1]sKg AS Kg
+			FROM records WHERE Best3%[1]sKg > 0`, lift))
+	}
+	parts = append(parts, `SELECT Name, Sex, Equipment, Event, Date, 'Total' AS Lift, TotalKg AS Kg
+			FROM records WHERE TotalKg > 0 AND Event = 'SBD'`)
+	return strings.Join(parts, "\n\t\t\tUNION ALL\n\t\t\t")
+}()
+
+// liftPointsSQL selects the lift series matching where, a WHERE clause on
+// the columns of liftSeriesSQL, as rows of Name, Sex, Equipment, Date, Lift
+// and Kg with one row per meet. Lifters entered in several divisions of a
+// meet have a record per division, of which the heaviest lift counts.
+func liftPointsSQL(where string) string {
+	return `SELECT Name, MAX(Sex) AS Sex, Equipment, Date, Lift, MAX(Kg) AS Kg
+			FROM (` + liftSeriesSQL + `) s` + where + `
+			GROUP BY Name, Equipment, Lift, Date`
+}
+
+// ProgressionPriors computes how lifters progress at each lift from one meet
+// to the next in the same equipment, per sex, equipment and training age.
+// They are the population priors of forecasts.
+type ProgressionPriors struct{}
+
+func (p *ProgressionPriors) Name() string { return "progression_priors" }
+
+func (p *ProgressionPriors) Inputs() []string { return []string{"records"} }
+
+func (p *ProgressionPriors) Outputs() []string { return []string{"progression_priors"} }
+
+func (p *ProgressionPriors) Calculate(ctx context.Context, tx *sql.Tx) error {
+	query := `INSERT INTO progression_priors (Sex, Equipment, Lift, TrainingYear, Pairs, MedianRateKg, IqrChangeKg)` +
+		progressionPriorsSQL("", false)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM progression_priors`); err != nil {
+		return fmt.Errorf("clearing progression priors: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("calculating progression priors: %w", err)
+	}
+	return nil
+}
+
+// progressionPriorsSQL selects the progression priors as rows of Sex,
+// Equipment, Lift, TrainingYear, Pairs, MedianRateKg and IqrChangeKg from
+// the lift series matching where. With withoutLast, each lifter's last meet
+// at each lift in each equipment is left out.
+func progressionPriorsSQL(where string, withoutLast bool) string {
+	last := ""
+	if withoutLast {
+		last = "WHERE NextDate IS NOT NULL"
+	}
+	// Meets less than a month apart say more about the calendar than about
+	// progression, so they are not paired
+	return fmt.Sprintf(`
+		WITH lifts AS (
+			SELECT *, LEAD(Date) OVER (PARTITION BY Name, Equipment, Lift ORDER BY Date) AS NextDate
+			FROM (
+			%s
+			)
+		), series AS (
+			SELECT Sex, Equipment, Lift, Date, Kg,
+				LAG(Kg) OVER w AS PreviousKg,
+				LAG(Date) OVER w AS PreviousDate,
+				FIRST_VALUE(Date) OVER w AS FirstDate
+			FROM lifts
+			%s
+			WINDOW w AS (PARTITION BY Name, Equipment, Lift ORDER BY Date)
+		), pairs AS (
+			SELECT Sex, Equipment, Lift,
+				MIN(CAST((julianday(PreviousDate) - julianday(FirstDate)) / 365.25 AS INTEGER), %d) AS TrainingYear,
+				Kg - PreviousKg AS ChangeKg,
+				(Kg - PreviousKg) * 365.25 / (julianday(Date) - julianday(PreviousDate)) AS RateKg
+			FROM series
+			WHERE PreviousKg IS NOT NULL AND julianday(Date) - julianday(PreviousDate) >= 30
+		)
+		SELECT Sex, Equipment, Lift, TrainingYear, COUNT(*), median(RateKg), iqr(ChangeKg)
+		FROM pairs
+		GROUP BY Sex, Equipment, Lift, TrainingYear
+		ORDER BY Sex, Equipment, Lift, TrainingYear
+	`, liftPointsSQL(where), last, MaxTrainingYear)
+}
+
+// GetProgressionPriors retrieves the forecast priors of lifters of sex in
+// equipment, keyed by lift. Lifts without any are left out.
+func GetProgressionPriors(ctx context.Context, db *sql.DB, sex, equipment string) (map[string]forecast.Prior, error) {
+	query := `SELECT Sex, Equipment, Lift, TrainingYear, Pairs, MedianRateKg, IqrChangeKg
+		FROM progression_priors WHERE Sex = ? AND Equipment = ?
+		ORDER BY Lift, TrainingYear`
+	priors, err := readPriors(ctx, db, "querying progression priors", query, []interface{}{sex, equipment})
+	if err != nil {
+		return nil, err
+	}
+	if p := priors[[2]string{sex, equipment}]; p != nil {
+		return p, nil
+	}
+	return map[string]forecast.Prior{}, nil
+}
+
+// readPriors reads rows of progression priors, ordered by training year,
+// into forecast priors keyed by sex and equipment, then by lift.
+func readPriors(ctx context.Context, db *sql.DB, op, query string, args []interface{}) (map[[2]string]map[string]forecast.Prior, error) {
+	type spread struct{ pairs, weighted float64 }
+	priors := make(map[[2]string]map[string]forecast.Prior)
+	spreads := make(map[[2]string]map[string]spread)
+	err := queryAggregate(ctx, db, op, query, args, func(r *sql.Rows) error {
+		var group [2]string
+		var lift string
+		var year, pairs int
+		var rate, iqr sql.NullFloat64
+		if err := r.Scan(&group[0], &group[1], &lift, &year, &pairs, &rate, &iqr); err != nil {
+			return err
+		}
+		if priors[group] == nil {
+			priors[group] = make(map[string]forecast.Prior)
+			spreads[group] = make(map[string]spread)
+		}
+		p := priors[group][lift]
+		// Years without pairs take the rate of the year before
+		for len(p.RateKgPerYear) <= year {
+			var previous float64
+			if n := len(p.RateKgPerYear); n > 0 {
+				previous = p.RateKgPerYear[n-1]
+			}
+			p.RateKgPerYear = append(p.RateKgPerYear, previous)
+		}
+		p.RateKgPerYear[year] = rate.Float64
+		priors[group][lift] = p
+
+		s := spreads[group][lift]
+		spreads[group][lift] = spread{s.pairs + float64(pairs), s.weighted + float64(pairs)*iqr.Float64}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The interquartile range of a normal distribution is 1.349 standard
+	// deviations
+	for group, lifts := range spreads {
+		for lift, s := range lifts {
+			p := priors[group][lift]
+			p.SpreadKg = s.weighted / s.pairs / 1.349
+			priors[group][lift] = p
+		}
+	}
+	return priors, nil
+}
+
+// LifterForecast predicts a lifter's lifts at a future meet.
+type LifterForecast struct {
+	Name      string `json:"name"`
+	Sex       string `json:"sex"`
+	Equipment string `json:"equipment"`
+	Date      string `json:"date"`
+	// Confidence is the coverage of the intervals of Lifts.
+	Confidence float64        `json:"confidence"`
+	Lifts      []LiftForecast `json:"lifts"`
+}
+
+// LiftForecast predicts one lift, or the total.
+type LiftForecast struct {
+	Lift string `json:"lift"`
+	// Meets counts the meets the forecast is based on, the last of which
+	// was on LastMeetDate with LastKg.
+	Meets        int     `json:"meets"`
+	LastMeetDate string  `json:"lastMeetDate"`
+	LastKg       float64 `json:"lastKg"`
+	// TrainingYears is the training age at Date.
+	TrainingYears float64 `json:"trainingYears"`
+	Model         string  `json:"model"`
+	Kg            float64 `json:"kg"`
+	// LowerKg and UpperKg bound the interval at Confidence, null when
+	// there is no spread to base it on.
+	LowerKg *float64 `json:"lowerKg"`
+	UpperKg *float64 `json:"upperKg"`
+}
+
+// liftSeries is the history of one lifter at one lift in one equipment.
+type liftSeries struct {
+	dates []time.Time
+	kgs   []float64
+}
+
+// points returns the meets of s up to but excluding index end, with their
+// training age.
+func (s liftSeries) points(end int) []forecast.Point {
+	var points []forecast.Point
+	for i := 0; i < end; i++ {
+		points = append(points, forecast.Point{Years: trainingYears(s.dates[0], s.dates[i]), Kg: s.kgs[i]})
+	}
+	return points
+}
+
+func trainingYears(first, date time.Time) float64 {
+	return date.Sub(first).Hours() / 24 / 365.25
+}
+
+// GetLifterForecast forecasts a lifter's lifts at a meet on date, which must
+// be after their last meet. The history is that of the entries matching
+// filter in one equipment, by default the equipment of the lifter's latest
+// matching entry.
+func GetLifterForecast(ctx context.Context, db *sql.DB, name string, filter EntryFilter, date time.Time) (*LifterForecast, error) {
+	defer observeQuery("GetLifterForecast", time.Now())
+	clause, args := filter.clause("s")
+	query := `
+	SELECT Sex, Equipment, Date, Lift, Kg
+	FROM (` + liftPointsSQL(" WHERE Name = ?"+clause) + `)
+	ORDER BY Date DESC
+	`
+
+	f := &LifterForecast{Name: name, Date: date.Format("2006-01-02"), Confidence: forecast.Confidence}
+	series := make(map[string]*liftSeries)
+	err := withTimeout(ctx, 5*time.Second, "getting lifter forecast", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{name}, args...)...)
+		if err != nil {
+			return fmt.Errorf("querying lifter history: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var sex, equipment, day, lift string
+			var kg float64
+			if err := rows.Scan(&sex, &equipment, &day, &lift, &kg); err != nil {
+				return fmt.Errorf("scanning lifter history: %w", err)
+			}
+			if f.Equipment == "" {
+				f.Sex, f.Equipment = sex, equipment
+			}
+			meet, err := time.Parse("2006-01-02", day)
+			if equipment != f.Equipment || err != nil {
+				continue
+			}
+			s := series[lift]
+			if s == nil {
+				s = &liftSeries{}
+				series[lift] = s
+			}
+			// Latest first, so each meet goes in front
+			s.dates = append([]time.Time{meet}, s.dates...)
+			s.kgs = append([]float64{kg}, s.kgs...)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lifter history rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return nil, ErrNoRows
+	}
+	for _, s := range series {
+		if last := s.dates[len(s.dates)-1]; !date.After(last) {
+			return nil, fmt.Errorf("%w: date must be after the lifter's last meet on %s", ErrInvalidQuery, last.Format("2006-01-02"))
+		}
+	}
+
+	priors, err := GetProgressionPriors(ctx, db, f.Sex, f.Equipment)
+	if err != nil {
+		return nil, err
+	}
+	for _, lift := range ForecastLifts {
+		s := series[lift]
+		if s == nil {
+			continue
+		}
+		n := len(s.kgs)
+		years := trainingYears(s.dates[0], date)
+		p, _ := forecast.Predict(s.points(n), years, priors[lift])
+		f.Lifts = append(f.Lifts, LiftForecast{
+			Lift: lift, Meets: n, LastMeetDate: s.dates[n-1].Format("2006-01-02"), LastKg: s.kgs[n-1],
+			TrainingYears: years, Model: p.Model, Kg: p.Kg, LowerKg: p.LowerKg, UpperKg: p.UpperKg,
+		})
+	}
+	return f, nil
+}
+
+// BacktestForecasts validates forecasts on held-out meets: for up to
+// lifters lifters matching the Sex and Equipment filters, the last meet in
+// each equipment is forecast from the earlier ones and compared with what
+// was lifted. The priors are computed afresh without the last meet of any
+// lifter, so no held-out meet informs them.
+func BacktestForecasts(ctx context.Context, db *sql.DB, filter AggregateFilter, lifters int) ([]forecast.Summary, error) {
+	where, whereArgs := filter.where(false, true, false)
+	query := `
+	SELECT Name, Sex, Equipment, Date, Lift, Kg
+	FROM (` + liftPointsSQL(where) + `)
+	WHERE Name IN (
+		SELECT Name FROM records` + where + `
+		GROUP BY Name HAVING COUNT(DISTINCT Date) >= 2
+		ORDER BY Name LIMIT ?
+	)
+	ORDER BY Name, Equipment, Lift, Date
+	`
+	args := append(append(append([]interface{}{}, whereArgs...), whereArgs...), lifters)
+
+	type key struct{ name, sex, equipment, lift string }
+	var keys []key
+	series := make(map[key]*liftSeries)
+	err := queryAggregate(ctx, db, "querying backtest history", query, args, func(r *sql.Rows) error {
+		var k key
+		var day string
+		var kg float64
+		if err := r.Scan(&k.name, &k.sex, &k.equipment, &day, &k.lift, &kg); err != nil {
+			return err
+		}
+		meet, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil
+		}
+		s := series[k]
+		if s == nil {
+			s = &liftSeries{}
+			series[k] = s
+			keys = append(keys, k)
+		}
+		s.dates = append(s.dates, meet)
+		s.kgs = append(s.kgs, kg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	priors, err := readPriors(ctx, db, "querying backtest priors", progressionPriorsSQL(where, true), whereArgs)
+	if err != nil {
+		return nil, err
+	}
+	backtests := make(map[string]*forecast.Backtest)
+	for _, k := range keys {
+		s := series[k]
+		n := len(s.kgs)
+		if n < 2 {
+			continue
+		}
+		group := [2]string{k.sex, k.equipment}
+		p, _ := forecast.Predict(s.points(n-1), trainingYears(s.dates[0], s.dates[n-1]), priors[group][k.lift])
+		b := backtests[k.lift]
+		if b == nil {
+			b = &forecast.Backtest{}
+			backtests[k.lift] = b
+		}
+		b.Add(p, s.kgs[n-1], s.kgs[n-2])
+	}
+
+	summaries := []forecast.Summary{}
+	for _, lift := range ForecastLifts {
+		if b := backtests[lift]; b != nil {
+			summaries = append(summaries, b.Summary(lift))
+		}
+	}
+	return summaries, nil
+}
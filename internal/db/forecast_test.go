@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"liftmetrics/internal/forecast"
+)
+
+func TestForecast(t *testing.T) {
+	var records []*Record
+	// Three lifters putting 10 kg a year on their bench, meeting yearly
+	for _, name := range []string{"Anna Berg", "Eva Lund", "Ida Holm"} {
+		for year := 2020; year <= 2023; year++ {
+			bench := 50 + 10*float64(year-2020)
+			records = append(records, &Record{
+				Name: name, Sex: "F", Event: "B", Equipment: "Raw", Date: fmt.Sprintf("%d-06-01", year),
+				Best3BenchKg: bench, TotalKg: bench,
+			})
+		}
+	}
+	// and one with a single meet
+	records = append(records, &Record{
+		Name: "Mia Sand", Sex: "F", Event: "B", Equipment: "Raw", Date: "2023-06-01", Best3BenchKg: 70, TotalKg: 70,
+	})
+	database := newTestDatabase(t, records)
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	priors, err := GetProgressionPriors(ctx, database, "F", "Raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bench := priors["Bench"]
+	// A year between meets is a little short of a year of training age
+	if len(bench.RateKgPerYear) != 2 || math.Abs(bench.RateKgPerYear[1]-10*365.25/365) > 1e-9 || bench.SpreadKg != 0 {
+		t.Errorf("bench prior %+v", bench)
+	}
+	if _, ok := priors["Total"]; ok {
+		t.Error("prior for the total of bench only meets")
+	}
+
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	f, err := GetLifterForecast(ctx, database, "Mia Sand", EntryFilter{}, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Equipment != "Raw" || f.Confidence != forecast.Confidence || len(f.Lifts) != 1 {
+		t.Fatalf("GetLifterForecast = %+v", f)
+	}
+	if l := f.Lifts[0]; l.Lift != "Bench" || l.Model != forecast.ModelPrior || l.Meets != 1 || math.Abs(l.Kg-80) > 0.1 {
+		t.Errorf("bench forecast %+v, want about 80 kg from the prior", l)
+	}
+
+	f, err = GetLifterForecast(ctx, database, "Anna Berg", EntryFilter{Event: "B"}, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := f.Lifts[0]; l.Model != forecast.ModelTheilSen || l.Meets != 4 || math.Abs(l.Kg-90) > 0.1 || l.LastMeetDate != "2023-06-01" {
+		t.Errorf("bench forecast %+v, want about 90 kg from the trend", l)
+	}
+
+	if _, err := GetLifterForecast(ctx, database, "Nobody", EntryFilter{}, date); !errors.Is(err, ErrNoRows) {
+		t.Errorf("unknown lifter: %v, want ErrNoRows", err)
+	}
+	if _, err := GetLifterForecast(ctx, database, "Anna Berg", EntryFilter{}, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("forecast of the last meet: %v, want ErrInvalidQuery", err)
+	}
+
+	// Without the held-out 2023 meets no pair starts in the second year
+	held, err := readPriors(ctx, database, "test", progressionPriorsSQL(" WHERE Sex = ?", true), []interface{}{"F"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bench := held[[2]string{"F", "Raw"}]["Bench"]; len(bench.RateKgPerYear) != 1 {
+		t.Errorf("backtest bench prior %+v", bench)
+	}
+
+	summaries, err := BacktestForecasts(ctx, database, AggregateFilter{Sex: "F"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Lift != "Bench" || summaries[0].Forecasts != 2 || *summaries[0].MAEKg > 0.1 {
+		t.Errorf("BacktestForecasts = %+v", summaries)
+	}
+}
+
+// TestBacktestDivisions tests that a lifter entered in several divisions of
+// their last meet is forecast from their earlier meets only.
+func TestBacktestDivisions(t *testing.T) {
+	var records []*Record
+	for _, name := range []string{"Ole Dahl", "Per Vik"} {
+		for _, m := range []struct {
+			date, division string
+			bench          float64
+		}{
+			{"2022-06-01", "Open", 100}, {"2023-06-01", "Open", 100},
+			{"2024-06-01", "Open", 180}, {"2024-06-01", "M1", 180},
+		} {
+			records = append(records, &Record{
+				Name: name, Sex: "M", Event: "B", Equipment: "Raw", Division: m.division, Date: m.date,
+				Best3BenchKg: m.bench, TotalKg: m.bench,
+			})
+		}
+	}
+	database := newTestDatabase(t, records)
+
+	summaries, err := BacktestForecasts(context.Background(), database, AggregateFilter{Sex: "M"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Forecasts != 2 || *summaries[0].NaiveMAEKg != 80 || *summaries[0].MAEKg < 70 {
+		t.Errorf("BacktestForecasts = %+v", summaries)
+	}
+}
@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LifterFilter restricts a lifter listing to lifters with at least one entry
+// matching every non-empty field.
+type LifterFilter struct {
+	Sex           string
+	Country       string
+	Federation    string
+	Equipment     string
+	Event         string
+	WeightClassKg string
+	AgeClass      string
+	Division      string
+
+	// Names, when non-nil, restricts the listing to these lifters and orders
+	// the results by their position in the slice instead of alphabetically.
+	Names []string
+}
+
+// LifterSummary is a lifter as shown in a listing. The descriptive fields are
+// taken from the lifter's most recent matching entry.
+type LifterSummary struct {
+	Name          string `json:"name"`
+	Sex           string `json:"sex"`
+	Country       string `json:"country"`
+	Federation    string `json:"federation"`
+	Equipment     string `json:"equipment"`
+	Event         string `json:"event"`
+	WeightClassKg string `json:"weightClassKg"`
+	AgeClass      string `json:"ageClass"`
+	Division      string `json:"division"`
+	LastMeetDate  string `json:"lastMeetDate"`
+	Entries       int    `json:"entries"`
+}
+
+// FacetCount is the number of distinct lifters having a given facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// LifterPage is one page of a lifter listing together with the facet counts of
+// the whole filtered set. Truncated is set by callers that restricted the
+// listing to a capped list of names, when more names qualified, so that
+// Total and Facets count only the names listed.
+type LifterPage struct {
+	Lifters   []LifterSummary         `json:"lifters"`
+	Total     int                     `json:"total"`
+	Limit     int                     `json:"limit"`
+	Offset    int                     `json:"offset"`
+	Facets    map[string][]FacetCount `json:"facets"`
+	Truncated bool                    `json:"truncated"`
+}
+
+// lifterFacets lists the facet names exposed in listings and the records
+// column each one is computed from.
+var lifterFacets = []struct {
+	key    string
+	column string
+}{
+	{"sex", "Sex"},
+	{"country", "Country"},
+	{"federation", "Federation"},
+	{"equipment", "Equipment"},
+	{"event", "Event"},
+	{"weightClassKg", "WeightClassKg"},
+	{"ageClass", "AgeClass"},
+	{"division", "Division"},
+}
+
+func (f LifterFilter) value(column string) string {
+	switch column {
+	case "Sex":
+		return f.Sex
+	case "Country":
+		return f.Country
+	case "Federation":
+		return f.Federation
+	case "Equipment":
+		return f.Equipment
+	case "Event":
+		return f.Event
+	case "WeightClassKg":
+		return f.WeightClassKg
+	case "AgeClass":
+		return f.AgeClass
+	case "Division":
+		return f.Division
+	}
+	return ""
+}
+
+// selectFrom builds a query selecting columns from the records matching the
+// filter, ending in its WHERE clause so callers can append to it. The filter
+// on the skip column is left out, which is how facet counts stay useful for
+// picking an alternative value of a facet that is already filtered on.
+func (f LifterFilter) selectFrom(columns, skip string) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+
+	if f.Names != nil {
+		b.WriteString("WITH ranked(Name, Rank) AS (VALUES ")
+		for i, name := range f.Names {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(?, ?)")
+			args = append(args, name, i)
+		}
+		b.WriteString(") ")
+	}
+
+	b.WriteString("SELECT " + columns + " FROM records r ")
+	if f.Names != nil {
+		b.WriteString("JOIN ranked k ON k.Name = r.Name ")
+	}
+
+	b.WriteString("WHERE 1 = 1")
+	for _, facet := range lifterFacets {
+		if v := f.value(facet.column); v != "" && facet.column != skip {
+			b.WriteString(" AND r." + facet.column + " = ?")
+			args = append(args, v)
+		}
+	}
+
+	return b.String(), args
+}
+
+// ListLifters returns one page of the lifters matching filter along with the
+// total number of matches and per-facet lifter counts. Lifters are ordered by
+// name, or by their position in filter.Names when it is set.
+func ListLifters(ctx context.Context, db *sql.DB, filter LifterFilter, limit, offset int) (LifterPage, error) {
+	page := LifterPage{
+		Lifters: []LifterSummary{},
+		Limit:   limit,
+		Offset:  offset,
+		Facets:  make(map[string][]FacetCount),
+	}
+	if filter.Names != nil && len(filter.Names) == 0 {
+		for _, facet := range lifterFacets {
+			page.Facets[facet.key] = []FacetCount{}
+		}
+		return page, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	countQuery, args := filter.selectFrom("COUNT(DISTINCT r.Name)", "")
+	if err := db.QueryRowContext(queryCtx, countQuery, args...).Scan(&page.Total); err != nil {
+		return LifterPage{}, fmt.Errorf("counting lifters: %w", err)
+	}
+
+	order := "r.Name"
+	if filter.Names != nil {
+		order = "k.Rank, r.Name"
+	}
+	// SQLite takes the bare columns from the row holding MAX(r.Date), i.e. the
+	// lifter's most recent matching entry.
+	listQuery, args := filter.selectFrom(`r.Name, MAX(r.Date), r.Sex, r.Country, r.Federation,
+		r.Equipment, r.Event, r.WeightClassKg, r.AgeClass, r.Division, COUNT(*)`, "")
+	listQuery += " GROUP BY r.Name ORDER BY " + order + " LIMIT ? OFFSET ?"
+
+	rows, err := db.QueryContext(queryCtx, listQuery, append(args, limit, offset)...)
+	if err != nil {
+		return LifterPage{}, fmt.Errorf("querying lifters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l LifterSummary
+		if err := rows.Scan(&l.Name, &l.LastMeetDate, &l.Sex, &l.Country, &l.Federation, &l.Equipment, &l.Event,
+			&l.WeightClassKg, &l.AgeClass, &l.Division, &l.Entries); err != nil {
+			return LifterPage{}, fmt.Errorf("scanning lifter summary: %w", err)
+		}
+		page.Lifters = append(page.Lifters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return LifterPage{}, fmt.Errorf("iterating over lifter summary rows: %w", err)
+	}
+
+	for _, facet := range lifterFacets {
+		counts, err := facetCounts(queryCtx, db, filter, facet.column)
+		if err != nil {
+			return LifterPage{}, err
+		}
+		page.Facets[facet.key] = counts
+	}
+
+	return page, nil
+}
+
+// facetCounts counts the distinct lifters per value of column, applying every
+// filter except the one on column itself.
+func facetCounts(ctx context.Context, db *sql.DB, filter LifterFilter, column string) ([]FacetCount, error) {
+	query, args := filter.selectFrom("r."+column+", COUNT(DISTINCT r.Name)", column)
+	query += " AND r." + column + " != '' GROUP BY r." + column + " ORDER BY 2 DESC, 1"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s facet: %w", column, err)
+	}
+	defer rows.Close()
+
+	counts := []FacetCount{}
+	for rows.Next() {
+		var fc FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, fmt.Errorf("scanning %s facet: %w", column, err)
+		}
+		counts = append(counts, fc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over %s facet rows: %w", column, err)
+	}
+
+	return counts, nil
+}
+
+// MatchLifterNames returns the lifters a name given by a person may refer to:
+// the lifter of that exact name, ignoring case, and those OpenPowerlifting
+// tells apart with a numbered suffix such as "John Smith #1". Names are
+// returned in order.
+func MatchLifterNames(ctx context.Context, db *sql.DB, name string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT DISTINCT Name FROM records
+	WHERE Name = ? COLLATE NOCASE OR Name LIKE ? ESCAPE '\'
+	ORDER BY Name
+	`, name, escapeLike(name)+" #%")
+	if err != nil {
+		return nil, fmt.Errorf("matching lifter names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("scanning lifter name: %w", err)
+		}
+		names = append(names, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over lifter names: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+// TestListLifters tests facet filtering, facet counts and pagination.
+func TestListLifters(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{Name: "Anna Berg", Sex: "F", Equipment: "Raw", BodyweightKg: 62, Country: "Denmark", Federation: "DSF", Date: "2023-03-01"},
+		{Name: "Anna Berg", Sex: "F", Equipment: "Single-ply", BodyweightKg: 68, Country: "Denmark", Federation: "DSF", Date: "2024-03-01"},
+		{Name: "Bo Dahl", Sex: "M", Equipment: "Raw", BodyweightKg: 82, Country: "Denmark", Federation: "DSF", Date: "2024-05-01"},
+		{Name: "Carl Ek", Sex: "M", Equipment: "Raw", BodyweightKg: 80, Country: "Sweden", Federation: "SSF", Date: "2024-06-01"},
+		{Name: "Dan Fors", Sex: "M", Equipment: "Single-ply", BodyweightKg: 100, Country: "Sweden", Federation: "SSF", Date: "2024-07-01"},
+	})
+	ctx := context.Background()
+
+	t.Run("Filter and facets", func(t *testing.T) {
+		page, err := ListLifters(ctx, database, LifterFilter{Country: "Denmark", Equipment: "Raw"}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListLifters failed: %v", err)
+		}
+
+		if page.Total != 2 || len(page.Lifters) != 2 {
+			t.Fatalf("Expected 2 lifters, got total %d and %v", page.Total, page.Lifters)
+		}
+		if page.Lifters[0].Name != "Anna Berg" || page.Lifters[1].Name != "Bo Dahl" {
+			t.Errorf("Unexpected lifters: %v", page.Lifters)
+		}
+		if page.Lifters[0].WeightClassKg != "-63" {
+			t.Errorf("Expected the latest matching entry's weight class -63, got %q", page.Lifters[0].WeightClassKg)
+		}
+
+		// The country facet ignores the country filter itself.
+		want := []FacetCount{{"Denmark", 2}, {"Sweden", 1}}
+		if got := page.Facets["country"]; !equalFacets(got, want) {
+			t.Errorf("Country facet = %v, want %v", got, want)
+		}
+		want = []FacetCount{{"F", 1}, {"M", 1}}
+		if got := page.Facets["sex"]; !equalFacets(got, want) {
+			t.Errorf("Sex facet = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		var names []string
+		for offset := 0; offset < 4; offset += 2 {
+			page, err := ListLifters(ctx, database, LifterFilter{}, 2, offset)
+			if err != nil {
+				t.Fatalf("ListLifters failed: %v", err)
+			}
+			if page.Total != 4 {
+				t.Errorf("Expected total 4, got %d", page.Total)
+			}
+			for _, l := range page.Lifters {
+				names = append(names, l.Name)
+			}
+		}
+
+		want := []string{"Anna Berg", "Bo Dahl", "Carl Ek", "Dan Fors"}
+		if len(names) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, names)
+		}
+		for i := range want {
+			if names[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, names)
+				break
+			}
+		}
+	})
+
+	t.Run("Ranked names", func(t *testing.T) {
+		filter := LifterFilter{Sex: "M", Names: []string{"Dan Fors", "Anna Berg", "Carl Ek"}}
+		page, err := ListLifters(ctx, database, filter, 10, 0)
+		if err != nil {
+			t.Fatalf("ListLifters failed: %v", err)
+		}
+		if len(page.Lifters) != 2 || page.Lifters[0].Name != "Dan Fors" || page.Lifters[1].Name != "Carl Ek" {
+			t.Errorf("Expected [Dan Fors Carl Ek] in rank order, got %v", page.Lifters)
+		}
+	})
+}
+
+func equalFacets(a, b []FacetCount) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatchLifterNames(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{Name: "John Smith #1", Sex: "M", Equipment: "Raw", BodyweightKg: 82, Date: "2024-03-01"},
+		{Name: "John Smith #2", Sex: "M", Equipment: "Raw", BodyweightKg: 93, Date: "2024-03-01"},
+		{Name: "John Smithson", Sex: "M", Equipment: "Raw", BodyweightKg: 93, Date: "2024-03-01"},
+		{Name: "Anna Berg", Sex: "F", Equipment: "Raw", BodyweightKg: 62, Date: "2024-03-01"},
+	})
+	ctx := context.Background()
+
+	for name, want := range map[string][]string{
+		"John Smith":    {"John Smith #1", "John Smith #2"},
+		"John Smith #2": {"John Smith #2"},
+		"anna berg":     {"Anna Berg"},
+		"Anna":          nil,
+		"John_Smith":    nil,
+	} {
+		got, err := MatchLifterNames(ctx, database, name)
+		if err != nil {
+			t.Fatalf("MatchLifterNames(%q) failed: %v", name, err)
+		}
+		if !equalStrings(got, want) {
+			t.Errorf("MatchLifterNames(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the tables created by CreateDatabase. It
+// is increased whenever they change in a way that needs the database to be
+// rebuilt.
+const SchemaVersion = 8
+
+// DataRevision identifies the data a database was built from.
+type DataRevision struct {
+	// Revision is the OpenPowerlifting revision of the CSV, if known.
+	Revision string `json:"revision"`
+	// BuiltAt is when the database was populated, in RFC 3339 format.
+	BuiltAt string `json:"builtAt"`
+}
+
+// String returns a token that changes whenever the database is rebuilt.
+func (r DataRevision) String() string {
+	return r.Revision + "@" + r.BuiltAt
+}
+
+// SetDataRevision records that the database was just built from revision.
+func SetDataRevision(ctx context.Context, db *sql.DB, revision string) error {
+	builtAt := time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := db.ExecContext(ctx, `
+	INSERT INTO metadata (Key, Value) VALUES ('DataRevision', ?), ('BuiltAt', ?)
+	ON CONFLICT (Key) DO UPDATE SET Value = excluded.Value
+	`, revision, builtAt)
+	if err != nil {
+		return fmt.Errorf("setting data revision: %w", err)
+	}
+	return nil
+}
+
+// GetDataRevision returns the revision recorded by SetDataRevision, or
+// ErrNoRows if none was recorded.
+func GetDataRevision(ctx context.Context, db *sql.DB) (DataRevision, error) {
+	var rev DataRevision
+	err := withTimeout(ctx, 5*time.Second, "getting data revision", func(ctx context.Context) error {
+		return db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT Value FROM metadata WHERE Key = 'DataRevision'),
+			(SELECT Value FROM metadata WHERE Key = 'BuiltAt')
+		WHERE EXISTS (SELECT 1 FROM metadata WHERE Key = 'BuiltAt')
+		`).Scan(&rev.Revision, &rev.BuiltAt)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return DataRevision{}, ErrNoRows
+	}
+	if err != nil {
+		return DataRevision{}, fmt.Errorf("getting data revision: %w", err)
+	}
+	return rev, nil
+}
+
+// GetSchemaVersion returns the schema version the database was created with,
+// or ErrNoRows if it predates versioning.
+func GetSchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := withTimeout(ctx, 5*time.Second, "getting schema version", func(ctx context.Context) error {
+		return db.QueryRowContext(ctx, `SELECT CAST(Value AS INTEGER) FROM metadata WHERE Key = 'SchemaVersion'`).Scan(&version)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRows
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting schema version: %w", err)
+	}
+	return version, nil
+}
+
+// ListTables returns the names of the tables in the database, in order.
+func ListTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	var tables []string
+	err := withTimeout(ctx, 5*time.Second, "listing tables", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+		if err != nil {
+			return fmt.Errorf("listing tables: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return fmt.Errorf("scanning table name: %w", err)
+			}
+			tables = append(tables, name)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// CountTableRows returns the number of rows of every table in the database,
+// keyed by table name.
+func CountTableRows(ctx context.Context, db *sql.DB) (map[string]int64, error) {
+	tables, err := ListTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(tables))
+	err = withTimeout(ctx, 30*time.Second, "counting table rows", func(ctx context.Context) error {
+		for _, table := range tables {
+			var n int64
+			query := `SELECT COUNT(*) FROM ` + quoteIdentifier(table)
+			if err := db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+				return fmt.Errorf("counting rows of %s: %w", table, err)
+			}
+			counts[table] = n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// quoteIdentifier quotes a table or column name for use in SQL.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// TablePage is a page of rows of a table, each row keyed by column name.
+type TablePage struct {
+	Columns []string                 `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+	Total   int                      `json:"total"`
+	Limit   int                      `json:"limit"`
+	Offset  int                      `json:"offset"`
+}
+
+// QueryTable returns a page of the rows of table whose columns equal the
+// values in filters, in rowid order. A filter on a column the table lacks
+// is an ErrInvalidQuery. The table name must be trusted.
+func QueryTable(ctx context.Context, db *sql.DB, table string, filters map[string]string, limit, offset int) (TablePage, error) {
+	page := TablePage{Rows: []map[string]interface{}{}, Limit: limit, Offset: offset}
+	err := withTimeout(ctx, 10*time.Second, "querying "+table, func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?) ORDER BY cid`, table)
+		if err != nil {
+			return fmt.Errorf("reading columns of %s: %w", table, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				return fmt.Errorf("scanning column of %s: %w", table, err)
+			}
+			page.Columns = append(page.Columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading columns of %s: %w", table, err)
+		}
+		if len(page.Columns) == 0 {
+			return ErrNoRows
+		}
+
+		var conditions []string
+		var args []interface{}
+		for _, column := range sortedFilterKeys(filters) {
+			if !slices.Contains(page.Columns, column) {
+				return fmt.Errorf("%w: %s has no column %q", ErrInvalidQuery, table, column)
+			}
+			conditions = append(conditions, quoteIdentifier(column)+" = ?")
+			args = append(args, filters[column])
+		}
+		where := ""
+		if len(conditions) > 0 {
+			where = " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quoteIdentifier(table)+where, args...).Scan(&page.Total); err != nil {
+			return fmt.Errorf("counting rows of %s: %w", table, err)
+		}
+
+		quoted := make([]string, len(page.Columns))
+		for i, column := range page.Columns {
+			quoted[i] = quoteIdentifier(column)
+		}
+		query := `SELECT ` + strings.Join(quoted, ", ") + ` FROM ` + quoteIdentifier(table) + where + ` ORDER BY rowid LIMIT ? OFFSET ?`
+		data, err := db.QueryContext(ctx, query, append(args, limit, offset)...)
+		if err != nil {
+			return fmt.Errorf("querying %s: %w", table, err)
+		}
+		defer data.Close()
+		for data.Next() {
+			values := make([]interface{}, len(page.Columns))
+			pointers := make([]interface{}, len(values))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			if err := data.Scan(pointers...); err != nil {
+				return fmt.Errorf("scanning row of %s: %w", table, err)
+			}
+			row := make(map[string]interface{}, len(values))
+			for i, v := range values {
+				if b, ok := v.([]byte); ok {
+					v = string(b)
+				}
+				row[page.Columns[i]] = v
+			}
+			page.Rows = append(page.Rows, row)
+		}
+		return data.Err()
+	})
+	if err != nil {
+		return TablePage{}, err
+	}
+	return page, nil
+}
+
+func sortedFilterKeys(filters map[string]string) []string {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
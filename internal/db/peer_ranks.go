@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bases of peer_ranks: an entry is ranked among all entries of its peers, and
+// a lifter's best entry of a year also among the bests of the peer lifters.
+const (
+	RankBasisEntry  = "entry"
+	RankBasisLifter = "lifter"
+)
+
+// peerGroup are the columns peers share: they compete in the same sex,
+// equipment, event, weight class and age class in the same year.
+const peerGroup = "Sex, Equipment, Event, WeightClassKg, AgeClass, Year"
+
+// rankScopes are the scopes entries are ranked in, by column prefix, with
+// the columns peers share besides peerGroup.
+var rankScopes = []struct{ prefix, column string }{
+	{"Global", ""}, {"Country", "Country"}, {"Federation", "Federation"},
+}
+
+// peerRankColumns lists the rank, peer count and percentile columns of
+// peer_ranks, in the order of rankScopes.
+var peerRankColumns = func() string {
+	var columns []string
+	for _, s := range rankScopes {
+		columns = append(columns, s.prefix+"Rank", s.prefix+"Peers", s.prefix+"Percentile")
+	}
+	return strings.Join(columns, ", ")
+}()
+
+// PeerRanks ranks every entry with a total among its peers, globally and
+// within its country and federation. Ranks are by total, with ties sharing
+// a rank, and the percentile is the share of peers totalling at most as
+// much. Entries without a country or federation are not ranked within it.
+//
+// The bests of the peer lifters are taken per scope: a lifter who competed
+// in two federations in a year has a best in each, ranked within its
+// federation, while only the better of the two is ranked globally.
+type PeerRanks struct{}
+
+func (p *PeerRanks) Name() string { return "peer_ranks" }
+
+func (p *PeerRanks) Inputs() []string { return []string{"records"} }
+
+func (p *PeerRanks) Outputs() []string { return []string{"peer_ranks"} }
+
+func (p *PeerRanks) Calculate(ctx context.Context, tx *sql.Tx) error {
+	// ranks returns the rank columns over the rows for which the condition
+	// of each scope holds, in the order of rankScopes.
+	ranks := func(ranked func(prefix, column string) string) string {
+		var columns []string
+		for _, s := range rankScopes {
+			partition := peerGroup
+			if s.column != "" {
+				partition += ", " + s.column
+			}
+			cond := ranked(s.prefix, s.column)
+			partition += ", " + cond
+			columns = append(columns, fmt.Sprintf(`
+				CASE WHEN %[2]s THEN RANK() OVER (PARTITION BY %[1]s ORDER BY TotalKg DESC) END,
+				CASE WHEN %[2]s THEN COUNT(*) OVER (PARTITION BY %[1]s) END,
+				CASE WHEN %[2]s THEN 100 * CUME_DIST() OVER (PARTITION BY %[1]s ORDER BY TotalKg) END`, partition, cond))
+		}
+		return strings.Join(columns, ",")
+	}
+	inScope := func(prefix, column string) string {
+		if column == "" {
+			return "1"
+		}
+		return "(" + column + " <> '')"
+	}
+
+	// Each entry is flagged as its lifter's best of the year in each scope
+	var flags []string
+	for _, s := range rankScopes {
+		partition := "Name, " + peerGroup
+		if s.column != "" {
+			partition += ", " + s.column
+		}
+		flags = append(flags, fmt.Sprintf(`
+			%s AND ROW_NUMBER() OVER (PARTITION BY %s ORDER BY TotalKg DESC, Date, ID) = 1 AS %sBest`,
+			inScope(s.prefix, s.column), partition, s.prefix))
+	}
+	var anyBest []string
+	for _, s := range rankScopes {
+		anyBest = append(anyBest, s.prefix+"Best")
+	}
+
+	query := `
+		INSERT INTO peer_ranks (ID, Basis, Year, ` + peerRankColumns + `)
+		WITH entries AS (
+			SELECT ID, Name, Sex, Equipment, Event, WeightClassKg, AgeClass,
+				CAST(strftime('%Y', Date) AS INTEGER) AS Year,
+				COALESCE(Country, '') AS Country, COALESCE(Federation, '') AS Federation, Date, TotalKg
+			FROM records
+			WHERE TotalKg > 0
+		), bests AS (
+			SELECT *,` + strings.Join(flags, ",") + `
+			FROM entries
+		)
+		SELECT ID, '` + RankBasisEntry + `', Year,` + ranks(inScope) + `
+		FROM entries
+		UNION ALL
+		SELECT ID, '` + RankBasisLifter + `', Year,` + ranks(func(prefix, column string) string { return prefix + "Best" }) + `
+		FROM bests
+		WHERE ` + strings.Join(anyBest, " OR ") + `
+	`
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM peer_ranks`); err != nil {
+		return fmt.Errorf("clearing peer ranks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("calculating peer ranks: %w", err)
+	}
+	return nil
+}
+
+// EntryPeerRank places one entry among its peers. Ranks, peer counts and
+// percentiles are null where the entry is not ranked in the scope.
+type EntryPeerRank struct {
+	Date          string  `json:"date"`
+	MeetName      string  `json:"meetName"`
+	Sex           string  `json:"sex"`
+	Equipment     string  `json:"equipment"`
+	Event         string  `json:"event"`
+	WeightClassKg string  `json:"weightClassKg"`
+	AgeClass      string  `json:"ageClass"`
+	Year          int     `json:"year"`
+	Country       string  `json:"country"`
+	Federation    string  `json:"federation"`
+	TotalKg       float64 `json:"totalKg"`
+	// Basis tells whether the entry is ranked among the entries of its
+	// peers or among their bests.
+	Basis                string   `json:"basis"`
+	GlobalRank           *int     `json:"globalRank"`
+	GlobalPeers          *int     `json:"globalPeers"`
+	GlobalPercentile     *float64 `json:"globalPercentile"`
+	CountryRank          *int     `json:"countryRank"`
+	CountryPeers         *int     `json:"countryPeers"`
+	CountryPercentile    *float64 `json:"countryPercentile"`
+	FederationRank       *int     `json:"federationRank"`
+	FederationPeers      *int     `json:"federationPeers"`
+	FederationPercentile *float64 `json:"federationPercentile"`
+}
+
+// LifterPeerRanks places a lifter among their peers.
+type LifterPeerRanks struct {
+	Name string `json:"name"`
+	// Entries ranks each entry among the entries of its peers, latest
+	// first.
+	Entries []EntryPeerRank `json:"entries"`
+	// CurrentBests ranks, for each equipment and event, the lifter's best
+	// entry of the latest year they competed in among the bests of the
+	// peer lifters that year. It is followed by their bests in other
+	// countries and federations that year, which are ranked only there.
+	CurrentBests []EntryPeerRank `json:"currentBests"`
+}
+
+// GetLifterPeerRanks retrieves the peer ranks of a lifter's entries
+// matching filter. Entries without a total are left out.
+func GetLifterPeerRanks(ctx context.Context, db *sql.DB, name string, filter EntryFilter) (*LifterPeerRanks, error) {
+	defer observeQuery("GetLifterPeerRanks", time.Now())
+	clause, args := filter.clause("r")
+	query := `
+	SELECT r.Date, r.MeetName, r.Sex, r.Equipment, r.Event, r.WeightClassKg, r.AgeClass, pr.Year,
+		COALESCE(r.Country, ''), COALESCE(r.Federation, ''), r.TotalKg, pr.Basis, ` + prefixColumns("pr", peerRankColumns) + `
+	FROM records r
+	JOIN peer_ranks pr ON pr.ID = r.ID
+	WHERE r.Name = ?` + clause + `
+	ORDER BY r.Date DESC, r.ID, pr.Basis
+	`
+
+	ranks := &LifterPeerRanks{Name: name, Entries: []EntryPeerRank{}}
+	var bests []EntryPeerRank
+	err := withTimeout(ctx, 5*time.Second, "getting lifter peer ranks", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query, append([]interface{}{name}, args...)...)
+		if err != nil {
+			return fmt.Errorf("querying lifter peer ranks: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e EntryPeerRank
+			if err := rows.Scan(&e.Date, &e.MeetName, &e.Sex, &e.Equipment, &e.Event, &e.WeightClassKg, &e.AgeClass, &e.Year,
+				&e.Country, &e.Federation, &e.TotalKg, &e.Basis,
+				&e.GlobalRank, &e.GlobalPeers, &e.GlobalPercentile,
+				&e.CountryRank, &e.CountryPeers, &e.CountryPercentile,
+				&e.FederationRank, &e.FederationPeers, &e.FederationPercentile,
+			); err != nil {
+				return fmt.Errorf("scanning lifter peer ranks: %w", err)
+			}
+			if e.Basis == RankBasisEntry {
+				ranks.Entries = append(ranks.Entries, e)
+			} else {
+				bests = append(bests, e)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over lifter peer ranks rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ranks.Entries) == 0 {
+		return nil, ErrNoRows
+	}
+	ranks.CurrentBests = currentBests(bests)
+	return ranks, nil
+}
+
+// currentBests picks, from a lifter's bests ordered latest first, the best of
+// the latest year for each equipment and event: the heaviest one ranked
+// globally, followed by the bests of the same peer group in the lifter's
+// other countries and federations that year.
+func currentBests(bests []EntryPeerRank) []EntryPeerRank {
+	type key struct{ equipment, event string }
+	var keys []key
+	overall := make(map[key]EntryPeerRank)
+	for _, b := range bests {
+		k := key{b.Equipment, b.Event}
+		o, ok := overall[k]
+		switch {
+		case b.GlobalRank == nil:
+		case !ok:
+			keys = append(keys, k)
+			overall[k] = b
+		// A later year has been seen already
+		case o.Year == b.Year && b.TotalKg > o.TotalKg:
+			overall[k] = b
+		}
+	}
+
+	current := []EntryPeerRank{}
+	for _, k := range keys {
+		o := overall[k]
+		current = append(current, o)
+		for _, b := range bests {
+			if b.GlobalRank == nil && b.Equipment == o.Equipment && b.Event == o.Event && b.Year == o.Year &&
+				b.WeightClassKg == o.WeightClassKg && b.AgeClass == o.AgeClass {
+				current = append(current, b)
+			}
+		}
+	}
+	return current
+}
+
+// prefixColumns qualifies each of the comma-separated columns with alias.
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ", ")
+	for i, c := range parts {
+		parts[i] = alias + "." + c
+	}
+	return strings.Join(parts, ", ")
+}
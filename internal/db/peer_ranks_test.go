@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPeerRanks(t *testing.T) {
+	entry := func(name, country, date, class string, total float64) *Record {
+		return &Record{
+			Name: name, Sex: "M", Event: "SBD", Equipment: "Raw", Date: date, WeightClassKg: class, AgeClass: "24-34",
+			Country: country, Federation: "DSF", TotalKg: total,
+		}
+	}
+	database := newTestDatabase(t, []*Record{
+		entry("Anders Holm", "Denmark", "2023-03-01", "93", 600),
+		entry("Anders Holm", "Denmark", "2023-09-01", "93", 650),
+		entry("Anders Holm", "Denmark", "2024-03-01", "93", 620),
+		entry("Anders Holm", "Denmark", "2024-09-01", "105", 630),
+		entry("Bjørn Dahl", "Norway", "2023-06-01", "93", 650),
+		entry("Carl Berg", "Denmark", "2023-06-01", "93", 500),
+		entry("Dan Ek", "", "2023-06-01", "93", 700),
+		entry("Erik Lund", "Denmark", "2023-06-01", "93", 0),
+	})
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	ranks, err := GetLifterPeerRanks(ctx, database, "Anders Holm", EntryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks.Entries) != 4 || ranks.Entries[0].Date != "2024-09-01" {
+		t.Fatalf("entries %+v", ranks.Entries)
+	}
+	// Tied with Bjørn, behind Dan, ahead of their own 600 and Carl
+	e := ranks.Entries[2]
+	if e.TotalKg != 650 || *e.GlobalRank != 2 || *e.GlobalPeers != 5 || *e.GlobalPercentile != 80 {
+		t.Errorf("global rank of the 650 entry %+v", e)
+	}
+	if *e.CountryRank != 1 || *e.CountryPeers != 3 || *e.CountryPercentile != 100 || *e.FederationPeers != 5 {
+		t.Errorf("scoped ranks of the 650 entry %+v", e)
+	}
+	if e := ranks.Entries[3]; *e.GlobalRank != 4 || *e.GlobalPercentile != 40 {
+		t.Errorf("global rank of the 600 entry %+v", e)
+	}
+
+	// The heavier of the two weight classes of the latest year
+	if len(ranks.CurrentBests) != 1 {
+		t.Fatalf("current bests %+v", ranks.CurrentBests)
+	}
+	if b := ranks.CurrentBests[0]; b.Basis != RankBasisLifter || b.Year != 2024 || b.TotalKg != 630 || *b.GlobalRank != 1 || *b.GlobalPeers != 1 {
+		t.Errorf("current best %+v", b)
+	}
+
+	// Among the lifters' bests of 2023, Anders' own 600 does not count
+	var best float64
+	err = database.QueryRowContext(ctx, `
+		SELECT GlobalPercentile FROM peer_ranks pr JOIN records r ON r.ID = pr.ID
+		WHERE r.Name = 'Anders Holm' AND pr.Basis = 'lifter' AND pr.Year = 2023
+	`).Scan(&best)
+	if err != nil || best != 75 {
+		t.Errorf("percentile of the 2023 best %v, %v, want 75", best, err)
+	}
+
+	ranks, err = GetLifterPeerRanks(ctx, database, "Dan Ek", EntryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := ranks.Entries[0]; e.CountryRank != nil || e.CountryPercentile != nil || *e.GlobalRank != 1 {
+		t.Errorf("entry without a country %+v", e)
+	}
+
+	for _, name := range []string{"Nobody", "Erik Lund"} {
+		if _, err := GetLifterPeerRanks(ctx, database, name, EntryFilter{}); !errors.Is(err, ErrNoRows) {
+			t.Errorf("%s: %v, want ErrNoRows", name, err)
+		}
+	}
+}
+
+func TestPeerRanksAcrossFederations(t *testing.T) {
+	entry := func(name, country, federation, date string, total float64) *Record {
+		return &Record{
+			Name: name, Sex: "M", Event: "SBD", Equipment: "Raw", Date: date, Country: country, Federation: federation, TotalKg: total,
+		}
+	}
+	database := newTestDatabase(t, []*Record{
+		entry("Finn Moe", "Denmark", "DSF", "2023-03-01", 620),
+		entry("Finn Moe", "Denmark", "NPF", "2023-09-01", 660),
+		entry("Gus Aas", "Denmark", "DSF", "2023-06-01", 640),
+		entry("Hal Vik", "Norway", "NPF", "2023-06-01", 650),
+	})
+	ctx := context.Background()
+	if err := NewFeatureCalculator().UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	ranks, err := GetLifterPeerRanks(ctx, database, "Finn Moe", EntryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks.CurrentBests) != 2 {
+		t.Fatalf("current bests %+v", ranks.CurrentBests)
+	}
+	// The 660 is Finn's best overall, in Denmark and in the NPF
+	if b := ranks.CurrentBests[0]; b.TotalKg != 660 || *b.GlobalRank != 1 || *b.GlobalPeers != 3 ||
+		*b.CountryPeers != 2 || *b.FederationRank != 1 || *b.FederationPeers != 2 {
+		t.Errorf("overall best %+v", b)
+	}
+	// and the 620 his best in the DSF, behind Gus
+	if b := ranks.CurrentBests[1]; b.TotalKg != 620 || b.GlobalRank != nil || b.CountryRank != nil ||
+		*b.FederationRank != 2 || *b.FederationPeers != 2 || *b.FederationPercentile != 50 {
+		t.Errorf("DSF best %+v", b)
+	}
+
+	ranks, err = GetLifterPeerRanks(ctx, database, "Gus Aas", EntryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := ranks.CurrentBests[0]; *b.FederationRank != 1 || *b.FederationPeers != 2 || *b.GlobalRank != 3 {
+		t.Errorf("Gus' best %+v", b)
+	}
+}
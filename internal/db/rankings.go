@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RankingSortKeys are the results rankings can be ordered by, with the
+// records column holding each.
+var RankingSortKeys = []struct{ Key, Column string }{
+	{"total", "TotalKg"},
+	{"squat", "Best3SquatKg"},
+	{"bench", "Best3BenchKg"},
+	{"deadlift", "Best3DeadliftKg"},
+	{"dots", "Dots"},
+	{"wilks", "Wilks"},
+	{"glossbrenner", "Glossbrenner"},
+	{"goodlift", "Goodlift"},
+}
+
+// RankingFilter selects the entries a ranking is made of. Empty fields do not
+// restrict it. Year and WindowDays are exclusive: a ranking covers a calendar
+// year or the days up to the latest meet in the database.
+type RankingFilter struct {
+	Sex           string
+	Equipment     string
+	Event         string
+	WeightClassKg string
+	AgeClass      string
+	Country       string
+	Federation    string
+	Year          int
+	WindowDays    int
+	// SortBy is one of the keys of RankingSortKeys, total if empty.
+	SortBy string
+}
+
+// Validate returns an error if the filter names an unknown equipment
+// category, event or sort key, or sets both a year and a window.
+func (f RankingFilter) Validate() error {
+	if err := (EntryFilter{Equipment: f.Equipment, Event: f.Event}).Validate(); err != nil {
+		return err
+	}
+	if _, err := f.sortColumn(); err != nil {
+		return err
+	}
+	if f.Year != 0 && f.WindowDays != 0 {
+		return fmt.Errorf("a ranking covers either a year or a window of days, not both")
+	}
+	if f.WindowDays < 0 {
+		return fmt.Errorf("the window must be a positive number of days")
+	}
+	return nil
+}
+
+// sortColumn returns the records column of the sort key.
+func (f RankingFilter) sortColumn() (string, error) {
+	sortBy := f.SortBy
+	if sortBy == "" {
+		sortBy = "total"
+	}
+	var keys []string
+	for _, k := range RankingSortKeys {
+		if k.Key == sortBy {
+			return k.Column, nil
+		}
+		keys = append(keys, k.Key)
+	}
+	return "", fmt.Errorf("unknown sort key %q, expected one of %s", f.SortBy, strings.Join(keys, ", "))
+}
+
+// where returns the WHERE clause selecting the ranked entries of the records
+// table aliased r: those matching the filter with a positive result, leaving
+// out disqualifications (DQ) and doping disqualifications (DD), whose lifts
+// may be recorded but do not count.
+func (f RankingFilter) where(column string) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	b.WriteString(" WHERE r." + column + " > 0 AND COALESCE(r.Place, '') NOT IN ('DQ', 'DD')")
+	for _, c := range []struct{ column, value string }{
+		{"Sex", f.Sex}, {"Equipment", f.Equipment}, {"Event", f.Event}, {"WeightClassKg", f.WeightClassKg},
+		{"AgeClass", f.AgeClass}, {"Country", f.Country}, {"Federation", f.Federation},
+	} {
+		if c.value != "" {
+			b.WriteString(" AND r." + c.column + " = ?")
+			args = append(args, c.value)
+		}
+	}
+	if f.Year != 0 {
+		b.WriteString(" AND r.Date >= ? AND r.Date < ?")
+		args = append(args, fmt.Sprintf("%04d-01-01", f.Year), fmt.Sprintf("%04d-01-01", f.Year+1))
+	}
+	if f.WindowDays != 0 {
+		b.WriteString(" AND r.Date > date((SELECT MAX(Date) FROM records), ?)")
+		args = append(args, fmt.Sprintf("-%d days", f.WindowDays))
+	}
+	return b.String(), args
+}
+
+// RankingEntry is a lifter's best result in a ranking. Lifters with the same
+// result share a rank, and are listed by who made it first.
+type RankingEntry struct {
+	Rank            int     `json:"rank"`
+	Name            string  `json:"name"`
+	Sex             string  `json:"sex"`
+	Country         string  `json:"country"`
+	Federation      string  `json:"federation"`
+	Equipment       string  `json:"equipment"`
+	Event           string  `json:"event"`
+	WeightClassKg   string  `json:"weightClassKg"`
+	AgeClass        string  `json:"ageClass"`
+	BodyweightKg    float64 `json:"bodyweightKg"`
+	Date            string  `json:"date"`
+	MeetName        string  `json:"meetName"`
+	Best3SquatKg    float64 `json:"best3SquatKg"`
+	Best3BenchKg    float64 `json:"best3BenchKg"`
+	Best3DeadliftKg float64 `json:"best3DeadliftKg"`
+	TotalKg         float64 `json:"totalKg"`
+	Dots            float64 `json:"dots"`
+	Wilks           float64 `json:"wilks"`
+	Glossbrenner    float64 `json:"glossbrenner"`
+	Goodlift        float64 `json:"goodlift"`
+}
+
+// RankingPage is one page of a ranking. Total counts the ranked lifters.
+type RankingPage struct {
+	SortBy   string         `json:"sortBy"`
+	Rankings []RankingEntry `json:"rankings"`
+	Total    int            `json:"total"`
+	Limit    int            `json:"limit"`
+	Offset   int            `json:"offset"`
+}
+
+// GetRankings ranks the lifters with entries matching filter by their best
+// result for its sort key and returns one page of the ranking.
+func GetRankings(ctx context.Context, db *sql.DB, filter RankingFilter, limit, offset int) (*RankingPage, error) {
+	defer observeQuery("GetRankings", time.Now())
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	column, _ := filter.sortColumn()
+	where, args := filter.where(column)
+
+	page := &RankingPage{SortBy: filter.SortBy, Rankings: []RankingEntry{}, Limit: limit, Offset: offset}
+	if page.SortBy == "" {
+		page.SortBy = "total"
+	}
+
+	// Each lifter's best comes first among their entries, the earliest of
+	// equal results
+	query := `
+	WITH bests AS (
+		SELECT * FROM (
+			SELECT r.*, ROW_NUMBER() OVER (PARTITION BY r.Name ORDER BY r.` + column + ` DESC, r.Date, r.ID) AS n
+			FROM records r` + where + `
+		)
+		WHERE n = 1
+	)
+	SELECT RANK() OVER (ORDER BY ` + column + ` DESC) AS Rank,
+		Name, Sex, COALESCE(Country, ''), COALESCE(Federation, ''), Equipment, Event,
+		COALESCE(WeightClassKg, ''), COALESCE(AgeClass, ''), COALESCE(BodyweightKg, 0), Date, COALESCE(MeetName, ''),
+		COALESCE(Best3SquatKg, 0), COALESCE(Best3BenchKg, 0), COALESCE(Best3DeadliftKg, 0), COALESCE(TotalKg, 0),
+		COALESCE(Dots, 0), COALESCE(Wilks, 0), COALESCE(Glossbrenner, 0), COALESCE(Goodlift, 0)
+	FROM bests
+	ORDER BY Rank, Date, Name
+	LIMIT ? OFFSET ?
+	`
+
+	err := withTimeout(ctx, 10*time.Second, "getting rankings", func(ctx context.Context) error {
+		err := db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT r.Name) FROM records r`+where, args...).Scan(&page.Total)
+		if err != nil {
+			return fmt.Errorf("counting ranked lifters: %w", err)
+		}
+
+		rows, err := db.QueryContext(ctx, query, append(args, limit, offset)...)
+		if err != nil {
+			return fmt.Errorf("querying rankings: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e RankingEntry
+			if err := rows.Scan(&e.Rank, &e.Name, &e.Sex, &e.Country, &e.Federation, &e.Equipment, &e.Event,
+				&e.WeightClassKg, &e.AgeClass, &e.BodyweightKg, &e.Date, &e.MeetName,
+				&e.Best3SquatKg, &e.Best3BenchKg, &e.Best3DeadliftKg, &e.TotalKg,
+				&e.Dots, &e.Wilks, &e.Glossbrenner, &e.Goodlift,
+			); err != nil {
+				return fmt.Errorf("scanning ranking: %w", err)
+			}
+			page.Rankings = append(page.Rankings, e)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over ranking rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetRankings(t *testing.T) {
+	entry := func(name, country, date string, bench, total, goodlift float64) *Record {
+		return &Record{
+			Name: name, Sex: "M", Event: "SBD", Equipment: "Raw", Date: date, Country: country, Federation: "DSF",
+			Best3BenchKg: bench, TotalKg: total, Goodlift: goodlift,
+		}
+	}
+	database := newTestDatabase(t, []*Record{
+		entry("Anders Holm", "Denmark", "2023-03-01", 150, 650, 80),
+		entry("Anders Holm", "Denmark", "2024-03-01", 160, 640, 85),
+		entry("Bjørn Dahl", "Norway", "2024-02-01", 140, 650, 82),
+		entry("Carl Berg", "Denmark", "2024-01-01", 170, 650, 90),
+		entry("Dan Ek", "Denmark", "2024-06-01", 130, 600, 75),
+		entry("Erik Lund", "Denmark", "2024-06-01", 0, 0, 0),
+	})
+	ctx := context.Background()
+
+	page, err := GetRankings(ctx, database, RankingFilter{}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.SortBy != "total" || page.Total != 4 || len(page.Rankings) != 4 {
+		t.Fatalf("GetRankings = %+v", page)
+	}
+	// Three lifters tied at 650 are listed by who totalled it first, and
+	// Anders by his first 650 rather than his latest entry
+	for i, want := range []struct {
+		rank int
+		name string
+		date string
+	}{{1, "Anders Holm", "2023-03-01"}, {1, "Carl Berg", "2024-01-01"}, {1, "Bjørn Dahl", "2024-02-01"}, {4, "Dan Ek", "2024-06-01"}} {
+		if e := page.Rankings[i]; e.Rank != want.rank || e.Name != want.name || e.Date != want.date {
+			t.Errorf("ranking %d = %+v, want %+v", i, e, want)
+		}
+	}
+
+	page, err = GetRankings(ctx, database, RankingFilter{Country: "Denmark", Year: 2024, SortBy: "goodlift"}, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Rankings) != 1 || page.Rankings[0].Name != "Anders Holm" || page.Rankings[0].Rank != 2 {
+		t.Errorf("second Danish lifter of 2024 by GL %+v", page)
+	}
+
+	// The window ends with the latest meet, 2024-06-01, and leaves out Carl
+	page, err = GetRankings(ctx, database, RankingFilter{WindowDays: 130, SortBy: "bench"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Rankings[0].Name != "Anders Holm" || page.Rankings[0].Best3BenchKg != 160 {
+		t.Errorf("bench ranking of the window %+v", page)
+	}
+
+	// A disqualified lifter's squat does not count, even if it was made
+	dq := entry("Finn Vik", "Denmark", "2024-06-01", 0, 0, 0)
+	dq.Best3SquatKg, dq.Place = 400, "DQ"
+	dd := entry("Gus Moe", "Denmark", "2024-06-01", 0, 0, 0)
+	dd.Best3SquatKg, dd.Place = 390, "DD"
+	ok := entry("Hal Aas", "Denmark", "2024-06-01", 0, 0, 0)
+	ok.Best3SquatKg, ok.Place = 250, "1"
+	if err := PopulateDatabase(database, []*Record{dq, dd, ok}); err != nil {
+		t.Fatal(err)
+	}
+	page, err = GetRankings(ctx, database, RankingFilter{SortBy: "squat"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Rankings[0].Name != "Hal Aas" {
+		t.Errorf("squat ranking with disqualifications %+v", page)
+	}
+
+	for _, filter := range []RankingFilter{{SortBy: "press"}, {Year: 2024, WindowDays: 30}, {Event: "X"}} {
+		if _, err := GetRankings(ctx, database, filter, 10, 0); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%+v: %v, want ErrInvalidQuery", filter, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// readOnlyDriver is the name of the SQLite driver whose connections refuse
+// everything but reading. It backs the SQL console.
+const readOnlyDriver = "sqlite3_readonly"
+
+// sqliteRecursive is SQLITE_RECURSIVE, which go-sqlite3 does not export. It
+// is authorized for recursive common table expressions.
+const sqliteRecursive = 33
+
+func init() {
+	sql.Register(readOnlyDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if _, err := conn.Exec("PRAGMA query_only = ON", nil); err != nil {
+				return fmt.Errorf("setting query_only: %w", err)
+			}
+			conn.RegisterAuthorizer(authorizeReadOnly)
+			return registerFunctions(conn)
+		},
+	})
+}
+
+// authorizeReadOnly is the SQLite authorizer of read-only connections. It
+// allows only the actions a SELECT needs, so writes, schema changes, PRAGMA,
+// ATTACH and transactions are all denied while the statement is prepared.
+func authorizeReadOnly(action int, arg1, arg2, arg3 string) int {
+	switch action {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_FUNCTION:
+		if strings.EqualFold(arg2, "load_extension") {
+			return sqlite3.SQLITE_DENY
+		}
+		return sqlite3.SQLITE_OK
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// OpenReadOnly opens the database at dbPath on a separate read-only
+// connection pool for the SQL console.
+func OpenReadOnly(dbPath string) (*sql.DB, error) {
+	dsn := "file:" + (&url.URL{Path: dbPath}).EscapedPath() + "?mode=ro"
+	database, err := sql.Open(readOnlyDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening read-only database: %w", err)
+	}
+	return database, nil
+}
+
+// ConsoleOptions limits console queries.
+type ConsoleOptions struct {
+	// Timeout is the statement timeout.
+	Timeout time.Duration
+	// MaxRows caps the number of rows returned; further rows are dropped and
+	// the result is marked truncated.
+	MaxRows int
+}
+
+// DefaultConsoleOptions are the limits used when none are configured.
+var DefaultConsoleOptions = ConsoleOptions{Timeout: 10 * time.Second, MaxRows: 10000}
+
+// RowWriter receives the result of a console query as it is read.
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+}
+
+// RunConsoleQuery runs query, which may be any read-only statement, on a
+// connection from OpenReadOnly and streams its result to w. It reports
+// whether rows were dropped because of opts.MaxRows. Statements that fail to
+// prepare, including those the authorizer denies, return an error wrapping
+// ErrInvalidQuery; running past opts.Timeout returns ErrQueryTimeout.
+func RunConsoleQuery(ctx context.Context, db *sql.DB, query string, opts ConsoleOptions, w RowWriter) (truncated bool, err error) {
+	err = withTimeout(ctx, opts.Timeout, "running console query", func(ctx context.Context) error {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrError || sqliteErr.Code == sqlite3.ErrAuth || sqliteErr.Code == sqlite3.ErrReadonly) {
+				return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+			}
+			return fmt.Errorf("running console query: %w", err)
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return fmt.Errorf("reading console query columns: %w", err)
+		}
+		if err := w.WriteHeader(columns); err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		count := 0
+		for rows.Next() {
+			if count == opts.MaxRows {
+				truncated = true
+				return nil
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return fmt.Errorf("scanning console query row: %w", err)
+			}
+			for i, v := range values {
+				if b, ok := v.([]byte); ok {
+					values[i] = string(b)
+				}
+			}
+			if err := w.WriteRow(values); err != nil {
+				return err
+			}
+			count++
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating over console query rows: %w", err)
+		}
+		return nil
+	})
+	return truncated, err
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectRows is a RowWriter keeping everything in memory.
+type collectRows struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func (c *collectRows) WriteHeader(columns []string) error {
+	c.columns = columns
+	return nil
+}
+
+func (c *collectRows) WriteRow(values []interface{}) error {
+	c.rows = append(c.rows, append([]interface{}{}, values...))
+	return nil
+}
+
+// TestRunConsoleQuery tests that the console reads freely but cannot write,
+// attach or run past its limits.
+func TestRunConsoleQuery(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "console.db")
+	database, err := CreateDatabase(dbPath, false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	defer database.Close()
+	if err := PopulateDatabase(database, []*Record{
+		{Name: "Anna Berg", Sex: "F", TotalKg: 400, Date: "2024-03-01"},
+		{Name: "Bo Dahl", Sex: "M", TotalKg: 600, Date: "2024-05-01"},
+	}); err != nil {
+		t.Fatalf("PopulateDatabase failed: %v", err)
+	}
+
+	readOnly, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer readOnly.Close()
+	ctx := context.Background()
+
+	t.Run("Select", func(t *testing.T) {
+		var w collectRows
+		truncated, err := RunConsoleQuery(ctx, readOnly, "SELECT Name, TotalKg FROM records ORDER BY TotalKg DESC", DefaultConsoleOptions, &w)
+		if err != nil {
+			t.Fatalf("RunConsoleQuery failed: %v", err)
+		}
+		if truncated || len(w.rows) != 2 || w.columns[0] != "Name" || w.rows[0][0] != "Bo Dahl" || w.rows[0][1] != 600.0 {
+			t.Errorf("Unexpected result: %v %v (truncated %v)", w.columns, w.rows, truncated)
+		}
+	})
+
+	t.Run("Aggregate functions", func(t *testing.T) {
+		var w collectRows
+		if _, err := RunConsoleQuery(ctx, readOnly, "SELECT median(TotalKg) FROM records", DefaultConsoleOptions, &w); err != nil {
+			t.Fatalf("RunConsoleQuery failed: %v", err)
+		}
+		if len(w.rows) != 1 || w.rows[0][0] != 500.0 {
+			t.Errorf("Unexpected result: %v", w.rows)
+		}
+	})
+
+	t.Run("Row cap", func(t *testing.T) {
+		var w collectRows
+		opts := ConsoleOptions{Timeout: time.Second, MaxRows: 5}
+		truncated, err := RunConsoleQuery(ctx, readOnly, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n LIMIT 100) SELECT i FROM n", opts, &w)
+		if err != nil {
+			t.Fatalf("RunConsoleQuery failed: %v", err)
+		}
+		if !truncated || len(w.rows) != 5 {
+			t.Errorf("Expected 5 rows and truncation, got %d rows (truncated %v)", len(w.rows), truncated)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		var w collectRows
+		opts := ConsoleOptions{Timeout: 50 * time.Millisecond, MaxRows: 5}
+		_, err := RunConsoleQuery(ctx, readOnly, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n", opts, &w)
+		if !errors.Is(err, ErrQueryTimeout) {
+			t.Errorf("Expected ErrQueryTimeout, got %v", err)
+		}
+	})
+
+	for _, query := range []string{
+		"DELETE FROM records",
+		"INSERT INTO records (ID) VALUES ('x')",
+		"CREATE TABLE evil (x)",
+		"ATTACH DATABASE 'other.db' AS other",
+		"PRAGMA query_only = OFF",
+		"SELECT 1; DELETE FROM records",
+		"SELECT load_extension('evil')",
+	} {
+		t.Run("Denied "+query, func(t *testing.T) {
+			var w collectRows
+			if _, err := RunConsoleQuery(ctx, readOnly, query, DefaultConsoleOptions, &w); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Expected ErrInvalidQuery, got %v", err)
+			}
+		})
+	}
+
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM records").Scan(&count); err != nil || count != 2 {
+		t.Errorf("Expected the records to be untouched, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"liftmetrics/internal/coefficients"
+	"liftmetrics/internal/stats"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the name of the SQLite driver behind every database opened
+// by this package. Its connections have the aggregate functions below.
+const driverName = "sqlite3_liftmetrics"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{ConnectHook: registerFunctions})
+}
+
+// registerFunctions makes the statistical aggregate functions available on
+// conn:
+//
+//	median(x)
+//	percentile_cont(x, p)   the p-th quantile, 0 <= p <= 1, interpolated
+//	stddev(x)               the sample standard deviation
+//	iqr(x)                  the interquartile range
+//	trimmed_mean(x, f)      the mean without the fraction f from each end
+//
+// They ignore NULL and non-numeric values and return NULL when no value is
+// left, or fewer than two for stddev.
+//
+// It also registers the coefficients of the coefficients package as scalar
+// functions:
+//
+//	dots(sex, bodyweight, total)
+//	wilks(sex, bodyweight, total)
+//	glossbrenner(sex, bodyweight, total)
+//	goodlift(sex, equipment, event, bodyweight, total)
+//
+// These return NULL if the bodyweight or total is not a number, and 0 like
+// the coefficients package where the score is undefined.
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	aggregates := map[string]interface{}{
+		"median":          func() *valueAggregate { return &valueAggregate{fn: stats.Median} },
+		"stddev":          func() *valueAggregate { return &valueAggregate{fn: stats.StdDev} },
+		"iqr":             func() *valueAggregate { return &valueAggregate{fn: stats.IQR} },
+		"percentile_cont": newPercentileAggregate,
+		"trimmed_mean":    newTrimmedMeanAggregate,
+	}
+	for name, constructor := range aggregates {
+		if err := conn.RegisterAggregator(name, constructor, true); err != nil {
+			return fmt.Errorf("registering %s: %w", name, err)
+		}
+	}
+
+	scalars := map[string]interface{}{
+		"dots":         sexScore(coefficients.Dots),
+		"wilks":        sexScore(coefficients.Wilks),
+		"glossbrenner": sexScore(coefficients.Glossbrenner),
+		"goodlift": func(sex, equipment, event, bodyweight, total interface{}) interface{} {
+			bw, okBW := number(bodyweight)
+			t, okTotal := number(total)
+			if !okBW || !okTotal {
+				return nil
+			}
+			return coefficients.Goodlift(text(sex), text(equipment), text(event), bw, t)
+		},
+	}
+	for name, fn := range scalars {
+		if err := conn.RegisterFunc(name, fn, true); err != nil {
+			return fmt.Errorf("registering %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sexScore adapts a coefficient depending on sex alone to a SQL function.
+func sexScore(score func(sex string, bodyweightKg, totalKg float64) float64) func(sex, bodyweight, total interface{}) interface{} {
+	return func(sex, bodyweight, total interface{}) interface{} {
+		bw, okBW := number(bodyweight)
+		t, okTotal := number(total)
+		if !okBW || !okTotal {
+			return nil
+		}
+		return score(text(sex), bw, t)
+	}
+}
+
+// number converts an integer or real SQL value to a float64.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// text returns a text SQL value, or "" for any other.
+func text(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// collector accumulates the numeric values an aggregate is given.
+type collector struct {
+	values []float64
+}
+
+func (c *collector) add(v interface{}) {
+	if x, ok := number(v); ok {
+		c.values = append(c.values, x)
+	}
+}
+
+// result converts a statistic to a SQL value, NaN becoming NULL.
+func result(x float64) interface{} {
+	if math.IsNaN(x) {
+		return nil
+	}
+	return x
+}
+
+// valueAggregate is an aggregate of one argument computing fn.
+type valueAggregate struct {
+	collector
+	fn func([]float64) float64
+}
+
+func (a *valueAggregate) Step(v interface{}) { a.add(v) }
+
+func (a *valueAggregate) Done() interface{} { return result(a.fn(a.values)) }
+
+// parameterAggregate is an aggregate whose second argument is a constant
+// parameter, such as the fraction of percentile_cont.
+type parameterAggregate struct {
+	collector
+	param float64
+	// valid reports whether the parameter is in range, and invalid is the
+	// error returned if it is not.
+	valid   func(float64) bool
+	invalid error
+	fn      func([]float64, float64) float64
+}
+
+func newPercentileAggregate() *parameterAggregate {
+	return &parameterAggregate{
+		valid:   func(p float64) bool { return p >= 0 && p <= 1 },
+		invalid: errors.New("percentile_cont: fraction must be between 0 and 1"),
+		fn:      stats.Percentile,
+	}
+}
+
+func newTrimmedMeanAggregate() *parameterAggregate {
+	return &parameterAggregate{
+		valid:   func(f float64) bool { return f >= 0 && f < 0.5 },
+		invalid: errors.New("trimmed_mean: fraction must be at least 0 and below 0.5"),
+		fn:      stats.TrimmedMean,
+	}
+}
+
+func (a *parameterAggregate) Step(v, param interface{}) {
+	a.add(v)
+	var ok bool
+	if a.param, ok = number(param); !ok {
+		a.param = math.NaN()
+	}
+}
+
+func (a *parameterAggregate) Done() (interface{}, error) {
+	if !a.valid(a.param) {
+		return nil, a.invalid
+	}
+	return result(a.fn(a.values, a.param)), nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAggregateFunctions tests the statistical aggregates in SQL, including
+// that they skip NULL and text and return NULL without values.
+func TestAggregateFunctions(t *testing.T) {
+	database, err := OpenDatabase(filepath.Join(t.TempDir(), "functions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.Exec(`CREATE TABLE v (x); INSERT INTO v VALUES (7), (1), (3), (100), (5), (9), (2), (4), (6), (8), (NULL), ('x')`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"median(x)", 5.5},
+		{"percentile_cont(x, 0.1)", 1.9},
+		{"percentile_cont(x, 1)", 100},
+		{"iqr(x)", 4.5},
+		{"trimmed_mean(x, 0.1)", 5.5},
+		{"stddev(x)", 30.1524},
+	}
+	for _, tt := range tests {
+		var got float64
+		if err := database.QueryRow("SELECT " + tt.expr + " FROM v").Scan(&got); err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		} else if got < tt.want-1e-4 || got > tt.want+1e-4 {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	var empty sql.NullFloat64
+	if err := database.QueryRow("SELECT median(x) FROM v WHERE x IS NULL").Scan(&empty); err != nil || empty.Valid {
+		t.Errorf("median of nothing = %v, %v", empty, err)
+	}
+	if err := database.QueryRow("SELECT stddev(x) FROM v WHERE x = 1").Scan(&empty); err != nil || empty.Valid {
+		t.Errorf("stddev of one value = %v, %v", empty, err)
+	}
+	err = database.QueryRow("SELECT percentile_cont(x, 1.5) FROM v").Scan(&empty)
+	if err == nil || !strings.Contains(err.Error(), "between 0 and 1") {
+		t.Errorf("out of range fraction: %v", err)
+	}
+}
+
+// TestCoefficientFunctions tests the coefficients as SQL functions.
+func TestCoefficientFunctions(t *testing.T) {
+	database, err := OpenDatabase(filepath.Join(t.TempDir(), "functions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var dots, goodlift, undefined float64
+	var missing sql.NullFloat64
+	err = database.QueryRow(`SELECT dots('M', 93, 700), goodlift('M', 'Raw', 'SBD', 93.0, 700), wilks(NULL, 93, 700), glossbrenner('F', NULL, 400)`).
+		Scan(&dots, &goodlift, &undefined, &missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dots < 445.37 || dots > 445.38 || goodlift < 91.57 || goodlift > 91.58 || undefined != 0 || missing.Valid {
+		t.Errorf("got dots %v, goodlift %v, wilks %v, glossbrenner %v", dots, goodlift, undefined, missing)
+	}
+}
+
+// TestLiftDistribution tests the average, median and percentile columns of
+// the trend tables, which leave out unsuccessful lifts.
+func TestLiftDistribution(t *testing.T) {
+	database := newTestDatabase(t, []*Record{
+		{Name: "A", Sex: "M", Equipment: "Raw", Best3SquatKg: 100, TotalKg: 300, Date: "2024-01-01"},
+		{Name: "B", Sex: "M", Equipment: "Raw", Best3SquatKg: 200, TotalKg: 500, Date: "2024-02-01"},
+		{Name: "C", Sex: "M", Equipment: "Raw", Best3SquatKg: -150, TotalKg: 0, Date: "2024-03-01"},
+		{Name: "D", Sex: "M", Equipment: "Raw", Best3BenchKg: 100, TotalKg: 100, Date: "2024-04-01"},
+	})
+	ctx := context.Background()
+	fc := &FeatureCalculator{}
+	fc.AddCalculator(&PerformanceTrends{})
+	if err := fc.UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	trends, err := GetPerformanceTrends(ctx, database, AggregateFilter{})
+	if err != nil || len(trends) != 1 {
+		t.Fatalf("GetPerformanceTrends = %v, %v", trends, err)
+	}
+	tr := trends[0]
+	if *tr.AvgSquat != 150 || *tr.MedianSquat != 150 || *tr.P10Squat != 110 || *tr.P90Squat != 190 {
+		t.Errorf("squat avg %v, median %v, P10 %v, P90 %v", *tr.AvgSquat, *tr.MedianSquat, *tr.P10Squat, *tr.P90Squat)
+	}
+	if *tr.MedianTotal != 300 || *tr.P25Total != 200 || *tr.P75Total != 400 {
+		t.Errorf("total median %v, P25 %v, P75 %v", *tr.MedianTotal, *tr.P25Total, *tr.P75Total)
+	}
+	if tr.AvgDeadlift != nil || tr.MedianDeadlift != nil {
+		t.Errorf("deadlift avg %v, median %v, want null", tr.AvgDeadlift, tr.MedianDeadlift)
+	}
+}
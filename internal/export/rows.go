@@ -0,0 +1,122 @@
+// Package export writes query results in the formats offered for download.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// JSONRows streams a table as a JSON object of the form
+// {"columns": [...], "rows": [[...], ...], "truncated": false}. An error that
+// occurs after the header has been written is reported in an "error" member.
+type JSONRows struct {
+	w       io.Writer
+	started bool
+	rows    int
+}
+
+// NewJSONRows returns a JSONRows writing to w.
+func NewJSONRows(w io.Writer) *JSONRows {
+	return &JSONRows{w: w}
+}
+
+// Started reports whether anything has been written yet.
+func (j *JSONRows) Started() bool {
+	return j.started
+}
+
+func (j *JSONRows) WriteHeader(columns []string) error {
+	data, err := json.Marshal(columns)
+	if err != nil {
+		return fmt.Errorf("encoding columns: %w", err)
+	}
+	j.started = true
+	_, err = fmt.Fprintf(j.w, `{"columns":%s,"rows":[`, data)
+	return err
+}
+
+func (j *JSONRows) WriteRow(values []interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("encoding row: %w", err)
+	}
+	if j.rows > 0 {
+		data = append([]byte{','}, data...)
+	}
+	j.rows++
+	_, err = j.w.Write(data)
+	return err
+}
+
+// Finish closes the object, recording whether rows were dropped and queryErr,
+// the error that ended the query early, if any.
+func (j *JSONRows) Finish(truncated bool, queryErr error) error {
+	trailer := map[string]interface{}{"truncated": truncated}
+	if queryErr != nil {
+		trailer["error"] = queryErr.Error()
+	}
+	data, err := json.Marshal(trailer)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.w, "],%s\n", data[1:])
+	return err
+}
+
+// CSVRows streams a table as CSV with a header line.
+type CSVRows struct {
+	w       *csv.Writer
+	started bool
+}
+
+// NewCSVRows returns a CSVRows writing to w.
+func NewCSVRows(w io.Writer) *CSVRows {
+	return &CSVRows{w: csv.NewWriter(w)}
+}
+
+// Started reports whether anything has been written yet.
+func (c *CSVRows) Started() bool {
+	return c.started
+}
+
+func (c *CSVRows) WriteHeader(columns []string) error {
+	c.started = true
+	return c.w.Write(columns)
+}
+
+func (c *CSVRows) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = FormatValue(v)
+	}
+	return c.w.Write(record)
+}
+
+// Finish flushes the buffered rows.
+func (c *CSVRows) Finish() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// FormatValue formats a value read from the database for a text cell. Nulls
+// become empty cells and numbers use the shortest exact representation.
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,43 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestJSONRows tests the streamed JSON object, including a mid-stream error.
+func TestJSONRows(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONRows(&buf)
+	w.WriteHeader([]string{"name", "total"})
+	w.WriteRow([]interface{}{"Anna", 400.5})
+	w.WriteRow([]interface{}{nil, int64(3)})
+	w.Finish(true, errors.New("interrupted"))
+
+	want := `{"columns":["name","total"],"rows":[["Anna",400.5],[null,3]],"error":"interrupted","truncated":true}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Got %s, want %s", buf.String(), want)
+	}
+}
+
+// TestFormatValue tests how database values are written to text cells.
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{632.5, "632.5"},
+		{1e7, "10000000"},
+		{int64(42), "42"},
+		{[]byte("Raw"), "Raw"},
+		{"Søren", "Søren"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatValue(tt.value); got != tt.want {
+			t.Errorf("FormatValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// The download formats offered besides JSON.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ContentTypes maps each download format to its media type.
+var ContentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// TableWriter writes one or more named tables of rows.
+type TableWriter interface {
+	// StartTable begins a table. Rows written after it belong to the table.
+	StartTable(name string, columns []string) error
+	WriteRow(values []interface{}) error
+	// Close finishes the output. It does not close the underlying writer.
+	Close() error
+}
+
+// NewTableWriter returns a TableWriter for format writing to w.
+func NewTableWriter(format string, w io.Writer) (TableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvTables{rows: NewCSVRows(w)}, nil
+	case FormatXLSX:
+		return NewXLSX(w), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// csvTables writes tables as a single CSV file. CSV holds only one table, so
+// tables after the first continue it when they have the same columns and are
+// left out otherwise.
+type csvTables struct {
+	rows    *CSVRows
+	columns []string
+	skip    bool
+}
+
+func (c *csvTables) StartTable(name string, columns []string) error {
+	if c.columns == nil {
+		c.columns = columns
+		return c.rows.WriteHeader(columns)
+	}
+	c.skip = strings.Join(columns, "\x00") != strings.Join(c.columns, "\x00")
+	return nil
+}
+
+func (c *csvTables) WriteRow(values []interface{}) error {
+	if c.skip {
+		return nil
+	}
+	return c.rows.WriteRow(values)
+}
+
+func (c *csvTables) Close() error {
+	return c.rows.Finish()
+}
+
+// WriteStructs writes rows, a slice of structs, as the table name with one
+// column per JSON field. Nil pointers become empty cells and string slices
+// are joined with commas.
+func WriteStructs(w TableWriter, name string, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("exporting %s: expected a slice, got %T", name, rows)
+	}
+	typ := v.Type().Elem()
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("exporting %s: expected a slice of structs, got %T", name, rows)
+	}
+
+	var columns []string
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		columns = append(columns, tag)
+		fields = append(fields, i)
+	}
+
+	if err := w.StartTable(name, columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(fields))
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		for j, field := range fields {
+			values[j] = cellValue(row.Field(field))
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMaps writes rows as the table name with the given columns. Keys
+// missing from a row become empty cells.
+func WriteMaps(w TableWriter, name string, columns []string, rows []map[string]interface{}) error {
+	if err := w.StartTable(name, columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			values[i] = row[column]
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cellValue converts a struct field to a value for WriteRow.
+func cellValue(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = FormatValue(cellValue(v.Index(i)))
+		}
+		return strings.Join(parts, ", ")
+	case reflect.Map, reflect.Struct:
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(data)
+	}
+	return v.Interface()
+}
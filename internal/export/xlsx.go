@@ -0,0 +1,261 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// XLSX streams an Office Open XML workbook with one worksheet per table.
+// Each worksheet is compressed into the archive as its rows are written, so
+// memory use does not grow with the result. Numbers are written as numeric
+// cells and everything else as inline strings; the header row is bold and
+// frozen.
+type XLSX struct {
+	zip    *zip.Writer
+	sheet  *bufio.Writer
+	sheets []string
+	row    int
+}
+
+// NewXLSX returns an XLSX writing to w.
+func NewXLSX(w io.Writer) *XLSX {
+	return &XLSX{zip: zip.NewWriter(w)}
+}
+
+// maxSheetName is the longest worksheet name Excel accepts.
+const maxSheetName = 31
+
+func (x *XLSX) StartTable(name string, columns []string) error {
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+
+	x.sheets = append(x.sheets, x.sheetName(name))
+	f, err := x.zip.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if err != nil {
+		return fmt.Errorf("creating worksheet: %w", err)
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.row = 0
+
+	x.sheet.WriteString(xml.Header)
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	x.sheet.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	x.sheet.WriteString(`</sheetView></sheetViews><sheetData>`)
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c
+	}
+	return x.writeRow(header, ` s="1"`)
+}
+
+func (x *XLSX) WriteRow(values []interface{}) error {
+	if x.sheet == nil {
+		return fmt.Errorf("writing row: no table started")
+	}
+	return x.writeRow(values, "")
+}
+
+func (x *XLSX) writeRow(values []interface{}, style string) error {
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, v := range values {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch n, kind := cellContent(v); kind {
+		case cellEmpty:
+		case cellNumeric:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%s</v></c>`, ref, style, n)
+		case cellBoolean:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s t="b"><v>%s</v></c>`, ref, style, n)
+		default:
+			fmt.Fprintf(x.sheet, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+			xml.EscapeText(x.sheet, []byte(n))
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Close finishes the last worksheet and writes the workbook parts. A
+// workbook needs a worksheet, so an empty one is added if no table was
+// started.
+func (x *XLSX) Close() error {
+	if len(x.sheets) == 0 {
+		if err := x.StartTable("", nil); err != nil {
+			return err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+
+	var sheets, rels, overrides strings.Builder
+	for i, name := range x.sheets {
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeAttr(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	stylesID := len(x.sheets) + 1
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, stylesID) +
+			`</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, part := range parts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(f, xml.Header+part.content); err != nil {
+			return fmt.Errorf("writing %s: %w", part.name, err)
+		}
+	}
+	return x.zip.Close()
+}
+
+// endSheet closes the worksheet being written, if any.
+func (x *XLSX) endSheet() error {
+	if x.sheet == nil {
+		return nil
+	}
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	err := x.sheet.Flush()
+	x.sheet = nil
+	if err != nil {
+		return fmt.Errorf("writing worksheet: %w", err)
+	}
+	return nil
+}
+
+// sheetName makes name a valid worksheet name that is unique in the
+// workbook: characters Excel forbids are replaced, the name is shortened to
+// 31 characters and a number is appended to repeated names.
+func (x *XLSX) sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.Trim(strings.TrimSpace(name), "'"))
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", len(x.sheets)+1)
+	}
+
+	candidate := truncateRunes(name, maxSheetName)
+	for n := 2; x.hasSheet(candidate); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		candidate = truncateRunes(name, maxSheetName-len(suffix)) + suffix
+	}
+	return candidate
+}
+
+func (x *XLSX) hasSheet(name string) bool {
+	for _, s := range x.sheets {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// columnName returns the spreadsheet name of the zero-based column i: A, B,
+// ..., Z, AA, AB and so on.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+type cellKind int
+
+const (
+	cellEmpty cellKind = iota
+	cellNumeric
+	cellBoolean
+	cellString
+)
+
+// cellContent classifies v and returns the text of its cell. Non-finite
+// floats are left empty since spreadsheets cannot represent them.
+func cellContent(v interface{}) (string, cellKind) {
+	switch v := v.(type) {
+	case nil:
+		return "", cellEmpty
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", cellEmpty
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), cellNumeric
+	case float32:
+		return cellContent(float64(v))
+	case int:
+		return strconv.Itoa(v), cellNumeric
+	case int64:
+		return strconv.FormatInt(v, 10), cellNumeric
+	case int32:
+		return strconv.FormatInt(int64(v), 10), cellNumeric
+	case json.Number:
+		if _, err := v.Float64(); err == nil {
+			return v.String(), cellNumeric
+		}
+		return v.String(), cellString
+	case bool:
+		if v {
+			return "1", cellBoolean
+		}
+		return "0", cellBoolean
+	case time.Time:
+		return v.Format(time.RFC3339), cellString
+	}
+	return FormatValue(v), cellString
+}
+
+// escapeAttr escapes s for use in a quoted XML attribute.
+func escapeAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
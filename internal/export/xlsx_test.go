@@ -0,0 +1,90 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestXLSX tests that tables become worksheets with typed cells and that
+// sheet names are made valid and unique.
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXLSX(&buf)
+	w.StartTable("Raw SBD", []string{"name", "totalKg", "dots"})
+	w.WriteRow([]interface{}{"Søren & co", 632.5, nil})
+	w.WriteRow([]interface{}{"Ida", int64(367), 400.25})
+	w.StartTable("Raw SBD", []string{"value"})
+	w.StartTable("a/very:long*sheet?name that goes on", nil)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet3.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("Part %s is missing", name)
+		}
+	}
+
+	workbook := parts["xl/workbook.xml"]
+	for _, want := range []string{`name="Raw SBD"`, `name="Raw SBD (2)"`, `name="a_very_long_sheet_name that goe"`} {
+		if !strings.Contains(workbook, want) {
+			t.Errorf("Workbook lacks sheet %s: %s", want, workbook)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Søren &amp; co</t></is></c><c r="B2"><v>632.5</v></c></row>`,
+		`<c r="B3"><v>367</v></c><c r="C3"><v>400.25</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("Sheet lacks %s: %s", want, sheet)
+		}
+	}
+}
+
+// TestCSVTables tests that CSV continues tables with the same columns and
+// leaves out the others.
+func TestCSVTables(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewTableWriter(FormatCSV, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type row struct {
+		Name   string   `json:"name"`
+		Total  *float64 `json:"totalKg"`
+		Events []string `json:"events"`
+	}
+	total := 632.5
+	WriteStructs(w, "Raw", []row{{"Søren", &total, []string{"SBD", "B"}}})
+	WriteStructs(w, "Wraps", []row{{"Ida", nil, nil}})
+	WriteMaps(w, "Facets", []string{"value", "count"}, []map[string]interface{}{{"value": "M", "count": 1}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name,totalKg,events\nSøren,632.5,\"SBD, B\"\nIda,,\n"
+	if buf.String() != want {
+		t.Errorf("Got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,341 @@
+// Package forecast projects a lifter's progression at one lift forward in
+// time.
+//
+// A lifter's history is a series of points giving the kilos lifted against
+// training age, the years since their first meet. Two models are fitted to
+// it: a Theil–Sen line, robust to the odd bad meet, and a curve that
+// saturates with training age, a - b·exp(-t/τ), used when it fits better by
+// AICc. A population Prior, the typical yearly progression of lifters of the
+// same sex and equipment at each training age, stands in for the trend of
+// lifters with a single meet and pulls the trend of those with few meets
+// towards it.
+package forecast
+
+import (
+	"math"
+
+	"liftmetrics/internal/stats"
+)
+
+// Names of the models a Prediction can come from.
+const (
+	ModelPrior      = "prior"
+	ModelTheilSen   = "theil-sen"
+	ModelSaturating = "saturating"
+)
+
+const (
+	// Confidence is the coverage of the prediction intervals.
+	Confidence = 0.95
+	// z is the normal quantile for Confidence.
+	z = 1.959964
+	// PriorMeets is how many meets the prior trend counts as when it is
+	// blended with the lifter's own.
+	PriorMeets = 3
+	// MinSaturatingMeets is the fewest meets a saturating curve is fitted
+	// to.
+	MinSaturatingMeets = 6
+)
+
+// Point is the best lift of one meet.
+type Point struct {
+	// Years is the training age at the meet.
+	Years float64
+	Kg    float64
+}
+
+// Prior is the progression of a population at one lift.
+type Prior struct {
+	// RateKgPerYear holds the typical progression in kg per year for each
+	// whole year of training age; the last applies to all later years.
+	RateKgPerYear []float64
+	// SpreadKg is the standard deviation of the change from one meet to the
+	// next.
+	SpreadKg float64
+}
+
+// rate returns the progression at training age years, or 0 without a prior.
+func (p Prior) rate(years float64) float64 {
+	if len(p.RateKgPerYear) == 0 {
+		return 0
+	}
+	i := int(math.Max(0, years))
+	if i >= len(p.RateKgPerYear) {
+		i = len(p.RateKgPerYear) - 1
+	}
+	return p.RateKgPerYear[i]
+}
+
+// gain integrates the prior's progression from training age from to to.
+func (p Prior) gain(from, to float64) float64 {
+	var kg float64
+	for from < to {
+		next := math.Min(to, math.Floor(from)+1)
+		kg += p.rate(from) * (next - from)
+		from = next
+	}
+	return kg
+}
+
+// Prediction is a forecast with its prediction interval at Confidence. The
+// bounds are nil when neither the history nor the prior tells the spread,
+// as with a single meet or two meets and a prior without one.
+type Prediction struct {
+	Model   string
+	Kg      float64
+	LowerKg *float64
+	UpperKg *float64
+}
+
+// Predict forecasts the lift at training age years from history, which is
+// ordered by training age. It returns false if history is empty.
+func Predict(history []Point, years float64, prior Prior) (Prediction, bool) {
+	n := len(history)
+	if n == 0 {
+		return Prediction{}, false
+	}
+	if n == 1 {
+		last := history[0]
+		sd := prior.SpreadKg * math.Sqrt(math.Max(1, years-last.Years))
+		return interval(ModelPrior, last.Kg+prior.gain(last.Years, years), sd), true
+	}
+
+	ts := theilSen(history, prior)
+	best := ts
+	if n >= MinSaturatingMeets {
+		if sat, ok := saturating(history); ok && sat.aicc < ts.aicc {
+			best = sat
+		}
+	}
+
+	// Few meets say little about the spread, so that of the prior weighs in.
+	// A change between meets holds the noise of both, hence the halving.
+	sd2 := best.sd * best.sd
+	if prior.SpreadKg > 0 {
+		df := math.Max(float64(n-best.params), 0)
+		sd2 = (df*sd2 + PriorMeets*prior.SpreadKg*prior.SpreadKg/2) / (df + PriorMeets)
+	}
+	return interval(best.name, best.predict(years), math.Sqrt(sd2)*best.leverage(years)), true
+}
+
+// interval returns the prediction of kg with standard error se, without
+// bounds if se is not positive.
+func interval(model string, kg, se float64) Prediction {
+	p := Prediction{Model: model, Kg: kg}
+	if se > 0 {
+		lower, upper := math.Max(0, kg-z*se), kg+z*se
+		p.LowerKg, p.UpperKg = &lower, &upper
+	}
+	return p
+}
+
+// fit is a model fitted to a history: y = a + b·x(t).
+type fit struct {
+	name string
+	// params counts the parameters fitted besides the residual spread.
+	params int
+	x      func(years float64) float64
+	a, b   float64
+	// sd is the residual standard deviation and aicc the corrected Akaike
+	// information criterion of the fit.
+	sd, aicc float64
+	// n, xMean and sxx are the number of points, the mean of x and the sum
+	// of squared deviations from it, for the leverage of predictions.
+	n, xMean, sxx float64
+}
+
+func (f fit) predict(years float64) float64 { return f.a + f.b*f.x(years) }
+
+// leverage returns the factor by which the residual spread grows for a
+// prediction at training age years.
+func (f fit) leverage(years float64) float64 {
+	l := 1 + 1/f.n
+	if f.sxx > 0 {
+		d := f.x(years) - f.xMean
+		l += d * d / f.sxx
+	}
+	return math.Sqrt(l)
+}
+
+// finish computes the spread, criterion and leverage terms of f over points,
+// given the sum of squared residuals.
+func (f *fit) finish(points []Point, sse float64) {
+	n := float64(len(points))
+	f.n = n
+	var xs []float64
+	for _, p := range points {
+		xs = append(xs, f.x(p.Years))
+	}
+	f.xMean = stats.Mean(xs)
+	for _, x := range xs {
+		f.sxx += (x - f.xMean) * (x - f.xMean)
+	}
+	if df := n - float64(f.params); df > 0 {
+		f.sd = math.Sqrt(sse / df)
+	}
+	// The residual spread is a parameter too
+	k := float64(f.params + 1)
+	f.aicc = n*math.Log(math.Max(sse, 1e-9)/n) + 2*k
+	if n-k-1 > 0 {
+		f.aicc += 2 * k * (k + 1) / (n - k - 1)
+	} else {
+		f.aicc = math.Inf(1)
+	}
+}
+
+// theilSen fits a line with the median of the pairwise slopes, pulled
+// towards the prior's progression by PriorMeets meets.
+func theilSen(points []Point, prior Prior) fit {
+	var slopes []float64
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			if dt := points[j].Years - points[i].Years; dt > 0 {
+				slopes = append(slopes, (points[j].Kg-points[i].Kg)/dt)
+			}
+		}
+	}
+	n := float64(len(points))
+	var slope float64
+	if len(slopes) > 0 {
+		slope = stats.Median(slopes)
+	}
+	if len(prior.RateKgPerYear) > 0 {
+		slope = (n*slope + PriorMeets*prior.rate(points[len(points)-1].Years)) / (n + PriorMeets)
+	}
+
+	var intercepts []float64
+	for _, p := range points {
+		intercepts = append(intercepts, p.Kg-slope*p.Years)
+	}
+	f := fit{name: ModelTheilSen, params: 2, x: func(t float64) float64 { return t }, a: stats.Median(intercepts), b: slope}
+	var sse float64
+	for _, p := range points {
+		r := p.Kg - f.predict(p.Years)
+		sse += r * r
+	}
+	f.finish(points, sse)
+	return f
+}
+
+// saturatingTimescales are the τ, in years, the saturating curve is fitted
+// with; the one with the smallest residuals is kept.
+var saturatingTimescales = func() []float64 {
+	var taus []float64
+	for tau := 0.25; tau <= 20; tau *= 1.15 {
+		taus = append(taus, tau)
+	}
+	return taus
+}()
+
+// saturating fits a - b·exp(-t/τ) with b > 0 by least squares for each of
+// saturatingTimescales, reporting false if no curve rises.
+func saturating(points []Point) (fit, bool) {
+	var best fit
+	bestSSE := math.Inf(1)
+	for _, tau := range saturatingTimescales {
+		tau := tau
+		f := fit{name: ModelSaturating, params: 3, x: func(t float64) float64 { return math.Exp(-t / tau) }}
+		var xs, ys []float64
+		for _, p := range points {
+			xs, ys = append(xs, f.x(p.Years)), append(ys, p.Kg)
+		}
+		var ok bool
+		if f.a, f.b, ok = leastSquares(xs, ys); !ok || f.b >= 0 {
+			continue
+		}
+		var sse float64
+		for i := range xs {
+			r := ys[i] - f.predict(points[i].Years)
+			sse += r * r
+		}
+		if sse < bestSSE {
+			best, bestSSE = f, sse
+		}
+	}
+	if math.IsInf(bestSSE, 1) {
+		return fit{}, false
+	}
+	best.finish(points, bestSSE)
+	return best, true
+}
+
+// leastSquares fits y = a + b·x, reporting false if x does not vary.
+func leastSquares(xs, ys []float64) (a, b float64, ok bool) {
+	xMean, yMean := stats.Mean(xs), stats.Mean(ys)
+	var sxy, sxx float64
+	for i := range xs {
+		sxy += (xs[i] - xMean) * (ys[i] - yMean)
+		sxx += (xs[i] - xMean) * (xs[i] - xMean)
+	}
+	if sxx < 1e-12 {
+		return 0, 0, false
+	}
+	b = sxy / sxx
+	return yMean - b*xMean, b, true
+}
+
+// Backtest accumulates forecasts of held-out meets and the lifts actually
+// made there.
+type Backtest struct {
+	forecasts, intervals, covered int
+	absErr, sqErr, naiveAbsErr    float64
+	models                        map[string]int
+}
+
+// Add records prediction of a meet where actual was lifted, and the naive
+// forecast of repeating the previous meet.
+func (b *Backtest) Add(prediction Prediction, actual, previous float64) {
+	if b.models == nil {
+		b.models = make(map[string]int)
+	}
+	b.forecasts++
+	b.models[prediction.Model]++
+	err := prediction.Kg - actual
+	b.absErr += math.Abs(err)
+	b.sqErr += err * err
+	b.naiveAbsErr += math.Abs(previous - actual)
+	if prediction.LowerKg != nil {
+		b.intervals++
+		if actual >= *prediction.LowerKg && actual <= *prediction.UpperKg {
+			b.covered++
+		}
+	}
+}
+
+// Summary describes the accuracy of the forecasts of one lift in a backtest.
+// The errors and coverage are null without forecasts.
+type Summary struct {
+	Lift      string `json:"lift"`
+	Forecasts int    `json:"forecasts"`
+	// MAEKg and RMSEKg are the mean absolute and root mean squared errors,
+	// and NaiveMAEKg the mean absolute error of repeating the previous meet.
+	MAEKg      *float64 `json:"maeKg"`
+	RMSEKg     *float64 `json:"rmseKg"`
+	NaiveMAEKg *float64 `json:"naiveMaeKg"`
+	// Intervals counts the forecasts with a prediction interval, and
+	// Coverage is the fraction of their meets within it, which should be
+	// close to Confidence. It is null without intervals.
+	Intervals int      `json:"intervals"`
+	Coverage  *float64 `json:"coverage"`
+	// Models counts the forecasts made by each model.
+	Models map[string]int `json:"models"`
+}
+
+// Summary summarises the forecasts added to b.
+func (b *Backtest) Summary(lift string) Summary {
+	s := Summary{Lift: lift, Forecasts: b.forecasts, Intervals: b.intervals, Models: map[string]int{}}
+	for model, count := range b.models {
+		s.Models[model] = count
+	}
+	if b.forecasts == 0 {
+		return s
+	}
+	n := float64(b.forecasts)
+	mae, rmse, naive := b.absErr/n, math.Sqrt(b.sqErr/n), b.naiveAbsErr/n
+	s.MAEKg, s.RMSEKg, s.NaiveMAEKg = &mae, &rmse, &naive
+	if b.intervals > 0 {
+		coverage := float64(b.covered) / float64(b.intervals)
+		s.Coverage = &coverage
+	}
+	return s
+}
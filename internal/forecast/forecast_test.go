@@ -0,0 +1,109 @@
+package forecast
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTheilSenIgnoresOutliers(t *testing.T) {
+	history := []Point{{0, 100}, {1, 110}, {2, 120}, {3, 60}, {4, 140}}
+	p, ok := Predict(history, 5, Prior{})
+	if !ok || p.Model != ModelTheilSen || math.Abs(p.Kg-150) > 1e-9 {
+		t.Errorf("Predict = %+v, want 150 kg by %s", p, ModelTheilSen)
+	}
+	if p.LowerKg == nil || !(*p.LowerKg < p.Kg && p.Kg < *p.UpperKg) {
+		t.Errorf("interval %+v does not contain the forecast", p)
+	}
+}
+
+func TestSaturating(t *testing.T) {
+	var history []Point
+	for i := 0; i < 10; i++ {
+		years := float64(i) / 2
+		history = append(history, Point{years, 250 - 100*math.Exp(-years/1.5)})
+	}
+	p, ok := Predict(history, 8, Prior{})
+	if !ok || p.Model != ModelSaturating || math.Abs(p.Kg-250) > 1 {
+		t.Errorf("Predict = %+v, want about 250 kg by %s", p, ModelSaturating)
+	}
+}
+
+// TestPrior tests a single meet projected with the population's progression,
+// which changes with training age.
+func TestPrior(t *testing.T) {
+	prior := Prior{RateKgPerYear: []float64{20, 10}, SpreadKg: 5}
+	p, ok := Predict([]Point{{0.5, 100}}, 2.5, prior)
+	if !ok || p.Model != ModelPrior || p.Kg != 100+10+10+5 {
+		t.Errorf("Predict = %+v, want 125 kg by %s", p, ModelPrior)
+	}
+	if want := z * 5 * math.Sqrt(2); p.UpperKg == nil || math.Abs(*p.UpperKg-p.Kg-want) > 1e-9 {
+		t.Errorf("interval %+v, want a half-width of %v", p, want)
+	}
+
+	// Two meets 30 kg apart in a year, pulled towards the prior's 10 kg a
+	// year, through the median of the intercepts 100 and 130 - 18
+	p, _ = Predict([]Point{{0, 100}, {1, 130}}, 2, prior)
+	if slope := (2*30.0 + 3*10) / 5; math.Abs(p.Kg-(106+2*slope)) > 1e-9 {
+		t.Errorf("Predict = %+v, want %v", p, 106+2*slope)
+	}
+
+	if _, ok := Predict(nil, 1, prior); ok {
+		t.Error("forecast without history")
+	}
+}
+
+// TestNoSpread tests that forecasts claim no interval when nothing tells the
+// spread.
+func TestNoSpread(t *testing.T) {
+	prior := Prior{RateKgPerYear: []float64{10}}
+	for _, history := range [][]Point{{{0, 100}}, {{0, 100}, {1, 120}}} {
+		p, ok := Predict(history, 2, prior)
+		if !ok || p.LowerKg != nil || p.UpperKg != nil {
+			t.Errorf("Predict(%v) = %+v, want no interval", history, p)
+		}
+	}
+
+	var b Backtest
+	b.Add(Prediction{Kg: 100}, 105, 95)
+	if s := b.Summary("Bench"); s.Forecasts != 1 || s.Intervals != 0 || s.Coverage != nil || *s.MAEKg != 5 {
+		t.Errorf("Summary without intervals = %+v", s)
+	}
+}
+
+// TestBacktest holds out the last meet of simulated careers and checks the
+// forecasts beat repeating the previous meet and that the intervals cover
+// about as many meets as they should.
+func TestBacktest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	prior := Prior{RateKgPerYear: []float64{15, 10, 6, 4, 2}, SpreadKg: 6}
+	var b Backtest
+	for lifter := 0; lifter < 500; lifter++ {
+		start, ceiling, tau := 80+40*r.Float64(), 60+60*r.Float64(), 1+2*r.Float64()
+		var history []Point
+		years := 0.0
+		for meet := 0; meet < 3+r.Intn(8); meet++ {
+			kg := start + ceiling*(1-math.Exp(-years/tau)) + 5*r.NormFloat64()
+			history = append(history, Point{years, kg})
+			years += 0.3 + 0.7*r.Float64()
+		}
+		held := history[len(history)-1]
+		p, _ := Predict(history[:len(history)-1], held.Years, prior)
+		b.Add(p, held.Kg, history[len(history)-2].Kg)
+	}
+
+	s := b.Summary("Total")
+	if s.Forecasts != 500 || s.Intervals != 500 || s.Models[ModelTheilSen] == 0 {
+		t.Fatalf("Summary = %+v", s)
+	}
+	if *s.MAEKg >= *s.NaiveMAEKg {
+		t.Errorf("MAE %.2f kg, naive %.2f kg", *s.MAEKg, *s.NaiveMAEKg)
+	}
+	if *s.Coverage < 0.85 || *s.Coverage > 0.99 {
+		t.Errorf("coverage %.3f, want about %v", *s.Coverage, Confidence)
+	}
+
+	if s := (&Backtest{}).Summary("Bench"); s.MAEKg != nil || s.Coverage != nil {
+		t.Errorf("empty Summary = %+v", s)
+	}
+}
@@ -0,0 +1,525 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request. Data is nil if the request could not
+// be executed at all.
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error in a response. Path is set for errors raised while
+// resolving a field.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Limits bound the queries a schema executes. Zero values mean no limit.
+type Limits struct {
+	// MaxDepth is the deepest nesting of selection sets allowed, counting
+	// fragments where they are spread. The document itself may not nest
+	// selection sets, or values and types, any deeper.
+	MaxDepth int
+	// MaxComplexity is the highest estimated number of resolved fields
+	// allowed, see Field.Cost.
+	MaxComplexity int
+}
+
+// Execute validates and runs the query in req.
+func (s *Schema) Execute(ctx context.Context, req Request, limits Limits) *Response {
+	doc, err := parse(req.Query, limits.MaxDepth)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if op.kind != "query" {
+		return errorResponse(fmt.Errorf("%s operations are not supported", op.kind))
+	}
+
+	vars, err := variableValues(op, req.Variables)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	e := &executor{schema: s, doc: doc, vars: vars}
+	complexity, depth, err := e.analyze(s.Query, op.selections, 1, map[string]bool{})
+	if err != nil {
+		return errorResponse(err)
+	}
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return errorResponse(fmt.Errorf("query depth %d exceeds the limit of %d", depth, limits.MaxDepth))
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return errorResponse(fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, limits.MaxComplexity))
+	}
+
+	data := e.executeObjects(ctx, s.Query, []interface{}{nil}, op.selections, nil)
+	return &Response{Data: data[0], Errors: e.errors}
+}
+
+func errorResponse(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// variableValues applies the defaults of op's variable definitions to the
+// given variables and checks that required ones are present.
+func variableValues(op *operation, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{}, len(op.variables))
+	for _, def := range op.variables {
+		v, ok := given[def.name]
+		if !ok && def.defaultValue.kind != valueNull {
+			v, ok = def.defaultValue.goValue(nil), true
+		}
+		if (!ok || v == nil) && def.typ.nonNull {
+			return nil, fmt.Errorf("variable $%s of type %s is required", def.name, def.typ)
+		}
+		if n, isNumber := v.(json.Number); isNumber {
+			v = jsonNumber(n)
+		}
+		vars[def.name] = v
+	}
+	return vars, nil
+}
+
+func jsonNumber(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// executor holds the state of one request.
+type executor struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+// analyze validates the selections on typ and returns their complexity and
+// depth. spreads holds the fragments being expanded, to reject cycles.
+func (e *executor) analyze(typ *Object, selections []selection, depth int, spreads map[string]bool) (complexity, maxDepth int, err error) {
+	maxDepth = depth
+	for _, sel := range selections {
+		var c, d int
+		switch sel := sel.(type) {
+		case *field:
+			c, d, err = e.analyzeField(typ, sel, depth, spreads)
+		case *fragmentSpread:
+			frag, ok := e.doc.fragments[sel.name]
+			if !ok {
+				return 0, 0, fmt.Errorf("unknown fragment %q", sel.name)
+			}
+			if spreads[sel.name] {
+				return 0, 0, fmt.Errorf("fragment %q spreads itself", sel.name)
+			}
+			if frag.typeCondition != typ.Name {
+				return 0, 0, fmt.Errorf("fragment %q on %s cannot be spread on %s", sel.name, frag.typeCondition, typ.Name)
+			}
+			spreads[sel.name] = true
+			c, d, err = e.analyze(typ, frag.selections, depth, spreads)
+			delete(spreads, sel.name)
+		case *inlineFragment:
+			if sel.typeCondition != "" && sel.typeCondition != typ.Name {
+				return 0, 0, fmt.Errorf("inline fragment on %s cannot be used on %s", sel.typeCondition, typ.Name)
+			}
+			c, d, err = e.analyze(typ, sel.selections, depth, spreads)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		complexity = saturatingAdd(complexity, c)
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	return complexity, maxDepth, nil
+}
+
+func (e *executor) analyzeField(typ *Object, f *field, depth int, spreads map[string]bool) (int, int, error) {
+	if f.name == "__typename" {
+		if f.selections != nil {
+			return 0, 0, fmt.Errorf("__typename cannot have a selection set")
+		}
+		return 0, depth, nil
+	}
+
+	def := typ.field(f.name)
+	if def == nil {
+		return 0, 0, fmt.Errorf("type %s has no field %q", typ.Name, f.name)
+	}
+	args, err := e.arguments(def, f)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := e.included(f.directives); err != nil {
+		return 0, 0, err
+	}
+
+	cost := def.Cost
+	if cost == 0 {
+		cost = 1
+	}
+	size := DefaultListSize
+	if limit, ok := args["limit"].(int); ok {
+		if limit < 1 || (def.MaxLimit > 0 && limit > def.MaxLimit) {
+			if def.MaxLimit > 0 {
+				return 0, 0, fmt.Errorf("argument limit of field %s must be between 1 and %d", f.name, def.MaxLimit)
+			}
+			return 0, 0, fmt.Errorf("argument limit of field %s must be at least 1", f.name)
+		}
+		size = limit
+	}
+
+	obj, isObject := unwrap(def.Type).(*Object)
+	switch {
+	case !isObject && f.selections != nil:
+		return 0, 0, fmt.Errorf("field %s.%s of type %s cannot have a selection set", typ.Name, f.name, def.Type)
+	case !isObject:
+		return cost, depth, nil
+	case f.selections == nil:
+		return 0, 0, fmt.Errorf("field %s.%s of type %s needs a selection set", typ.Name, f.name, def.Type)
+	}
+
+	childCost, childDepth, err := e.analyze(obj, f.selections, depth+1, spreads)
+	if err != nil {
+		return 0, 0, err
+	}
+	if isList(def.Type) {
+		childCost = saturatingMul(childCost, size)
+	}
+	return saturatingAdd(cost, childCost), childDepth, nil
+}
+
+// saturatingAdd and saturatingMul add and multiply non-negative
+// complexities, stopping at math.MaxInt instead of overflowing.
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+func isList(t Type) bool {
+	if nn, ok := t.(*NonNull); ok {
+		t = nn.Of
+	}
+	_, ok := t.(*List)
+	return ok
+}
+
+// arguments coerces the arguments of f according to def.
+func (e *executor) arguments(def *Field, f *field) (Args, error) {
+	given := make(map[string]value, len(f.arguments))
+	for _, arg := range f.arguments {
+		given[arg.name] = arg.value
+	}
+
+	args := make(Args, len(def.Args))
+	for _, argDef := range def.Args {
+		v, ok := given[argDef.Name]
+		delete(given, argDef.Name)
+		if ok && v.kind == valueVariable {
+			if _, defined := e.vars[v.text]; !defined {
+				return nil, fmt.Errorf("variable $%s is not defined", v.text)
+			}
+		}
+		if !ok || (v.kind == valueVariable && e.vars[v.text] == nil) {
+			if argDef.Default != nil {
+				args[argDef.Name] = argDef.Default
+				continue
+			}
+			if _, required := argDef.Type.(*NonNull); required {
+				return nil, fmt.Errorf("argument %s of field %s is required", argDef.Name, f.name)
+			}
+			continue
+		}
+		if _, isEnum := unwrap(argDef.Type).(*Enum); isEnum && v.kind == valueString {
+			return nil, fmt.Errorf("argument %s of field %s must be an enum value, not a string", argDef.Name, f.name)
+		}
+
+		c, err := coerce(argDef.Type, v.goValue(e.vars))
+		if err != nil {
+			return nil, fmt.Errorf("argument %s of field %s: %v", argDef.Name, f.name, err)
+		}
+		if c != nil {
+			args[argDef.Name] = c
+		}
+	}
+	for name := range given {
+		return nil, fmt.Errorf("field %s has no argument %q", f.name, name)
+	}
+	return args, nil
+}
+
+// included evaluates the @include and @skip directives.
+func (e *executor) included(directives []*directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "include" && d.name != "skip" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		if len(d.arguments) != 1 || d.arguments[0].name != "if" {
+			return false, fmt.Errorf("@%s takes exactly one argument, if", d.name)
+		}
+		cond, err := coerce(&NonNull{Of: Boolean}, d.arguments[0].value.goValue(e.vars))
+		if err != nil {
+			return false, fmt.Errorf("@%s: %v", d.name, err)
+		}
+		if cond.(bool) == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// collectedField is a response key with all the fields selected under it.
+type collectedField struct {
+	key    string
+	fields []*field
+}
+
+// collectFields flattens fragments and merges fields with the same response
+// key, keeping the order in which keys first appear.
+func (e *executor) collectFields(selections []selection, collected []*collectedField) []*collectedField {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if ok, _ := e.included(sel.directives); !ok {
+				continue
+			}
+			found := false
+			for _, c := range collected {
+				if c.key == sel.responseKey() {
+					c.fields = append(c.fields, sel)
+					found = true
+				}
+			}
+			if !found {
+				collected = append(collected, &collectedField{key: sel.responseKey(), fields: []*field{sel}})
+			}
+		case *fragmentSpread:
+			if ok, _ := e.included(sel.directives); ok {
+				collected = e.collectFields(e.doc.fragments[sel.name].selections, collected)
+			}
+		case *inlineFragment:
+			if ok, _ := e.included(sel.directives); ok {
+				collected = e.collectFields(sel.selections, collected)
+			}
+		}
+	}
+	return collected
+}
+
+// executeObjects resolves selections on every source, all of type typ, and
+// returns one result object per source. Each field is resolved for all
+// sources before moving on to the next level.
+func (e *executor) executeObjects(ctx context.Context, typ *Object, sources []interface{}, selections []selection, path []interface{}) []interface{} {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	results := make([]*orderedObject, len(sources))
+	for i := range results {
+		results[i] = &orderedObject{}
+	}
+
+	for _, cf := range e.collectFields(selections, nil) {
+		first := cf.fields[0]
+		fieldPath := append(append([]interface{}{}, path...), cf.key)
+
+		if first.name == "__typename" {
+			for _, r := range results {
+				r.set(cf.key, typ.Name)
+			}
+			continue
+		}
+
+		def := typ.field(first.name)
+		args, _ := e.arguments(def, first)
+		values, err := e.resolve(ctx, def, sources, args)
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: fieldPath})
+			values = make([]interface{}, len(sources))
+		}
+
+		var subselections []selection
+		for _, f := range cf.fields {
+			subselections = append(subselections, f.selections...)
+		}
+		completed := e.complete(ctx, def.Type, values, subselections, fieldPath)
+		for i, r := range results {
+			r.set(cf.key, completed[i])
+		}
+	}
+
+	out := make([]interface{}, len(results))
+	for i, r := range results {
+		out[i] = r
+	}
+	return out
+}
+
+func (e *executor) resolve(ctx context.Context, def *Field, sources []interface{}, args Args) ([]interface{}, error) {
+	if def.Batch != nil {
+		values, err := def.Batch(ctx, sources, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(sources) {
+			return nil, fmt.Errorf("resolving %s: got %d values for %d objects", def.Name, len(values), len(sources))
+		}
+		return values, nil
+	}
+
+	values := make([]interface{}, len(sources))
+	for i, source := range sources {
+		if def.Resolve == nil {
+			values[i] = defaultResolve(source, def.Name)
+			continue
+		}
+		v, err := def.Resolve(ctx, source, args)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// complete turns resolved values of type typ into response values. Objects
+// at the same level, including the items of all lists, are executed together.
+func (e *executor) complete(ctx context.Context, typ Type, values []interface{}, selections []selection, path []interface{}) []interface{} {
+	switch t := typ.(type) {
+	case *NonNull:
+		return e.complete(ctx, t.Of, values, selections, path)
+
+	case *Object:
+		var present []interface{}
+		for _, v := range values {
+			if v != nil {
+				present = append(present, v)
+			}
+		}
+		executed := e.executeObjects(ctx, t, present, selections, path)
+		out := make([]interface{}, len(values))
+		for i, v := range values {
+			if v != nil {
+				out[i], executed = executed[0], executed[1:]
+			}
+		}
+		return out
+
+	case *List:
+		var items []interface{}
+		lengths := make([]int, len(values))
+		for i, v := range values {
+			if v == nil {
+				lengths[i] = -1
+				continue
+			}
+			list := reflect.ValueOf(v)
+			if list.Kind() != reflect.Slice {
+				lengths[i] = -1
+				e.errors = append(e.errors, &Error{Message: fmt.Sprintf("expected a list, got %T", v), Path: path})
+				continue
+			}
+			lengths[i] = list.Len()
+			for j := 0; j < list.Len(); j++ {
+				items = append(items, list.Index(j).Interface())
+			}
+		}
+
+		completed := e.complete(ctx, t.Of, items, selections, path)
+		out := make([]interface{}, len(values))
+		for i, n := range lengths {
+			switch {
+			case n < 0:
+				out[i] = nil
+			case n == 0:
+				out[i] = []interface{}{}
+			default:
+				out[i], completed = completed[:n:n], completed[n:]
+			}
+		}
+		return out
+	}
+
+	return values
+}
+
+// orderedObject is a response object that keeps its keys in selection order.
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *orderedObject) set(key string, v interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, v)
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,252 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+type testAuthor struct {
+	Name string `json:"name"`
+}
+
+type testBook struct {
+	Title  string `json:"title"`
+	Author string `json:"-"`
+	Pages  int    `json:"pages"`
+}
+
+// newTestSchema returns a schema of authors and books in which the books of
+// all authors at one level are fetched with a single call, counted in calls.
+func newTestSchema(calls *int) *Schema {
+	books := []testBook{
+		{"Dune", "Herbert", 412},
+		{"Children of Dune", "Herbert", 444},
+		{"Emma", "Austen", 474},
+	}
+
+	author := &Object{Name: "Author"}
+	book := &Object{Name: "Book", Fields: []*Field{
+		{Name: "title", Type: &NonNull{Of: String}},
+		{Name: "pages", Type: Int},
+		{Name: "author", Type: author, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return testAuthor{Name: source.(testBook).Author}, nil
+		}},
+	}}
+	author.Fields = []*Field{
+		{Name: "name", Type: &NonNull{Of: String}},
+		{
+			Name: "books",
+			Type: &NonNull{Of: &List{Of: &NonNull{Of: book}}},
+			Args: []*Argument{{Name: "limit", Type: Int, Default: 10}},
+			Batch: func(ctx context.Context, sources []interface{}, args Args) ([]interface{}, error) {
+				*calls++
+				out := make([]interface{}, len(sources))
+				for i, s := range sources {
+					var list []testBook
+					for _, b := range books {
+						if b.Author == s.(testAuthor).Name && len(list) < args.Int("limit") {
+							list = append(list, b)
+						}
+					}
+					out[i] = list
+				}
+				return out, nil
+			},
+		},
+	}
+
+	query := &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "authors",
+			Type: &NonNull{Of: &List{Of: author}},
+			Args: []*Argument{{Name: "order", Type: &Enum{Name: "Order", Values: []string{"ASC", "DESC"}}, Default: "ASC"}},
+			Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				if args.String("order") == "DESC" {
+					return []testAuthor{{"Herbert"}, {"Austen"}}, nil
+				}
+				return []testAuthor{{"Austen"}, {"Herbert"}}, nil
+			},
+		},
+		{
+			Name: "author",
+			Type: author,
+			Args: []*Argument{{Name: "name", Type: &NonNull{Of: String}}},
+			Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				if args.String("name") == "Nobody" {
+					return nil, nil
+				}
+				return testAuthor{Name: args.String("name")}, nil
+			},
+		},
+	}}
+
+	schema, err := NewSchema(query)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+func execute(t *testing.T, s *Schema, req Request, limits Limits) string {
+	t.Helper()
+	data, err := json.Marshal(s.Execute(context.Background(), req, limits))
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	return string(data)
+}
+
+// TestExecute tests query features and that nested lists are batched.
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   Request
+		want  string
+		calls int
+	}{
+		{
+			name: "Nested lists are batched per level",
+			req:  Request{Query: `{ authors { name books { title author { books(limit: 1) { title } } } } }`},
+			want: `{"data":{"authors":[{"name":"Austen","books":[{"title":"Emma","author":{"books":[{"title":"Emma"}]}}]},` +
+				`{"name":"Herbert","books":[{"title":"Dune","author":{"books":[{"title":"Dune"}]}},{"title":"Children of Dune","author":{"books":[{"title":"Dune"}]}}]}]}}`,
+			calls: 2,
+		},
+		{
+			name: "Aliases, fragments, variables and directives",
+			req: Request{
+				Query: `query Q($name: String!, $withPages: Boolean = false, $order: Order) {
+					herbert: author(name: $name) { ...names books(limit: 1) { title pages @include(if: $withPages) } }
+					authors(order: $order) { ... on Author { name } __typename }
+				}
+				fragment names on Author { name }`,
+				Variables: map[string]interface{}{"name": "Herbert", "order": "DESC"},
+			},
+			want:  `{"data":{"herbert":{"name":"Herbert","books":[{"title":"Dune"}]},"authors":[{"name":"Herbert","__typename":"Author"},{"name":"Austen","__typename":"Author"}]}}`,
+			calls: 1,
+		},
+		{
+			name: "Null object",
+			req:  Request{Query: `{ author(name: "Nobody") { name books { title } } }`},
+			want: `{"data":{"author":null}}`,
+		},
+		{
+			name: "Unknown field",
+			req:  Request{Query: `{ authors { age } }`},
+			want: `{"data":null,"errors":[{"message":"type Author has no field \"age\""}]}`,
+		},
+		{
+			name: "String for an enum",
+			req:  Request{Query: `{ authors(order: "DESC") { name } }`},
+			want: `{"data":null,"errors":[{"message":"argument order of field authors must be an enum value, not a string"}]}`,
+		},
+		{
+			name: "Missing required variable",
+			req:  Request{Query: `query ($name: String!) { author(name: $name) { name } }`},
+			want: `{"data":null,"errors":[{"message":"variable $name of type String! is required"}]}`,
+		},
+		{
+			name: "Leaf with selection",
+			req:  Request{Query: `{ authors { name { first } } }`},
+			want: `{"data":null,"errors":[{"message":"field Author.name of type String! cannot have a selection set"}]}`,
+		},
+		{
+			name: "Fragment cycle",
+			req:  Request{Query: `{ authors { ...a } } fragment a on Author { books { author { ...a } } }`},
+			want: `{"data":null,"errors":[{"message":"fragment \"a\" spreads itself"}]}`,
+		},
+		{
+			name: "Mutation",
+			req:  Request{Query: `mutation { authors { name } }`},
+			want: `{"data":null,"errors":[{"message":"mutation operations are not supported"}]}`,
+		},
+		{
+			name: "Syntax error",
+			req:  Request{Query: "{ authors {\n name "},
+			want: `{"data":null,"errors":[{"message":"syntax error at 2:7: unexpected end of document"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got := execute(t, newTestSchema(&calls), tt.req, Limits{})
+			if got != tt.want {
+				t.Errorf("Got  %s\nwant %s", got, tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("Expected %d batch calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+// TestLimits tests the depth and complexity limits.
+func TestLimits(t *testing.T) {
+	var calls int
+	s := newTestSchema(&calls)
+	query := `{ authors { books { author { books { title } } } } }`
+
+	if got := execute(t, s, Request{Query: query}, Limits{MaxDepth: 4}); !strings.Contains(got, "query depth 5 exceeds the limit of 4") {
+		t.Errorf("Expected the depth limit to apply, got %s", got)
+	}
+	// authors: 1 + 10 * (books: 1 + 10 * (author: 1 + books: 1 + 10 * title: 1))
+	if got := execute(t, s, Request{Query: query}, Limits{MaxComplexity: 1000}); !strings.Contains(got, "query complexity 1211 exceeds the limit of 1000") {
+		t.Errorf("Expected the complexity limit to apply, got %s", got)
+	}
+	if got := execute(t, s, Request{Query: query}, Limits{MaxDepth: 5, MaxComplexity: 1211}); strings.Contains(got, "errors") {
+		t.Errorf("Expected the query to run, got %s", got)
+	}
+	if calls != 2 {
+		t.Errorf("Expected only the last query to run, got %d batch calls", calls)
+	}
+
+	// A negative limit must not cancel out the cost of other fields
+	query = `{ authors { a: books(limit: 100) { title } b: books(limit: -100) { title } } }`
+	if got := execute(t, s, Request{Query: query}, Limits{MaxComplexity: 50}); !strings.Contains(got, "argument limit of field books must be at least 1") {
+		t.Errorf("Expected the negative limit to be rejected, got %s", got)
+	}
+	// and products of large limits must not overflow
+	query = `{ authors { books(limit: 2000000000) { author { books(limit: 2000000000) { author { books(limit: 2000000000) { title } } } } } } }`
+	if got := execute(t, s, Request{Query: query}, Limits{MaxComplexity: 1000}); !strings.Contains(got, fmt.Sprintf("query complexity %d exceeds", math.MaxInt)) {
+		t.Errorf("Expected the complexity to saturate, got %s", got)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the rejected queries not to run, got %d batch calls", calls)
+	}
+
+	// Nesting is bounded while parsing, before it can exhaust the stack
+	for query, want := range map[string]string{
+		strings.Repeat("{a", 1<<20):                           "query depth 5 exceeds the limit of 4",
+		"{ authors(x: " + strings.Repeat("[", 1<<20) + ")":    "value depth 5 exceeds the limit of 4",
+		"{ authors(x: " + strings.Repeat("{a:", 1<<20) + ")":  "value depth 5 exceeds the limit of 4",
+		"query($x: " + strings.Repeat("[", 1<<20) + ") { a }": "value depth 5 exceeds the limit of 4",
+	} {
+		if got := execute(t, s, Request{Query: query}, Limits{MaxDepth: 4}); !strings.Contains(got, want) {
+			t.Errorf("Expected %q, got %.200s", want, got)
+		}
+	}
+	// Values may nest as deep as selection sets
+	query = `{ authors { books { author { books(x: [[[{a: [1]}]]]) { title } } } } }`
+	if got := execute(t, s, Request{Query: query}, Limits{MaxDepth: 5}); strings.Contains(got, "depth") {
+		t.Errorf("Expected the query to parse, got %s", got)
+	}
+}
+
+// TestSDL tests that the schema is printed with arguments and defaults.
+func TestSDL(t *testing.T) {
+	var calls int
+	sdl := newTestSchema(&calls).SDL()
+	for _, want := range []string{
+		"type Author {\n  name: String!\n  books(limit: Int = 10): [Book!]!\n}",
+		"enum Order {\n  ASC\n  DESC\n}",
+		"authors(order: Order = ASC): [Author]!",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL is missing %q:\n%s", want, sdl)
+		}
+	}
+}
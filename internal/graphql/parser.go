@@ -0,0 +1,586 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// document is a parsed GraphQL request document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []*variableDefinition
+	selections []selection
+}
+
+type variableDefinition struct {
+	name         string
+	typ          typeRef
+	defaultValue value
+}
+
+// typeRef is a type as written in a variable definition.
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+func (t typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	selections    []selection
+}
+
+// selection is one of *field, *fragmentSpread or *inlineFragment.
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	arguments  []*argument
+	directives []*directive
+	selections []selection
+	pos        int
+}
+
+// responseKey is the name of the field in the response.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []*directive
+	selections    []selection
+}
+
+type argument struct {
+	name  string
+	value value
+}
+
+type directive struct {
+	name      string
+	arguments []*argument
+}
+
+// value is a literal or variable in a document. Scalars keep their source
+// text; the argument type decides how it is interpreted.
+type value struct {
+	kind   valueKind
+	text   string
+	list   []value
+	fields map[string]value
+}
+
+type valueKind int
+
+const (
+	valueNull valueKind = iota
+	valueVariable
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueEnum
+	valueList
+	valueObject
+)
+
+// parser is a recursive descent parser over the lexer's tokens.
+type parser struct {
+	lex *lexer
+	tok token
+
+	// maxDepth bounds the nesting of selection sets, and separately that of
+	// list and object values and list types, so that deeply nested documents
+	// fail before they exhaust the stack. Zero means no limit.
+	maxDepth                   int
+	selectionDepth, valueDepth int
+}
+
+// parse parses a request document, failing if it nests selection sets,
+// values or types deeper than maxDepth, unless that is zero.
+func parse(src string, maxDepth int) (*document, error) {
+	p := &parser{lex: &lexer{src: src}, maxDepth: maxDepth}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"), p.peekName("query"), p.peekName("mutation"), p.peekName("subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peekName("fragment"):
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[frag.name]; ok {
+				return nil, fmt.Errorf("fragment %q is defined more than once", frag.name)
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("the document contains no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokenName && p.tok.value == name
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return p.lex.errorf(p.tok.pos, "unexpected end of document")
+	}
+	return p.lex.errorf(p.tok.pos, "unexpected %q", p.tok.value)
+}
+
+// nest enters one more level of the nesting of what counted by depth,
+// failing past maxDepth. The caller decrements depth when it leaves the level.
+func (p *parser) nest(what string, depth *int) error {
+	*depth++
+	if p.maxDepth > 0 && *depth > p.maxDepth {
+		return p.lex.errorf(p.tok.pos, "%s depth %d exceeds the limit of %d", what, *depth, p.maxDepth)
+	}
+	return nil
+}
+
+func (p *parser) expect(punct string) error {
+	if p.tok.kind == tokenEOF {
+		return p.unexpected()
+	}
+	if !p.peek(punct) {
+		return p.lex.errorf(p.tok.pos, "expected %q, got %q", punct, p.tok.value)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind == tokenEOF {
+		return "", p.unexpected()
+	}
+	if p.tok.kind != tokenName {
+		return "", p.lex.errorf(p.tok.pos, "expected a name, got %q", p.tok.value)
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: "query"}
+	if p.tok.kind == tokenName {
+		op.kind = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenName {
+			op.name = p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("(") {
+			vars, err := p.parseVariableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = vars
+		}
+		if _, err := p.parseDirectives(); err != nil {
+			return nil, err
+		}
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*variableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []*variableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		def := &variableDefinition{name: name, typ: typ}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.defaultValue, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *parser) parseType() (typeRef, error) {
+	var typ typeRef
+	if p.peek("[") {
+		if err := p.nest("value", &p.valueDepth); err != nil {
+			return typ, err
+		}
+		defer func() { p.valueDepth-- }()
+		if err := p.advance(); err != nil {
+			return typ, err
+		}
+		of, err := p.parseType()
+		if err != nil {
+			return typ, err
+		}
+		typ.list = &of
+		if err := p.expect("]"); err != nil {
+			return typ, err
+		}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return typ, err
+		}
+		typ.name = name
+	}
+	if p.peek("!") {
+		typ.nonNull = true
+		return typ, p.advance()
+	}
+	return typ, nil
+}
+
+func (p *parser) parseFragment() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.lex.errorf(p.tok.pos, "a fragment cannot be named \"on\"")
+	}
+	if !p.peekName("on") {
+		return nil, p.lex.errorf(p.tok.pos, "expected \"on\", got %q", p.tok.value)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, typeCondition: typeCondition, selections: selections}, nil
+}
+
+func (p *parser) parseSelectionSet() ([]selection, error) {
+	if err := p.nest("query", &p.selectionDepth); err != nil {
+		return nil, err
+	}
+	defer func() { p.selectionDepth-- }()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.peek("}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, p.lex.errorf(p.tok.pos, "empty selection set")
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) parseSelection() (selection, error) {
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			directives, err := p.parseDirectives()
+			if err != nil {
+				return nil, err
+			}
+			return &fragmentSpread{name: name, directives: directives}, nil
+		}
+
+		inline := &inlineFragment{}
+		if p.peekName("on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			inline.typeCondition = name
+		}
+		var err error
+		if inline.directives, err = p.parseDirectives(); err != nil {
+			return nil, err
+		}
+		if inline.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+		return inline, nil
+	}
+
+	f := &field{pos: p.tok.pos}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+
+	if f.arguments, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if f.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) parseArguments() ([]*argument, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var args []*argument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &argument{name: name, value: v})
+	}
+	return args, p.advance()
+}
+
+func (p *parser) parseDirectives() ([]*directive, error) {
+	var directives []*directive
+	for p.peek("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, &directive{name: name, arguments: args})
+	}
+	return directives, nil
+}
+
+// parseValue parses a value. Variables are not allowed in constant
+// positions such as variable defaults.
+func (p *parser) parseValue(constant bool) (value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return value{}, p.lex.errorf(tok.pos, "variables are not allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return value{}, err
+			}
+			name, err := p.name()
+			return value{kind: valueVariable, text: name}, err
+		case "[":
+			if err := p.nest("value", &p.valueDepth); err != nil {
+				return value{}, err
+			}
+			defer func() { p.valueDepth-- }()
+			if err := p.advance(); err != nil {
+				return value{}, err
+			}
+			v := value{kind: valueList}
+			for !p.peek("]") {
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return value{}, err
+				}
+				v.list = append(v.list, item)
+			}
+			return v, p.advance()
+		case "{":
+			if err := p.nest("value", &p.valueDepth); err != nil {
+				return value{}, err
+			}
+			defer func() { p.valueDepth-- }()
+			if err := p.advance(); err != nil {
+				return value{}, err
+			}
+			v := value{kind: valueObject, fields: make(map[string]value)}
+			for !p.peek("}") {
+				name, err := p.name()
+				if err != nil {
+					return value{}, err
+				}
+				if err := p.expect(":"); err != nil {
+					return value{}, err
+				}
+				if v.fields[name], err = p.parseValue(constant); err != nil {
+					return value{}, err
+				}
+			}
+			return v, p.advance()
+		}
+	case tokenInt:
+		return value{kind: valueInt, text: tok.value}, p.advance()
+	case tokenFloat:
+		return value{kind: valueFloat, text: tok.value}, p.advance()
+	case tokenString:
+		return value{kind: valueString, text: tok.value}, p.advance()
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			return value{kind: valueBoolean, text: tok.value}, p.advance()
+		case "null":
+			return value{kind: valueNull}, p.advance()
+		}
+		return value{kind: valueEnum, text: tok.value}, p.advance()
+	}
+	return value{}, p.unexpected()
+}
+
+// goValue converts v to the Go value of a JSON-decoded variable, resolving
+// variables from vars.
+func (v value) goValue(vars map[string]interface{}) interface{} {
+	switch v.kind {
+	case valueVariable:
+		return vars[v.text]
+	case valueInt:
+		n, _ := strconv.ParseInt(v.text, 10, 64)
+		return n
+	case valueFloat:
+		f, _ := strconv.ParseFloat(v.text, 64)
+		return f
+	case valueString, valueEnum:
+		return v.text
+	case valueBoolean:
+		return v.text == "true"
+	case valueList:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			list[i] = item.goValue(vars)
+		}
+		return list
+	case valueObject:
+		obj := make(map[string]interface{}, len(v.fields))
+		for name, item := range v.fields {
+			obj[name] = item.goValue(vars)
+		}
+		return obj
+	}
+	return nil
+}
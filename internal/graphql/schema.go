@@ -0,0 +1,405 @@
+// Package graphql is a small GraphQL query executor. Schemas are declared in
+// Go and resolvers may resolve a field for all objects at one level of the
+// response in a single call, which keeps nested queries from issuing one
+// database query per object.
+//
+// Queries with variables, aliases, fragments and the @include and @skip
+// directives are supported. Mutations, subscriptions, input objects and
+// introspection are not; the schema is published as SDL instead.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Type is a GraphQL output or argument type: *Scalar, *Enum, *Object, *List
+// or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type. Values are passed through to the JSON encoder.
+type Scalar struct {
+	Name        string
+	Description string
+}
+
+// The built-in scalars.
+var (
+	String  = &Scalar{Name: "String"}
+	Int     = &Scalar{Name: "Int"}
+	Float   = &Scalar{Name: "Float"}
+	Boolean = &Scalar{Name: "Boolean"}
+	ID      = &Scalar{Name: "ID"}
+)
+
+func (s *Scalar) String() string { return s.Name }
+
+// Enum is a leaf type whose values are names. Resolvers and arguments use
+// the names as Go strings.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+// Object is a type with fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+
+	once   sync.Once
+	byName map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// field returns the field called name, or nil.
+func (o *Object) field(name string) *Field {
+	o.once.Do(func() {
+		o.byName = make(map[string]*Field, len(o.Fields))
+		for _, f := range o.Fields {
+			o.byName[f.Name] = f
+		}
+	})
+	return o.byName[name]
+}
+
+// List is a list of another type.
+type List struct {
+	Of Type
+}
+
+func (l *List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull marks a type as never null.
+type NonNull struct {
+	Of Type
+}
+
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// Args holds the coerced arguments of a field: string for String, ID and
+// enums, int for Int, float64 for Float and bool for Boolean. Arguments that
+// were not given and have no default are absent.
+type Args map[string]interface{}
+
+// String returns the string argument name, or "".
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns the integer argument name, or 0.
+func (a Args) Int(name string) int {
+	n, _ := a[name].(int)
+	return n
+}
+
+// Field is a field of an object type.
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+
+	// Resolve returns the value of the field for source. If neither Resolve
+	// nor Batch is set, the value is read from the struct field of source with
+	// a matching json tag, or from the map entry of that name.
+	Resolve func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+
+	// Batch returns the values of the field for all sources at one level of
+	// the response, in order.
+	Batch func(ctx context.Context, sources []interface{}, args Args) ([]interface{}, error)
+
+	// Cost is the complexity of resolving the field once; zero means 1. For
+	// list fields the cost of the selected subfields is multiplied by the
+	// field's "limit" argument, or by DefaultListSize if it has none.
+	Cost int
+
+	// MaxLimit is the largest "limit" argument accepted, checked before
+	// the query runs along with limits below 1; zero means no maximum.
+	MaxLimit int
+}
+
+// Argument is an argument of a field.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     interface{}
+}
+
+// DefaultListSize is the number of items assumed for list fields without a
+// limit argument when computing query complexity.
+const DefaultListSize = 10
+
+// Schema is a set of types reachable from the query root.
+type Schema struct {
+	Query *Object
+	types map[string]Type
+}
+
+// NewSchema checks the types reachable from query and returns the schema.
+func NewSchema(query *Object) (*Schema, error) {
+	s := &Schema{Query: query, types: make(map[string]Type)}
+	for _, scalar := range []*Scalar{String, Int, Float, Boolean, ID} {
+		s.types[scalar.Name] = scalar
+	}
+	if err := s.add(query); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) add(t Type) error {
+	named := unwrap(t)
+	if existing, ok := s.types[named.String()]; ok {
+		if existing != named {
+			return fmt.Errorf("two different types are named %s", named)
+		}
+		return nil
+	}
+	s.types[named.String()] = named
+
+	obj, ok := named.(*Object)
+	if !ok {
+		return nil
+	}
+	if len(obj.Fields) == 0 {
+		return fmt.Errorf("type %s has no fields", obj.Name)
+	}
+	for _, f := range obj.Fields {
+		if f.Resolve != nil && f.Batch != nil {
+			return fmt.Errorf("field %s.%s has both Resolve and Batch", obj.Name, f.Name)
+		}
+		for _, arg := range f.Args {
+			if _, ok := unwrap(arg.Type).(*Object); ok {
+				return fmt.Errorf("argument %s of %s.%s must be a scalar or enum", arg.Name, obj.Name, f.Name)
+			}
+			if err := s.add(arg.Type); err != nil {
+				return err
+			}
+		}
+		if err := s.add(f.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwrap strips List and NonNull from t.
+func unwrap(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.Of
+		case *NonNull:
+			t = w.Of
+		default:
+			return t
+		}
+	}
+}
+
+// SDL returns the schema in the GraphQL schema definition language.
+func (s *Schema) SDL() string {
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n}\n")
+	for _, name := range names {
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			if t != String && t != Int && t != Float && t != Boolean && t != ID {
+				writeDescription(&b, t.Description, "")
+				b.WriteString("\nscalar " + t.Name + "\n")
+			}
+		case *Enum:
+			b.WriteString("\n")
+			writeDescription(&b, t.Description, "")
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				b.WriteString("  " + v + "\n")
+			}
+			b.WriteString("}\n")
+		case *Object:
+			b.WriteString("\n")
+			writeDescription(&b, t.Description, "")
+			b.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				writeDescription(&b, f.Description, "  ")
+				b.WriteString("  " + f.Name)
+				if len(f.Args) > 0 {
+					var args []string
+					for _, a := range f.Args {
+						arg := a.Name + ": " + a.Type.String()
+						if a.Default != nil {
+							arg += " = " + formatDefault(a.Type, a.Default)
+						}
+						args = append(args, arg)
+					}
+					b.WriteString("(" + strings.Join(args, ", ") + ")")
+				}
+				b.WriteString(": " + f.Type.String() + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, description, indent string) {
+	if description != "" {
+		b.WriteString(indent + `"""` + description + `"""` + "\n")
+	}
+}
+
+func formatDefault(typ Type, v interface{}) string {
+	if _, isEnum := unwrap(typ).(*Enum); isEnum {
+		return fmt.Sprint(v)
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// coerce converts v, a literal or variable value in its Go form, to the Go
+// representation of typ described at Args.
+func coerce(typ Type, v interface{}) (interface{}, error) {
+	if nn, ok := typ.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", nn.Of)
+		}
+		return coerce(nn.Of, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := typ.(type) {
+	case *Scalar:
+		switch t {
+		case Int:
+			switch n := v.(type) {
+			case int64:
+				if n >= math.MinInt32 && n <= math.MaxInt32 {
+					return int(n), nil
+				}
+			case float64:
+				if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+					return int(n), nil
+				}
+			}
+			return nil, fmt.Errorf("expected an Int, got %v", v)
+		case Float:
+			switch n := v.(type) {
+			case int64:
+				return float64(n), nil
+			case float64:
+				return n, nil
+			}
+			return nil, fmt.Errorf("expected a Float, got %v", v)
+		case Boolean:
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("expected a Boolean, got %v", v)
+		case ID:
+			switch id := v.(type) {
+			case string:
+				return id, nil
+			case int64:
+				return fmt.Sprint(id), nil
+			}
+			return nil, fmt.Errorf("expected an ID, got %v", v)
+		default:
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("expected a %s, got %v", t.Name, v)
+		}
+	case *Enum:
+		if s, ok := v.(string); ok {
+			for _, allowed := range t.Values {
+				if s == allowed {
+					return s, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("expected one of %s, got %v", strings.Join(t.Values, ", "), v)
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerce(t.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot use %s as an argument type", typ)
+}
+
+// jsonFieldIndex caches, per struct type, the field index of each json tag.
+var jsonFieldIndex sync.Map
+
+// defaultResolve reads the field name from source, a struct, pointer to
+// struct or map[string]interface{}.
+func defaultResolve(source interface{}, name string) interface{} {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name]
+	}
+
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	cached, ok := jsonFieldIndex.Load(v.Type())
+	if !ok {
+		index := make(map[string]int)
+		for i := 0; i < v.NumField(); i++ {
+			tag := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+			if tag != "" && tag != "-" {
+				index[tag] = i
+			}
+		}
+		cached, _ = jsonFieldIndex.LoadOrStore(v.Type(), index)
+	}
+	i, ok := cached.(map[string]int)[name]
+	if !ok {
+		return nil
+	}
+
+	f := v.Field(i)
+	if f.Kind() == reflect.Pointer && f.IsNil() {
+		return nil
+	}
+	return f.Interface()
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("rows", "Rows per table.", "table")
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	info := r.NewGauge("build_info", "Build \\ info.")
+
+	g.Set(3, "records")
+	g.Set(1, `we"ird`)
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+	info.Set(1)
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP rows Rows per table.
+# TYPE rows gauge
+rows{table="records"} 3
+rows{table="we\"ird"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+# HELP build_info Build \\ info.
+# TYPE build_info gauge
+build_info 1
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	r.NewGauge("rows", "again")
+}
@@ -0,0 +1,372 @@
+// Package plugins loads user-defined metrics. Each metric is a YAML or TOML
+// file in a directory, named after the metric, that declares the columns of
+// the metric's table, the tables it reads and the SQL filling the table:
+//
+//	description: Bench press as a share of the total
+//	inputs: [records]
+//	schema: ID TEXT PRIMARY KEY, Name TEXT, BenchShare REAL
+//	sql: |
+//	  INSERT INTO custom_bench_share (ID, Name, BenchShare)
+//	  SELECT ID, Name, Best3BenchKg / TotalKg FROM records WHERE TotalKg > 0
+//
+// The table of a metric named bench_share is custom_bench_share. Metrics
+// run as calculators of the db.FeatureCalculator, after the calculators
+// writing their inputs.
+package plugins
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"liftmetrics/internal/db"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// TablePrefix starts the name of every metric's table, keeping them apart
+// from the built-in tables.
+const TablePrefix = "custom_"
+
+// Definition is a user-defined metric.
+type Definition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Table       string   `json:"table"`
+	Inputs      []string `json:"inputs"`
+	// Columns are the columns of the table, known after DryRun.
+	Columns []string `json:"columns"`
+	// Schema holds the column definitions of the table, as between the
+	// parentheses of CREATE TABLE.
+	Schema string `json:"-"`
+	// SQL fills the table, which is empty when it runs.
+	SQL string `json:"-"`
+	// Path is the file the metric was loaded from.
+	Path string `json:"-"`
+}
+
+// definitionFile is the content of a definition file.
+type definitionFile struct {
+	Description string   `yaml:"description" toml:"description"`
+	Inputs      []string `yaml:"inputs" toml:"inputs"`
+	Schema      string   `yaml:"schema" toml:"schema"`
+	SQL         string   `yaml:"sql" toml:"sql"`
+}
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Load reads the definitions in dir and validates them with DryRun.
+func Load(ctx context.Context, dir string) ([]*Definition, error) {
+	defs, err := LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := DryRun(ctx, defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// LoadDir reads the definitions in dir: the files ending in .yaml, .yml and
+// .toml, ordered by name. Other files are ignored.
+func LoadDir(dir string) ([]*Definition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading metrics directory: %w", err)
+	}
+
+	var defs []*Definition
+	var errs []error
+	names := make(map[string]string)
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".toml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		def, err := LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := names[def.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: metric %s is also defined in %s", path, def.Name, other))
+			continue
+		}
+		names[def.Name] = path
+		defs = append(defs, def)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// LoadFile reads the definition in the file at path. Files ending in .toml
+// are read as TOML and all others as YAML; unknown keys are an error.
+func LoadFile(path string) (*Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening metric definition: %w", err)
+	}
+	defer f.Close()
+
+	var file definitionFile
+	ext := filepath.Ext(path)
+	if strings.EqualFold(ext, ".toml") {
+		dec := toml.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	} else {
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err = dec.Decode(&file); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading metric definition %s: %w", path, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	if file.Inputs == nil {
+		file.Inputs = []string{}
+	}
+	def := &Definition{
+		Name:        name,
+		Description: strings.TrimSpace(file.Description),
+		Table:       TablePrefix + name,
+		Inputs:      file.Inputs,
+		Schema:      strings.TrimSpace(file.Schema),
+		SQL:         strings.TrimSpace(file.SQL),
+		Path:        path,
+	}
+	if err := def.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return def, nil
+}
+
+// validate checks what can be checked without a database.
+func (d *Definition) validate() error {
+	var errs []error
+	if !validName.MatchString(d.Name) {
+		errs = append(errs, fmt.Errorf("metric name %q must be lower case letters, digits and underscores, starting with a letter; it is the file name", d.Name))
+	}
+	if d.Schema == "" {
+		errs = append(errs, errors.New("schema must not be empty"))
+	}
+	// The schema is pasted into CREATE TABLE, which must stay one statement
+	if strings.Contains(d.Schema, ";") {
+		errs = append(errs, errors.New("schema must not contain ';'"))
+	}
+	if d.SQL == "" {
+		errs = append(errs, errors.New("sql must not be empty"))
+	}
+	for _, input := range d.Inputs {
+		if input == d.Table {
+			errs = append(errs, fmt.Errorf("inputs must not list the metric's own table %s", d.Table))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Calculators returns the calculators filling the tables of defs.
+func Calculators(defs []*Definition) []db.MetricCalculator {
+	calcs := make([]db.MetricCalculator, len(defs))
+	for i, d := range defs {
+		calcs[i] = calculator{d}
+	}
+	return calcs
+}
+
+// calculator fills the table of a definition. Its name is the table's.
+type calculator struct {
+	def *Definition
+}
+
+func (c calculator) Name() string      { return c.def.Table }
+func (c calculator) Inputs() []string  { return c.def.Inputs }
+func (c calculator) Outputs() []string { return []string{c.def.Table} }
+
+// Calculate recreates the table, so that edits to the schema apply, and
+// fills it.
+func (c calculator) Calculate(ctx context.Context, tx *sql.Tx) error {
+	if err := c.def.createTable(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, c.def.SQL); err != nil {
+		return fmt.Errorf("calculating metric %s: %w", c.def.Name, err)
+	}
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (d *Definition) createTable(ctx context.Context, tx execer) error {
+	table := `"` + d.Table + `"`
+	if _, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS `+table); err != nil {
+		return fmt.Errorf("dropping table of metric %s: %w", d.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE `+table+` (`+d.Schema+`)`); err != nil {
+		return fmt.Errorf("creating table of metric %s: %w", d.Name, err)
+	}
+	return nil
+}
+
+// DryRun validates defs against a scratch database with the built-in
+// tables, all empty. It runs them in dependency order, checking that each
+// metric's schema and SQL are valid, that its SQL reads only its declared
+// inputs and writes only its own table, and that the inputs exist. It
+// fills in the Columns of each definition.
+func DryRun(ctx context.Context, defs []*Definition) error {
+	dir, err := os.MkdirTemp("", "liftmetrics-dry-run")
+	if err != nil {
+		return fmt.Errorf("creating scratch directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	scratch, err := db.CreateDatabase(filepath.Join(dir, "scratch.db"), false)
+	if err != nil {
+		return fmt.Errorf("creating scratch database: %w", err)
+	}
+	defer scratch.Close()
+
+	// Ordering the metrics with the built-in calculators rejects cycles and
+	// names clashing with built-in calculators
+	fc := db.NewFeatureCalculator()
+	for _, c := range Calculators(defs) {
+		fc.AddCalculator(c)
+	}
+	order, err := fc.Order()
+	if err != nil {
+		return err
+	}
+	byTable := make(map[string]*Definition, len(defs))
+	for _, d := range defs {
+		byTable[d.Table] = d
+	}
+
+	conn, err := scratch.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to scratch database: %w", err)
+	}
+	defer conn.Close()
+
+	var errs []error
+	for _, name := range order {
+		d, ok := byTable[name]
+		if !ok {
+			continue
+		}
+		if err := d.dryRun(ctx, conn); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dryRun creates and fills the table of d on conn, with an authorizer
+// holding the SQL to the declared tables.
+func (d *Definition) dryRun(ctx context.Context, conn *sql.Conn) error {
+	tables := make(map[string]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return fmt.Errorf("listing tables: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables[name] = true
+	}
+	rows.Close()
+	for _, input := range d.Inputs {
+		if !tables[input] {
+			return fmt.Errorf("input %s is neither a built-in table nor the table of another metric", input)
+		}
+	}
+
+	if err := d.createTable(ctx, conn); err != nil {
+		return err
+	}
+
+	var violation error
+	allowed := map[string]bool{d.Table: true}
+	for _, input := range d.Inputs {
+		allowed[input] = true
+	}
+	setAuthorizer(conn, func(action int, arg1, arg2, arg3 string) int {
+		switch action {
+		case sqlite3.SQLITE_SELECT, sqliteRecursive:
+			return sqlite3.SQLITE_OK
+		case sqlite3.SQLITE_READ:
+			// Columns of subqueries and views have no table
+			if arg1 == "" || allowed[arg1] {
+				return sqlite3.SQLITE_OK
+			}
+			violation = fmt.Errorf("sql reads %s, which is not among its inputs", arg1)
+		case sqlite3.SQLITE_INSERT, sqlite3.SQLITE_UPDATE, sqlite3.SQLITE_DELETE:
+			if arg1 == d.Table {
+				return sqlite3.SQLITE_OK
+			}
+			violation = fmt.Errorf("sql writes %s; it may only write %s", arg1, d.Table)
+		case sqlite3.SQLITE_FUNCTION:
+			if !strings.EqualFold(arg2, "load_extension") {
+				return sqlite3.SQLITE_OK
+			}
+			violation = errors.New("sql calls load_extension")
+		default:
+			violation = fmt.Errorf("sql may only insert into, update and delete from %s", d.Table)
+		}
+		return sqlite3.SQLITE_DENY
+	})
+	_, err = conn.ExecContext(ctx, d.SQL)
+	setAuthorizer(conn, nil)
+	if violation != nil {
+		return violation
+	}
+	if err != nil {
+		return fmt.Errorf("sql: %w", err)
+	}
+
+	rows, err = conn.QueryContext(ctx, `SELECT name FROM pragma_table_info(?) ORDER BY cid`, d.Table)
+	if err != nil {
+		return fmt.Errorf("reading columns: %w", err)
+	}
+	defer rows.Close()
+	d.Columns = nil
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return err
+		}
+		d.Columns = append(d.Columns, column)
+	}
+	return rows.Err()
+}
+
+// sqliteRecursive is SQLITE_RECURSIVE, which go-sqlite3 does not export.
+const sqliteRecursive = 33
+
+// setAuthorizer installs fn, or removes the authorizer if fn is nil, on the
+// SQLite connection underlying conn.
+func setAuthorizer(conn *sql.Conn, fn func(int, string, string, string) int) {
+	conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*sqlite3.SQLiteConn).RegisterAuthorizer(fn)
+		return nil
+	})
+}
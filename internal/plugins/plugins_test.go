@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"context"
+	"liftmetrics/internal/db"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeDefinitions(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const benchShare = `description: Bench press as a share of the total
+inputs: [records]
+schema: ID TEXT PRIMARY KEY, Name TEXT, BenchShare REAL
+sql: |
+  INSERT INTO custom_bench_share (ID, Name, BenchShare)
+  SELECT ID, Name, Best3BenchKg / TotalKg FROM records WHERE TotalKg > 0
+`
+
+// TestLoad tests loading definitions in both formats, one depending on the
+// other, and running them with the built-in calculators.
+func TestLoad(t *testing.T) {
+	dir := writeDefinitions(t, map[string]string{
+		"bench_share.yaml": benchShare,
+		"top_bench_share.toml": `
+description = "The highest bench share of each lifter"
+inputs = ["custom_bench_share"]
+schema = "Name TEXT PRIMARY KEY, BenchShare REAL"
+sql = "INSERT INTO custom_top_bench_share SELECT Name, MAX(BenchShare) FROM custom_bench_share GROUP BY Name"
+`,
+		"README.md": "ignored",
+	})
+	ctx := context.Background()
+	defs, err := Load(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Name != "bench_share" || defs[1].Table != "custom_top_bench_share" {
+		t.Fatalf("loaded %+v", defs)
+	}
+	if want := []string{"ID", "Name", "BenchShare"}; !reflect.DeepEqual(defs[0].Columns, want) {
+		t.Errorf("columns %v, want %v", defs[0].Columns, want)
+	}
+
+	database, err := db.CreateDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := db.PopulateDatabase(database, []*db.Record{
+		{Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Best3BenchKg: 50, TotalKg: 250, Date: "2024-03-01"},
+		{Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: "Raw", Best3BenchKg: 60, TotalKg: 240, Date: "2024-06-01"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fc := db.NewFeatureCalculator()
+	for _, c := range Calculators(defs) {
+		fc.AddCalculator(c)
+	}
+	if err := fc.UpdateAllMetrics(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+	page, err := db.QueryTable(ctx, database, "custom_top_bench_share", map[string]string{"Name": "Anna Berg"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Rows[0]["BenchShare"] != 0.25 {
+		t.Errorf("page %+v", page)
+	}
+}
+
+// TestDryRun tests that definitions are rejected for what the dry run can
+// find wrong with them.
+func TestDryRun(t *testing.T) {
+	for _, tc := range []struct {
+		name, definition, want string
+	}{
+		{"undeclared_input", "inputs: []\nschema: N INT\nsql: INSERT INTO custom_undeclared_input SELECT COUNT(*) FROM records\n", "not among its inputs"},
+		{"writes_records", "inputs: [records]\nschema: N INT\nsql: DELETE FROM records\n", "may only write custom_writes_records"},
+		{"unknown_input", "inputs: [nonexistent]\nschema: N INT\nsql: INSERT INTO custom_unknown_input VALUES (1)\n", "neither a built-in table"},
+		{"bad_sql", "inputs: [records]\nschema: N INT\nsql: INSERT INTO custom_bad_sql SELECT NoSuchColumn FROM records\n", "no such column"},
+		{"bad_schema", "inputs: [records]\nschema: N INT); DROP TABLE records; --\nsql: SELECT 1\n", "must not contain ';'"},
+		{"unknown_key", "input: [records]\nschema: N INT\nsql: SELECT 1\n", "field input not found"},
+	} {
+		dir := writeDefinitions(t, map[string]string{tc.name + ".yaml": tc.definition})
+		_, err := Load(context.Background(), dir)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+
+	dir := writeDefinitions(t, map[string]string{
+		"a.yaml": "inputs: [custom_b]\nschema: N INT\nsql: INSERT INTO custom_a SELECT N FROM custom_b\n",
+		"b.yaml": "inputs: [custom_a]\nschema: N INT\nsql: INSERT INTO custom_b SELECT N FROM custom_a\n",
+	})
+	if _, err := Load(context.Background(), dir); err == nil || !strings.Contains(err.Error(), "depend on each other") {
+		t.Errorf("cycle: got %v", err)
+	}
+}
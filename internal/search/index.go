@@ -0,0 +1,149 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Result is a single ranked search hit.
+type Result struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// entry is a lifter name together with its precomputed normalized spellings.
+type entry struct {
+	name     string
+	variants []string
+	tokens   [][]string
+	trigrams []map[string]struct{}
+}
+
+// Index holds a set of lifter names prepared for fuzzy lookup. It is safe for
+// concurrent use once built.
+type Index struct {
+	entries  []entry
+	postings map[string][]int32
+}
+
+// NewIndex builds an Index over the given names.
+func NewIndex(names []string) *Index {
+	idx := &Index{
+		entries:  make([]entry, len(names)),
+		postings: make(map[string][]int32),
+	}
+
+	for i, name := range names {
+		e := entry{name: name, variants: variants(name)}
+		seen := make(map[string]struct{})
+		for _, v := range e.variants {
+			e.tokens = append(e.tokens, strings.Fields(v))
+			tri := trigrams(v)
+			e.trigrams = append(e.trigrams, tri)
+			for t := range tri {
+				if _, ok := seen[t]; ok {
+					continue
+				}
+				seen[t] = struct{}{}
+				idx.postings[t] = append(idx.postings[t], int32(i))
+			}
+		}
+		idx.entries[i] = e
+	}
+
+	return idx
+}
+
+// Len returns the number of names in the index.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Search returns the names matching query, best match first. Matches with
+// equal scores are ordered by name so that results are stable across calls.
+// A limit of zero or less returns every match.
+func (idx *Index) Search(query string, limit int) []Result {
+	qvariants := variants(query)
+	if qvariants[0] == "" {
+		return nil
+	}
+
+	qtokens := make([][]string, len(qvariants))
+	qtrigrams := make([]map[string]struct{}, len(qvariants))
+	candidates := make(map[int32]struct{})
+	for i, q := range qvariants {
+		qtokens[i] = strings.Fields(q)
+		qtrigrams[i] = trigrams(q)
+		for t := range qtrigrams[i] {
+			for _, id := range idx.postings[t] {
+				candidates[id] = struct{}{}
+			}
+		}
+	}
+
+	var results []Result
+	for id := range candidates {
+		e := &idx.entries[id]
+		best := 0.0
+		for qi, q := range qvariants {
+			for ni, n := range e.variants {
+				s := score(q, n, qtokens[qi], e.tokens[ni], qtrigrams[qi], e.trigrams[ni])
+				if s > best {
+					best = s
+				}
+			}
+		}
+		if best > 0 {
+			results = append(results, Result{Name: e.name, Score: best})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// score rates how well the normalized query q matches the normalized name n.
+// Exact, prefix and substring matches rank above token-wise fuzzy matches,
+// which in turn rank above plain trigram overlap.
+func score(q, n string, qtokens, ntokens []string, qtri, ntri map[string]struct{}) float64 {
+	switch {
+	case q == n:
+		return 1
+	case strings.HasPrefix(n, q):
+		return 0.95
+	case strings.Contains(n, q):
+		return 0.85
+	}
+
+	total := 0.0
+	for _, qt := range qtokens {
+		best := 0.0
+		for _, nt := range ntokens {
+			if s := tokenSimilarity(qt, nt); s > best {
+				best = s
+			}
+		}
+		if best == 0 {
+			total = 0
+			break
+		}
+		total += best
+	}
+	if total > 0 {
+		return 0.4 + 0.4*total/float64(len(qtokens))
+	}
+
+	if sim := trigramSimilarity(qtri, ntri); sim >= 0.35 {
+		return 0.5 * sim
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package search
+
+import "strings"
+
+// editDistance returns the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and adjacent
+// transpositions needed to turn one into the other. It gives up and returns
+// max+1 as soon as the distance is known to exceed max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+// allowedEdits is how many typos a query token of the given length may contain.
+func allowedEdits(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// tokenSimilarity scores how well a query token matches a name token, between
+// 0 and 1. A query token may be an unfinished prefix of the name token, and
+// may contain a few typos in either case.
+func tokenSimilarity(query, token string) float64 {
+	if query == token {
+		return 1
+	}
+	if strings.HasPrefix(token, query) {
+		return 0.9
+	}
+
+	qlen := len([]rune(query))
+	max := allowedEdits(qlen)
+	if max == 0 {
+		return 0
+	}
+
+	best := max + 1
+	if d := editDistance(query, token, max); d < best {
+		best = d
+	}
+	// Compare against the token's prefix so that "orbe" still finds "ørbæk".
+	if rt := []rune(token); len(rt) > qlen {
+		if d := editDistance(query, string(rt[:qlen]), max); d < best {
+			best = d
+		}
+	}
+	if best > max {
+		return 0
+	}
+
+	return 0.8 * (1 - float64(best)/float64(qlen))
+}
+
+// trigrams returns the set of padded character trigrams of s.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(s) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// trigramSimilarity returns the Jaccard similarity of the trigram sets of a and b.
+func trigramSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
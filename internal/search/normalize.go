@@ -0,0 +1,106 @@
+// Package search provides accent- and typo-tolerant matching of lifter names.
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldings maps letters that Unicode decomposition leaves untouched to their
+// closest plain Latin spelling. Everything not listed here is handled by NFKD
+// decomposition followed by stripping the combining marks.
+var foldings = map[rune]string{
+	'ø': "o", 'æ': "ae", 'œ': "oe", 'ß': "ss", 'ł': "l", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ı': "i", 'ŋ': "n", 'ħ': "h", 'ŧ': "t", 'ĸ': "k", 'ſ': "s",
+	'ƒ': "f", 'ĳ': "ij",
+}
+
+// digraphs holds the alternative spellings commonly used when a keyboard lacks
+// the letter, e.g. "Mueller" for "Müller" or "Aagaard" for "Ågaard".
+var digraphs = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'å': "aa", 'ø': "oe", 'æ': "ae",
+}
+
+// cyrillic transliterates Cyrillic letters following the common passport style.
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'у': "u", 'ў': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+}
+
+// greek transliterates Greek letters following ELOT 743.
+var greek = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// greekDigraphs are the letter pairs ELOT 743 transliterates as a unit.
+var greekDigraphs = strings.NewReplacer("ου", "ou", "ού", "ou")
+
+// Normalize prepares a name for comparison. It lowercases s, transliterates
+// Cyrillic and Greek, folds diacritics ("Søren Ørbæk" becomes "soren orbaek"),
+// turns punctuation into spaces and collapses runs of whitespace.
+func Normalize(s string) string {
+	return fold(s, nil)
+}
+
+// variants returns the normalized spellings of s that should all be considered
+// a match: the plain folding plus, when it differs, the digraph spelling.
+func variants(s string) []string {
+	plain := fold(s, nil)
+	alt := fold(s, digraphs)
+	if alt == plain {
+		return []string{plain}
+	}
+	return []string{plain, alt}
+}
+
+func fold(s string, extra map[rune]string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	// Digraph replacements have to be looked up before decomposition strips
+	// the umlaut or ring from the base letter.
+	for _, r := range greekDigraphs.Replace(strings.ToLower(norm.NFC.String(s))) {
+		if rep, ok := extra[r]; ok {
+			b.WriteString(rep)
+			continue
+		}
+		writeFolded(&b, r)
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func writeFolded(b *strings.Builder, r rune) {
+	if rep, ok := foldings[r]; ok {
+		b.WriteString(rep)
+		return
+	}
+	if rep, ok := cyrillic[r]; ok {
+		b.WriteString(rep)
+		return
+	}
+
+	for _, d := range norm.NFKD.String(string(r)) {
+		switch {
+		case unicode.Is(unicode.Mn, d):
+			// Combining mark left over from decomposition, e.g. the ring in å.
+		case d == '\'' || d == '’' || d == '`':
+			// Apostrophes are dropped so "O'Neil" and "ONeil" compare equal.
+		case greek[d] != "":
+			b.WriteString(greek[d])
+		case unicode.IsLetter(d) || unicode.IsDigit(d):
+			b.WriteRune(unicode.ToLower(d))
+		default:
+			b.WriteByte(' ')
+		}
+	}
+}
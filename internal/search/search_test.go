@@ -0,0 +1,132 @@
+package search
+
+import (
+	"testing"
+)
+
+// TestNormalize checks diacritic folding and transliteration of tricky names.
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Danish letters", "Søren Ørbæk", "soren orbaek"},
+		{"German umlaut", "Jürgen Müller", "jurgen muller"},
+		{"German sharp s", "Strauß", "strauss"},
+		{"Swedish ring", "Åsa Öberg", "asa oberg"},
+		{"Icelandic letters", "Þórður Guðmundsson", "thordur gudmundsson"},
+		{"Polish stroke", "Łukasz Wójcik", "lukasz wojcik"},
+		{"Decomposed input", "Sñchez", "snchez"},
+		{"Apostrophes and hyphens", "Mary O'Neil-Smith", "mary oneil smith"},
+		{"Extra whitespace", "  John   Doe ", "john doe"},
+		{"Disambiguation suffix", "John Smith #2", "john smith 2"},
+		{"Cyrillic", "Андрей Малиничев", "andrey malinichev"},
+		{"Ukrainian", "Олександр Їжак", "oleksandr yizhak"},
+		{"Greek with tonos", "Γιώργος Παπαδόπουλος", "giorgos papadopoulos"},
+		{"Fullwidth Latin", "ＪＯＨＮ", "john"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEditDistance checks the bounded optimal string alignment distance.
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"soren", "soren", 2, 0},
+		{"soren", "sorne", 2, 1},
+		{"muller", "mueller", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 1, 2},
+		{"abc", "abcdef", 1, 2},
+		{"ørbæk", "orbæk", 1, 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+// TestSearch checks that tricky queries find the intended lifter first.
+func TestSearch(t *testing.T) {
+	idx := NewIndex([]string{
+		"Søren Ørbæk",
+		"Soren Orbeck",
+		"Jürgen Müller",
+		"Thomas Mueller",
+		"Åse Aagaard",
+		"Andrey Malinichev",
+		"Ray Williams",
+		"Rayna Williamson",
+		"Jonas Rantanen",
+		"John Smith #1",
+		"John Smith #2",
+		"Li Wei",
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"Exact diacritics", "Søren Ørbæk", "Søren Ørbæk"},
+		{"Plain ASCII", "soren orbaek", "Søren Ørbæk"},
+		{"Mixed case and spacing", "  SØREN   ørbæk ", "Søren Ørbæk"},
+		{"Digraph spelling", "Juergen Mueller", "Jürgen Müller"},
+		{"Folded umlaut", "muller", "Jürgen Müller"},
+		{"Scandinavian double a", "Ase Agaard", "Åse Aagaard"},
+		{"Typo in surname", "Malinichv", "Andrey Malinichev"},
+		{"Transposed letters", "Andery Malinichev", "Andrey Malinichev"},
+		{"Cyrillic query", "Малиничев", "Andrey Malinichev"},
+		{"Reversed token order", "williams ray", "Ray Williams"},
+		{"Unfinished prefix", "ray will", "Ray Williams"},
+		{"Prefix with typo", "jnoas rant", "Jonas Rantanen"},
+		{"Short name", "li", "Li Wei"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := idx.Search(tt.query, 5)
+			if len(results) == 0 {
+				t.Fatalf("Search(%q) returned no results", tt.query)
+			}
+			if results[0].Name != tt.want {
+				t.Errorf("Search(%q) top result = %q, want %q (all: %v)", tt.query, results[0].Name, tt.want, results)
+			}
+		})
+	}
+
+	t.Run("Ranking", func(t *testing.T) {
+		results := idx.Search("john smith", 0)
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %v", results)
+		}
+		if results[0].Name != "John Smith #1" || results[1].Name != "John Smith #2" {
+			t.Errorf("Equal scores should be ordered by name, got %v", results)
+		}
+	})
+
+	t.Run("No match", func(t *testing.T) {
+		if results := idx.Search("zzzzqqq", 5); len(results) != 0 {
+			t.Errorf("Expected no results, got %v", results)
+		}
+	})
+
+	t.Run("Empty query", func(t *testing.T) {
+		if results := idx.Search("  ", 5); results != nil {
+			t.Errorf("Expected nil for an empty query, got %v", results)
+		}
+	})
+}
@@ -3,11 +3,15 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gocarina/gocsv"
 	"liftmetrics/internal/db"
+	"liftmetrics/internal/metrics"
 	"liftmetrics/pkg"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -23,6 +27,18 @@ var (
 	ErrCalculationFailed = errors.New("failed to perform database calculations")
 )
 
+var (
+	phaseDuration = metrics.Default.NewGauge("liftmetrics_ingest_phase_duration_seconds",
+		"Duration of each phase of the last ingest: check, download, extract, parse, populate and calculate.", "phase")
+	lastIngest = metrics.Default.NewGauge("liftmetrics_ingest_last_success_timestamp_seconds",
+		"Unix time the last ingest of this process completed.")
+)
+
+// observePhase records the duration of an ingest phase begun at start.
+func observePhase(phase string, start time.Time) {
+	phaseDuration.Set(time.Since(start).Seconds(), phase)
+}
+
 // SetupDatabase handles the entire process of checking for updates, downloading,
 // extracting, and setting up the database if necessary.
 //
@@ -32,12 +48,15 @@ var (
 //   - filePath: The path where the downloaded ZIP file should be saved.
 //   - dataDir: The directory where the CSV should be extracted.
 //   - dbFilePath: The path where the database file should be created.
+//   - extra: Calculators to run after the built-in ones, such as user-defined metrics.
 //
 // Returns:
 //   - error: An error if any step of the process fails, nil otherwise.
-func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string) error {
+func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string, extra []db.MetricCalculator) error {
 	// Check if we need to update the database
-	needsUpdate, err := checkForUpdate(dataDir, websiteURL)
+	start := time.Now()
+	needsUpdate, err := CheckForUpdate(dataDir, websiteURL)
+	observePhase("check", start)
 	if err != nil {
 		log.Printf("Failed to check for updates: %v", err)
 		return err
@@ -55,16 +74,21 @@ func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string) er
 	defer cancel()
 
 	// Download the ZIP file containing the new data
+	start = time.Now()
 	if err := DownloadFile(ctx, dataURL, filePath); err != nil {
 		log.Printf("Error downloading file: %v", err)
 		return ErrDownloadFailed
 	}
 
+	observePhase("download", start)
+
 	// Extract the CSV file from the downloaded ZIP
+	start = time.Now()
 	if _, err := ExtractCSVFromZip(filePath, dataDir); err != nil {
 		log.Printf("Failed to extract CSV: %v", err)
 		return ErrExtractFailed
 	}
+	observePhase("extract", start)
 
 	// Locate the extracted CSV file
 	csvFilePath, err := pkg.FindCSVFile(dataDir)
@@ -73,6 +97,30 @@ func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string) er
 		return ErrCSVNotFound
 	}
 
+	return ImportCSV(ctx, csvFilePath, dbFilePath, extra)
+}
+
+// ImportFile builds the database at dbFilePath from a local copy of the data:
+// either a CSV file or a ZIP archive, which is extracted into dataDir.
+func ImportFile(ctx context.Context, path, dataDir, dbFilePath string, extra []db.MetricCalculator) error {
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		return ImportCSV(ctx, path, dbFilePath, extra)
+	}
+
+	start := time.Now()
+	csvFilePath, err := ExtractCSVFromZip(path, dataDir)
+	if err != nil {
+		log.Printf("Failed to extract CSV: %v", err)
+		return ErrExtractFailed
+	}
+	observePhase("extract", start)
+	return ImportCSV(ctx, csvFilePath, dbFilePath, extra)
+}
+
+// ImportCSV replaces the database at dbFilePath with one built from the CSV
+// file at csvFilePath, calculates the metrics, including those of the extra
+// calculators, and records the revision of the file.
+func ImportCSV(ctx context.Context, csvFilePath, dbFilePath string, extra []db.MetricCalculator) error {
 	// Open the CSV file for reading
 	csvFile, err := os.Open(csvFilePath)
 	if err != nil {
@@ -82,14 +130,17 @@ func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string) er
 	defer csvFile.Close() // Ensure the file is closed when we're done
 
 	// Parse the CSV file into a slice of Record structs
+	start := time.Now()
 	var records []*db.Record
 	if err := gocsv.UnmarshalFile(csvFile, &records); err != nil {
 		log.Printf("Failed to parse CSV file: %v", err)
 		return ErrCSVParseFailed
 	}
+	observePhase("parse", start)
 
 	// Create a new database or open an existing one
 	log.Println("Setting up the database. This might take a while!")
+	start = time.Now()
 	database, err := db.CreateDatabase(dbFilePath, true)
 	if err != nil {
 		log.Printf("Failed to create database: %v", err)
@@ -102,22 +153,147 @@ func SetupDatabase(dataURL, websiteURL, filePath, dataDir, dbFilePath string) er
 		log.Printf("Failed to populate database: %v", err)
 		return ErrDBPopulateFailed
 	}
+	observePhase("populate", start)
 
 	// Calculate and update successful attempts for each lift type
 	log.Println("\nCalculating new metrics for the database!")
-	fc := db.NewFeatureCalculator()
-	if err := fc.UpdateAllMetrics(ctx, database); err != nil {
+	start = time.Now()
+	fc := newFeatureCalculator(extra)
+	err = fc.UpdateAllMetrics(ctx, database)
+	logCalculatorRuns(fc.Runs())
+	if err != nil {
 		log.Printf("Failed to update metrics in database: %v", err)
 		return ErrCalculationFailed
 	}
+	observePhase("calculate", start)
+
+	// Record the revision so that cached responses from older data are dropped
+	revision, err := getCSVRevision(csvFilePath)
+	if err != nil {
+		log.Printf("Recording the database without a revision: %v", err)
+	}
+	if err := db.SetDataRevision(ctx, database, revision); err != nil {
+		log.Printf("Failed to record data revision: %v", err)
+		return ErrDBPopulateFailed
+	}
 
+	lastIngest.Set(float64(time.Now().Unix()))
 	log.Println("All setup and calculations complete!")
 	return nil
 }
 
-// checkForUpdate compares the local CSV revision with the website revision.
+// RecalculateMetrics runs the metric calculations again on the existing
+// database at dbFilePath, without downloading or importing anything. With a
+// calculator name, only that calculator and those depending on it run. The
+// revision is recorded again so that cached responses are dropped.
+func RecalculateMetrics(ctx context.Context, dbFilePath, calculator string, extra []db.MetricCalculator) error {
+	database, err := db.OpenDatabase(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer database.Close()
+
+	start := time.Now()
+	fc := newFeatureCalculator(extra)
+	if calculator == "" {
+		err = fc.UpdateAllMetrics(ctx, database)
+	} else {
+		err = fc.Recompute(ctx, database, calculator)
+	}
+	logCalculatorRuns(fc.Runs())
+	if err != nil {
+		log.Printf("Failed to update metrics in database: %v", err)
+		return ErrCalculationFailed
+	}
+	observePhase("calculate", start)
+
+	rev, err := db.GetDataRevision(ctx, database)
+	if err != nil && !errors.Is(err, db.ErrNoRows) {
+		return err
+	}
+	return db.SetDataRevision(ctx, database, rev.Revision)
+}
+
+// CalculateMissing runs those of the extra calculators whose output tables
+// are missing from the database at dbFilePath, and the calculators depending
+// on them. It brings an existing database up to date with newly added
+// user-defined metrics.
+func CalculateMissing(ctx context.Context, dbFilePath string, extra []db.MetricCalculator) error {
+	if len(extra) == 0 {
+		return nil
+	}
+	database, err := db.OpenDatabase(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer database.Close()
+
+	tables, err := db.ListTables(ctx, database)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		exists[t] = true
+	}
+
+	outputs := make(map[string][]string, len(extra))
+	for _, calc := range extra {
+		outputs[calc.Name()] = calc.Outputs()
+	}
+
+	fc := newFeatureCalculator(extra)
+	for _, calc := range extra {
+		missing := false
+		for _, t := range calc.Outputs() {
+			missing = missing || !exists[t]
+		}
+		if !missing {
+			continue
+		}
+		log.Printf("Calculating %s, which the database lacks", calc.Name())
+		err := fc.Recompute(ctx, database, calc.Name())
+		logCalculatorRuns(fc.Runs())
+		if err != nil {
+			log.Printf("Failed to calculate %s: %v", calc.Name(), err)
+			return ErrCalculationFailed
+		}
+		// Dependents that were missing too are now calculated
+		for _, run := range fc.Runs() {
+			for _, t := range outputs[run.Name] {
+				exists[t] = true
+			}
+		}
+	}
+	return nil
+}
+
+// newFeatureCalculator returns the built-in calculators followed by extra.
+func newFeatureCalculator(extra []db.MetricCalculator) *db.FeatureCalculator {
+	fc := db.NewFeatureCalculator()
+	for _, calc := range extra {
+		fc.AddCalculator(calc)
+	}
+	return fc
+}
+
+// logCalculatorRuns logs the outcome and duration of each calculator.
+func logCalculatorRuns(runs []db.CalculatorRun) {
+	for _, run := range runs {
+		switch run.Status {
+		case db.RunFailed:
+			log.Printf("Calculator %s failed after %s: %s", run.Name, run.Duration.Round(time.Millisecond), run.Error)
+		case db.RunSkipped:
+			log.Printf("Calculator %s skipped", run.Name)
+		default:
+			log.Printf("Calculator %s took %s", run.Name, run.Duration.Round(time.Millisecond))
+		}
+	}
+}
+
+// CheckForUpdate compares the local CSV revision with the website revision.
 // It returns true if an update is needed, false otherwise.
-func checkForUpdate(dataDir, websiteURL string) (bool, error) {
+func CheckForUpdate(dataDir, websiteURL string) (bool, error) {
 	// Attempt to find the local CSV file
 	csvFilePath, err := pkg.FindCSVFile(dataDir)
 	if err != nil {
@@ -0,0 +1,83 @@
+// Package stats implements the descriptive statistics used by the
+// calculators and exposed to SQL as aggregate functions.
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Mean returns the arithmetic mean of values, or NaN if there are none.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// StdDev returns the sample standard deviation of values, or NaN if there
+// are fewer than two.
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	mean := Mean(values)
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)-1))
+}
+
+// Percentile returns the p-th quantile of values, 0 <= p <= 1, interpolating
+// linearly between the closest ranks like SQL's PERCENTILE_CONT. It returns
+// NaN if there are no values or p is out of range. values is not modified.
+func Percentile(values []float64, p float64) float64 {
+	return PercentileSorted(sorted(values), p)
+}
+
+// PercentileSorted is Percentile for values already in ascending order.
+func PercentileSorted(values []float64, p float64) float64 {
+	if len(values) == 0 || !(p >= 0 && p <= 1) {
+		return math.NaN()
+	}
+	rank := p * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	if lower == len(values)-1 {
+		return values[lower]
+	}
+	return values[lower] + (rank-float64(lower))*(values[lower+1]-values[lower])
+}
+
+// Median returns the median of values, or NaN if there are none.
+func Median(values []float64) float64 {
+	return Percentile(values, 0.5)
+}
+
+// IQR returns the interquartile range of values, or NaN if there are none.
+func IQR(values []float64) float64 {
+	s := sorted(values)
+	return PercentileSorted(s, 0.75) - PercentileSorted(s, 0.25)
+}
+
+// TrimmedMean returns the mean of values after dropping the given fraction,
+// 0 <= fraction < 0.5, from each end. It returns NaN if there are no values
+// or fraction is out of range.
+func TrimmedMean(values []float64, fraction float64) float64 {
+	if !(fraction >= 0 && fraction < 0.5) {
+		return math.NaN()
+	}
+	s := sorted(values)
+	trim := int(fraction * float64(len(s)))
+	return Mean(s[trim : len(s)-trim])
+}
+
+func sorted(values []float64) []float64 {
+	s := append([]float64(nil), values...)
+	sort.Float64s(s)
+	return s
+}
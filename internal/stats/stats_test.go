@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStatistics(t *testing.T) {
+	values := []float64{7, 1, 3, 100, 5, 9, 2, 4, 6, 8}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"Mean", Mean(values), 14.5},
+		{"Median", Median(values), 5.5},
+		{"Median odd", Median([]float64{3, 1, 2}), 2},
+		{"P0", Percentile(values, 0), 1},
+		{"P10", Percentile(values, 0.1), 1.9},
+		{"P90", Percentile(values, 0.9), 18.1},
+		{"P100", Percentile(values, 1), 100},
+		{"IQR", IQR(values), 7.75 - 3.25},
+		{"TrimmedMean", TrimmedMean(values, 0.1), 5.5},
+		{"TrimmedMean none", TrimmedMean(values, 0), 14.5},
+		{"StdDev", StdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9}), math.Sqrt(32.0 / 7)},
+		{"single", Percentile([]float64{42}, 0.25), 42},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if values[0] != 7 {
+		t.Error("input was reordered")
+	}
+}
+
+func TestUndefined(t *testing.T) {
+	for name, got := range map[string]float64{
+		"Mean empty":        Mean(nil),
+		"Median empty":      Median(nil),
+		"IQR empty":         IQR(nil),
+		"StdDev single":     StdDev([]float64{1}),
+		"Percentile range":  Percentile([]float64{1, 2}, 1.5),
+		"Percentile NaN":    Percentile([]float64{1, 2}, math.NaN()),
+		"TrimmedMean range": TrimmedMean([]float64{1, 2}, 0.5),
+		"TrimmedMean empty": TrimmedMean(nil, 0.1),
+	} {
+		if !math.IsNaN(got) {
+			t.Errorf("%s = %v, want NaN", name, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"liftmetrics/internal/db"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// aggregateEndpoint describes one of the routes serving an aggregate table.
+type aggregateEndpoint struct {
+	// what names the table in error messages.
+	what string
+	// dimensions are the JSON fields identifying a row; all other fields are
+	// metrics.
+	dimensions []string
+	// series are the dimensions spread into columns by default in the wide shape.
+	series []string
+	// filters are the query parameters the table supports.
+	filters []string
+	// paginated tables take limit and offset parameters, counting lifters.
+	paginated bool
+	fetch     func(ctx context.Context, database *sql.DB, filter db.AggregateFilter) (interface{}, error)
+}
+
+var (
+	weightClassDistributionEndpoint = aggregateEndpoint{
+		what:       "weight class distribution",
+		dimensions: []string{"year", "weightClass", "sex", "equipment"},
+		series:     []string{"sex", "equipment"},
+		filters:    []string{"sex", "equipment", "yearFrom", "yearTo"},
+		fetch: func(ctx context.Context, database *sql.DB, f db.AggregateFilter) (interface{}, error) {
+			return db.GetWeightClassDistribution(ctx, database, f)
+		},
+	}
+	ageGroupPerformanceEndpoint = aggregateEndpoint{
+		what:       "age group performance",
+		dimensions: []string{"year", "ageClass", "sex", "equipment"},
+		series:     []string{"sex", "equipment"},
+		filters:    []string{"sex", "equipment", "yearFrom", "yearTo"},
+		fetch: func(ctx context.Context, database *sql.DB, f db.AggregateFilter) (interface{}, error) {
+			return db.GetAgeGroupPerformance(ctx, database, f)
+		},
+	}
+	performanceTrendsEndpoint = aggregateEndpoint{
+		what:       "performance trends",
+		dimensions: []string{"year", "sex", "equipment"},
+		series:     []string{"sex", "equipment"},
+		filters:    []string{"sex", "equipment", "yearFrom", "yearTo"},
+		fetch: func(ctx context.Context, database *sql.DB, f db.AggregateFilter) (interface{}, error) {
+			return db.GetPerformanceTrends(ctx, database, f)
+		},
+	}
+	aggregatedMetricsSBDEndpoint = aggregateEndpoint{
+		what:       "aggregated SBD metrics",
+		dimensions: []string{"name", "equipment"},
+		series:     []string{"equipment"},
+		filters:    []string{"name", "equipment"},
+		paginated:  true,
+		fetch: func(ctx context.Context, database *sql.DB, f db.AggregateFilter) (interface{}, error) {
+			return db.GetAggregatedMetricsSBD(ctx, database, f)
+		},
+	}
+	aggregatedMetricsBenchEndpoint = aggregateEndpoint{
+		what:       "aggregated bench metrics",
+		dimensions: []string{"name", "equipment"},
+		series:     []string{"equipment"},
+		filters:    []string{"name", "equipment"},
+		paginated:  true,
+		fetch: func(ctx context.Context, database *sql.DB, f db.AggregateFilter) (interface{}, error) {
+			return db.GetAggregatedMetricsBench(ctx, database, f)
+		},
+	}
+)
+
+// filename is the name of the table's downloads.
+func (ep aggregateEndpoint) filename() string {
+	return strings.ReplaceAll(ep.what, " ", "-")
+}
+
+// allAggregateFilters lists every filter parameter any aggregate route knows.
+var allAggregateFilters = []string{"sex", "equipment", "name", "yearFrom", "yearTo"}
+
+// wideTable is the wide shape of an aggregate table: one row per combination
+// of the index dimensions, with a column per series value and metric named
+// "<series values>.<metric>", e.g. "M.Raw.avgTotal".
+type wideTable struct {
+	Index  []string                 `json:"index"`
+	Series []string                 `json:"series"`
+	Rows   []map[string]interface{} `json:"rows"`
+}
+
+// handleAggregate returns a handler serving the table described by ep.
+func (s *Server) handleAggregate(ep aggregateEndpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := aggregateFilterFromQuery(c, ep.filters)
+		if err != nil {
+			respondBadRequest(c, err.Error())
+			return
+		}
+		if ep.paginated {
+			if filter.Limit, filter.Offset, err = paginationFromQuery(c); err != nil {
+				respondBadRequest(c, err.Error())
+				return
+			}
+		}
+		format, ok := exportFormat(c)
+		if !ok {
+			return
+		}
+
+		rows, err := ep.fetch(c, s.DB, filter)
+		if err != nil {
+			respondDBError(c, err, ep.what)
+			return
+		}
+
+		switch shape := c.DefaultQuery("shape", "long"); shape {
+		case "long":
+			if format != formatJSON {
+				respondExport(c, format, ep.filename(), exportTable{name: ep.what, rows: rows})
+				return
+			}
+			c.JSON(http.StatusOK, rows)
+		case "wide":
+			series := ep.series
+			if v := c.Query("pivot"); v != "" {
+				series = strings.Split(v, ",")
+			}
+			table, err := pivotWide(rows, ep.dimensions, series)
+			if err != nil {
+				respondBadRequest(c, err.Error())
+				return
+			}
+			if format != formatJSON {
+				respondExport(c, format, ep.filename(), wideTableExport(ep.what, table))
+				return
+			}
+			c.JSON(http.StatusOK, table)
+		default:
+			respondBadRequest(c, fmt.Sprintf("unknown shape %q, expected long or wide", shape))
+		}
+	}
+}
+
+// aggregateFilterFromQuery reads the filters in allowed from the query string
+// and rejects filters the table does not support.
+func aggregateFilterFromQuery(c *gin.Context, allowed []string) (db.AggregateFilter, error) {
+	var filter db.AggregateFilter
+
+	for _, name := range allAggregateFilters {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		if !slices.Contains(allowed, name) {
+			return filter, fmt.Errorf("filter %q is not supported here; supported filters: %s", name, strings.Join(allowed, ", "))
+		}
+
+		switch name {
+		case "sex":
+			filter.Sex = value
+		case "equipment":
+			filter.Equipment = value
+		case "name":
+			filter.Name = value
+		case "yearFrom", "yearTo":
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a year", name)
+			}
+			if name == "yearFrom" {
+				filter.YearFrom = year
+			} else {
+				filter.YearTo = year
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// pivotWide converts rows, a slice of structs, into the wide shape by
+// spreading the series dimensions and all metrics into columns.
+func pivotWide(rows interface{}, dimensions, series []string) (wideTable, error) {
+	for _, dim := range series {
+		if !slices.Contains(dimensions, dim) {
+			return wideTable{}, fmt.Errorf("cannot pivot on %q; dimensions are %s", dim, strings.Join(dimensions, ", "))
+		}
+	}
+
+	var index []string
+	for _, dim := range dimensions {
+		if !slices.Contains(series, dim) {
+			index = append(index, dim)
+		}
+	}
+
+	records, err := toRecords(rows)
+	if err != nil {
+		return wideTable{}, err
+	}
+
+	table := wideTable{Index: index, Series: series, Rows: []map[string]interface{}{}}
+	byKey := make(map[string]map[string]interface{})
+	for _, rec := range records {
+		var key []string
+		for _, dim := range index {
+			key = append(key, fmt.Sprint(rec[dim]))
+		}
+		row, ok := byKey[strings.Join(key, "\x00")]
+		if !ok {
+			row = make(map[string]interface{})
+			for _, dim := range index {
+				row[dim] = rec[dim]
+			}
+			byKey[strings.Join(key, "\x00")] = row
+			table.Rows = append(table.Rows, row)
+		}
+
+		var prefix []string
+		for _, dim := range series {
+			prefix = append(prefix, fmt.Sprint(rec[dim]))
+		}
+		for field, value := range rec {
+			if !slices.Contains(dimensions, field) {
+				row[strings.Join(append(prefix, field), ".")] = value
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// toRecords converts a slice of structs into maps keyed by their JSON names.
+func toRecords(rows interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
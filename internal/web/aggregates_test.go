@@ -0,0 +1,115 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// TestPivotWide tests spreading series dimensions into columns.
+func TestPivotWide(t *testing.T) {
+	kg := func(x float64) *float64 { return &x }
+	rows := []db.PerformanceTrend{
+		{Year: 2023, Sex: "M", Equipment: "Raw", AvgTotal: kg(600)},
+		{Year: 2023, Sex: "F", Equipment: "Raw", AvgTotal: kg(350)},
+		{Year: 2024, Sex: "M", Equipment: "Raw", AvgTotal: kg(610)},
+	}
+
+	table, err := pivotWide(rows, performanceTrendsEndpoint.dimensions, []string{"sex", "equipment"})
+	if err != nil {
+		t.Fatalf("pivotWide failed: %v", err)
+	}
+
+	if len(table.Rows) != 2 {
+		t.Fatalf("Expected one row per year, got %v", table.Rows)
+	}
+	if got := table.Rows[0]["F.Raw.avgTotal"]; got != json.Number("350") {
+		t.Errorf("Expected F.Raw.avgTotal 350 in 2023, got %v", got)
+	}
+	if _, ok := table.Rows[1]["F.Raw.avgTotal"]; ok {
+		t.Errorf("Expected no F.Raw.avgTotal column in 2024, got %v", table.Rows[1])
+	}
+
+	if _, err := pivotWide(rows, performanceTrendsEndpoint.dimensions, []string{"avgTotal"}); err == nil {
+		t.Error("Expected an error when pivoting on a metric")
+	}
+}
+
+// TestAggregateRoutes tests filtering and shapes on the aggregate routes.
+func TestAggregateRoutes(t *testing.T) {
+	s := newTestServer(t, testRecords)
+
+	t.Run("Long shape with filters", func(t *testing.T) {
+		w := get(t, s, "/api/v1/aggregates/performance-trends?sex=M&yearFrom=2024")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var trends []db.PerformanceTrend
+		if err := json.Unmarshal(w.Body.Bytes(), &trends); err != nil {
+			t.Fatalf("Failed to decode trends: %v", err)
+		}
+		if len(trends) != 1 || trends[0].Year != 2024 || *trends[0].AvgTotal != 632.5 {
+			t.Errorf("Unexpected trends: %+v", trends)
+		}
+	})
+
+	t.Run("Wide shape", func(t *testing.T) {
+		w := get(t, s, "/api/v1/aggregates/weight-class-distribution?shape=wide")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var table wideTable
+		if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+			t.Fatalf("Failed to decode table: %v", err)
+		}
+		if len(table.Rows) != 2 || table.Rows[0]["M.Raw.count"] != float64(1) {
+			t.Errorf("Unexpected table: %+v", table)
+		}
+	})
+
+	t.Run("Unsupported filter", func(t *testing.T) {
+		if w := get(t, s, "/api/v1/aggregates/lifter-metrics/sbd?sex=M"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d: %s", w.Code, w.Body)
+		}
+	})
+}
+
+// TestLifterMetricsPages tests that the per-lifter tables are paged by
+// lifter, each with all of their rows.
+func TestLifterMetricsPages(t *testing.T) {
+	records := append([]*db.Record{}, testRecords...)
+	for _, equipment := range []string{"Raw", "Wraps"} {
+		records = append(records, &db.Record{
+			Name: "Anna Berg", Sex: "F", Event: "SBD", Equipment: equipment, BodyweightKg: 63, Date: "2024-03-01",
+			Squat1Kg: 120, Best3SquatKg: 120, Bench1Kg: 70, Best3BenchKg: 70, Deadlift1Kg: 150, Best3DeadliftKg: 150, TotalKg: 340,
+		})
+	}
+	s := newTestServer(t, records)
+
+	for query, want := range map[string][]string{
+		"limit=1":          {"Anna Berg", "Anna Berg"},
+		"limit=1&offset=1": {"Søren Ørbæk"},
+		"offset=2":         {},
+	} {
+		w := get(t, s, "/api/v1/aggregates/lifter-metrics/sbd?"+query)
+		var rows []db.LifterAggregateSBD
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("%s: failed to decode rows: %v", query, err)
+		}
+		var names []string
+		for _, r := range rows {
+			names = append(names, r.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("%s: lifters %v, want %v", query, names, want)
+		}
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "offset=-1"} {
+		if w := get(t, s, "/api/v1/aggregates/lifter-metrics/bench?"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, w.Code, w.Body)
+		}
+	}
+}
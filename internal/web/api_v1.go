@@ -0,0 +1,163 @@
+package web
+
+import (
+	_ "embed"
+	"liftmetrics/internal/db"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the published description of the /api/v1 routes. The tests
+// check that it documents exactly the routes registered below.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// registerV1Routes sets up the versioned API under the given group.
+func (s *Server) registerV1Routes(v1 *gin.RouterGroup) {
+	v1.GET("/openapi.json", s.handleOpenAPI)
+	v1.GET("/lifters", s.handleListLifters)
+	v1.GET("/lifters/:name", s.handleLifterProfile)
+	v1.GET("/lifters/:name/details", s.handleV1LifterDetails)
+	v1.GET("/lifters/:name/progression", s.handleLifterProgression)
+	v1.GET("/lifters/:name/stats", s.handleLifterStats)
+	v1.GET("/lifters/:name/attempt-strategy", s.handleLifterAttemptStrategy)
+	v1.GET("/lifters/:name/attempt-recommendation", s.handleAttemptRecommendation)
+	v1.GET("/lifters/:name/forecast", s.handleLifterForecast)
+	v1.GET("/lifters/:name/peer-ranks", s.handleLifterPeerRanks)
+	v1.GET("/entries", s.handleQueryEntries)
+	v1.GET("/entries/fields", s.handleEntryFields)
+	v1.POST("/sql", s.handleSQLConsole)
+	v1.GET("/custom-metrics", s.handleListCustomMetrics)
+	v1.GET("/custom-metrics/:metric", s.handleCustomMetric)
+	v1.GET("/coefficients", s.handleCoefficients)
+	v1.GET("/attempt-strategy", s.handleAttemptStrategy)
+	v1.GET("/forecasts/backtest", s.handleForecastBacktest)
+	v1.GET("/rankings", s.handleRankings)
+
+	schema := s.graphQLSchema()
+	v1.GET("/graphql", s.handleGraphQL(schema))
+	v1.POST("/graphql", s.handleGraphQL(schema))
+	v1.GET("/graphql/schema", handleGraphQLSchema(schema))
+
+	aggregates := v1.Group("/aggregates")
+	aggregates.GET("/weight-class-distribution", s.handleAggregate(weightClassDistributionEndpoint))
+	aggregates.GET("/age-group-performance", s.handleAggregate(ageGroupPerformanceEndpoint))
+	aggregates.GET("/performance-trends", s.handleAggregate(performanceTrendsEndpoint))
+	aggregates.GET("/lifter-metrics/sbd", s.handleAggregate(aggregatedMetricsSBDEndpoint))
+	aggregates.GET("/lifter-metrics/bench", s.handleAggregate(aggregatedMetricsBenchEndpoint))
+}
+
+func (s *Server) handleOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
+
+func (s *Server) handleLifterProfile(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	profile, err := db.GetLifterProfile(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) handleV1LifterDetails(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	grouped, ok := groupFromQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	details, err := db.GetLifterDetails(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter details")
+		return
+	}
+
+	if format != formatJSON {
+		respondDetailsExport(c, format, c.Param("name"), details)
+		return
+	}
+
+	if !grouped {
+		c.JSON(http.StatusOK, details)
+		return
+	}
+	c.JSON(http.StatusOK, groupEntries(details, func(d db.LifterDetails) (string, string) {
+		return d.Equipment, d.Event
+	}))
+}
+
+func (s *Server) handleLifterProgression(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	grouped, ok := groupFromQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	performances, err := db.GetLifterPerformanceOverTime(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter progression")
+		return
+	}
+
+	if format != formatJSON {
+		respondExport(c, format, c.Param("name")+" progression", groupTables(performances, func(p db.LifterPerformance) (string, string) {
+			return p.Equipment, p.Event
+		})...)
+		return
+	}
+
+	if !grouped {
+		c.JSON(http.StatusOK, performances)
+		return
+	}
+	c.JSON(http.StatusOK, groupEntries(performances, func(p db.LifterPerformance) (string, string) {
+		return p.Equipment, p.Event
+	}))
+}
+
+func (s *Server) handleLifterStats(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	stats, err := db.GetLifterStats(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter stats")
+		return
+	}
+
+	if format != formatJSON {
+		respondExport(c, format, c.Param("name")+" stats", exportTable{name: "Stats", rows: stats})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
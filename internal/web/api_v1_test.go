@@ -0,0 +1,291 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"liftmetrics/frontend"
+	"liftmetrics/internal/db"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testRecords are the entries loaded into the database of newTestServer.
+var testRecords = []*db.Record{
+	{
+		Name: "Søren Ørbæk", Sex: "M", Event: "SBD", Equipment: "Raw", BodyweightKg: 82, Date: "2023-03-01",
+		MeetName: "Spring Open", Country: "Denmark", Federation: "DSF",
+		Squat1Kg: 200, Squat2Kg: 210, Squat3Kg: -215, Best3SquatKg: 210,
+		Bench1Kg: 130, Bench2Kg: 135, Bench3Kg: 140, Best3BenchKg: 140,
+		Deadlift1Kg: 250, Deadlift2Kg: 260, Deadlift3Kg: 270, Best3DeadliftKg: 270, TotalKg: 620,
+	},
+	{
+		Name: "Søren Ørbæk", Sex: "M", Event: "SBD", Equipment: "Raw", BodyweightKg: 83, Date: "2024-03-01",
+		MeetName: "Nationals", Country: "Denmark", Federation: "DSF",
+		Squat1Kg: 205, Squat2Kg: 215, Squat3Kg: 222.5, Best3SquatKg: 222.5,
+		Bench1Kg: 135, Bench2Kg: -140, Bench3Kg: 140, Best3BenchKg: 140,
+		Deadlift1Kg: 260, Deadlift2Kg: 270, Deadlift3Kg: -280, Best3DeadliftKg: 270, TotalKg: 632.5,
+	},
+}
+
+// newTestServer returns a server backed by a database holding records. With
+// nil records the server has no database, which is enough for route checks.
+func newTestServer(t *testing.T, records []*db.Record) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	if records == nil {
+		return newServer(t, nil, nil)
+	}
+
+	database, err := db.CreateDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if err := db.PopulateDatabase(database, records); err != nil {
+		t.Fatalf("PopulateDatabase failed: %v", err)
+	}
+	if err := db.NewFeatureCalculator().UpdateAllMetrics(context.Background(), database); err != nil {
+		t.Fatalf("UpdateAllMetrics failed: %v", err)
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range records {
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			names = append(names, r.Name)
+		}
+	}
+	return newServer(t, names, database)
+}
+
+func newServer(t *testing.T, names []string, database *sql.DB) *Server {
+	t.Helper()
+	s, err := NewServer(names, database, frontend.Files)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	s.Logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	return s
+}
+
+func get(t *testing.T, s *Server, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+// TestV1LifterRoutes tests the lifter routes for a known and an unknown lifter.
+func TestV1LifterRoutes(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	name := url.PathEscape("Søren Ørbæk")
+
+	t.Run("Profile", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var profile db.LifterProfile
+		if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+			t.Fatalf("Failed to decode profile: %v", err)
+		}
+		if profile.Meets != 2 || len(profile.Bests) != 1 || profile.Bests[0].BestTotalKg != 632.5 || profile.LastMeetDate != "2024-03-01" {
+			t.Errorf("Unexpected profile: %+v", profile)
+		}
+	})
+
+	for _, path := range []string{"", "/details", "/progression", "/stats"} {
+		t.Run("Known lifter "+path, func(t *testing.T) {
+			if w := get(t, s, "/api/v1/lifters/"+name+path); w.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d: %s", w.Code, w.Body)
+			}
+		})
+
+		t.Run("Unknown lifter "+path, func(t *testing.T) {
+			w := get(t, s, "/api/v1/lifters/Nobody"+path)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("Expected 404, got %d: %s", w.Code, w.Body)
+			}
+			var body errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode error: %v", err)
+			}
+			if body.Code != codeNotFound || body.Error == "" {
+				t.Errorf("Unexpected error body: %+v", body)
+			}
+		})
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		w := get(t, s, "/api/search?q=soren+orbaek")
+		var names []string
+		if err := json.Unmarshal(w.Body.Bytes(), &names); err != nil {
+			t.Fatalf("Failed to decode search results: %v", err)
+		}
+		if len(names) != 1 || names[0] != "Søren Ørbæk" {
+			t.Errorf("Unexpected search results: %v", names)
+		}
+	})
+}
+
+// TestV1LifterEquipmentAndEvent tests that the lifter routes keep a bench-only
+// meet apart from full power meets.
+func TestV1LifterEquipmentAndEvent(t *testing.T) {
+	benchOnly := &db.Record{
+		Name: "Søren Ørbæk", Sex: "M", Event: "B", Equipment: "Raw", BodyweightKg: 83, Date: "2024-06-01",
+		MeetName: "Bench Cup", Country: "Denmark", Federation: "DSF",
+		Bench1Kg: 140, Bench2Kg: -145, Bench3Kg: -145, Best3BenchKg: 140, TotalKg: 140,
+	}
+	s := newTestServer(t, append(append([]*db.Record{}, testRecords...), benchOnly))
+	name := url.PathEscape("Søren Ørbæk")
+
+	t.Run("Grouped details", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name+"/details")
+		var groups []struct {
+			Equipment string             `json:"equipment"`
+			Event     string             `json:"event"`
+			Entries   []db.LifterDetails `json:"entries"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &groups); err != nil {
+			t.Fatalf("Failed to decode groups: %v", err)
+		}
+		if len(groups) != 2 || groups[0].Event != "B" || len(groups[0].Entries) != 1 ||
+			groups[1].Event != "SBD" || len(groups[1].Entries) != 2 {
+			t.Errorf("Unexpected groups: %+v", groups)
+		}
+	})
+
+	t.Run("Flat progression", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name+"/progression?group=false&event=SBD")
+		var performances []db.LifterPerformance
+		if err := json.Unmarshal(w.Body.Bytes(), &performances); err != nil {
+			t.Fatalf("Failed to decode progression: %v", err)
+		}
+		if len(performances) != 2 || performances[0].Date != "2023-03-01" {
+			t.Errorf("Unexpected progression: %+v", performances)
+		}
+	})
+
+	t.Run("Stats per event", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name+"/stats?equipment=Raw")
+		var stats []db.LifterStats
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("Failed to decode stats: %v", err)
+		}
+		if len(stats) != 2 || stats[0].Event != "B" || stats[0].AvgBenchSuccess != 1 ||
+			stats[1].Event != "SBD" || stats[1].Meets != 2 || stats[1].AvgBenchSuccess != 2.5 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("Profile filtered by event", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name+"?event=B")
+		var profile db.LifterProfile
+		if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+			t.Fatalf("Failed to decode profile: %v", err)
+		}
+		if profile.Meets != 1 || len(profile.Bests) != 1 || profile.Bests[0].BestTotalKg != 140 {
+			t.Errorf("Unexpected profile: %+v", profile)
+		}
+	})
+
+	t.Run("Profile bests per event", func(t *testing.T) {
+		w := get(t, s, "/api/v1/lifters/"+name)
+		var profile db.LifterProfile
+		if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+			t.Fatalf("Failed to decode profile: %v", err)
+		}
+		if len(profile.Bests) != 2 || profile.Bests[0].Event != "B" || profile.Bests[0].Meets != 1 ||
+			profile.Bests[0].BestTotalKg != 140 || profile.Bests[1].Event != "SBD" || profile.Bests[1].BestTotalKg != 632.5 {
+			t.Errorf("Unexpected bests: %+v", profile.Bests)
+		}
+	})
+
+	t.Run("No entries for filter", func(t *testing.T) {
+		if w := get(t, s, "/api/v1/lifters/"+name+"/stats?equipment=Multi-ply"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		for _, query := range []string{"equipment=raw", "event=X", "group=maybe"} {
+			if w := get(t, s, "/api/v1/lifters/"+name+"/details?"+query); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d: %s", query, w.Code, w.Body)
+			}
+		}
+	})
+}
+
+// TestListLiftersSearchTruncated tests that listings of searches matching
+// more than maxSearchCandidates names are marked truncated.
+func TestListLiftersSearchTruncated(t *testing.T) {
+	records := append([]*db.Record{}, testRecords...)
+	for i := 0; i <= maxSearchCandidates; i++ {
+		records = append(records, &db.Record{
+			Name: fmt.Sprintf("Lifter %04d", i), Sex: "M", Event: "B", Equipment: "Raw", BodyweightKg: 80,
+			Date: "2024-01-01", Bench1Kg: 100, Best3BenchKg: 100, TotalKg: 100,
+		})
+	}
+	s := newTestServer(t, records)
+
+	for _, tt := range []struct {
+		query     string
+		total     int
+		truncated bool
+	}{
+		{"q=Lifter&sex=M", maxSearchCandidates, true},
+		{"q=S%C3%B8ren&sex=M", 1, false},
+		{"sex=M", maxSearchCandidates + 2, false},
+	} {
+		w := get(t, s, "/api/v1/lifters?"+tt.query)
+		var page db.LifterPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("%s: failed to decode listing: %v", tt.query, err)
+		}
+		if page.Total != tt.total || page.Truncated != tt.truncated {
+			t.Errorf("%s: total %d, truncated %v; want %d, %v", tt.query, page.Total, page.Truncated, tt.total, tt.truncated)
+		}
+
+		w = get(t, s, "/api/search?"+tt.query)
+		var names []string
+		if err := json.Unmarshal(w.Body.Bytes(), &names); err != nil {
+			t.Fatalf("%s: failed to decode search: %v", tt.query, err)
+		}
+		if want := min(tt.total, defaultPageSize); len(names) != want || (w.Header().Get("X-Truncated") == "true") != tt.truncated {
+			t.Errorf("%s: search found %d names, X-Truncated %q; want %d, %v", tt.query, len(names), w.Header().Get("X-Truncated"), want, tt.truncated)
+		}
+	}
+
+	w := postGraphQL(t, s, `{"query": "{ lifters(search: \"Lifter\", sex: \"M\") { name } }"}`)
+	if !strings.Contains(w.Body.String(), "search matches more than 1000 names") {
+		t.Errorf("GraphQL search with facets: %s", w.Body)
+	}
+	w = postGraphQL(t, s, `{"query": "{ lifters(search: \"Lifter\", limit: 2) { name } }"}`)
+	if strings.Contains(w.Body.String(), "errors") {
+		t.Errorf("GraphQL search of the best matches: %s", w.Body)
+	}
+}
+
+// TestFrontend tests that the page and its static files are served from the
+// embedded assets.
+func TestFrontend(t *testing.T) {
+	s := newTestServer(t, nil)
+	for _, target := range []string{"/", "/static/app.js", "/static/styles.css"} {
+		if w := get(t, s, target); w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("%s: status %d", target, w.Code)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"liftmetrics/internal/db"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attemptStrategyFilters are the filters /api/v1/attempt-strategy supports.
+var attemptStrategyFilters = []string{"sex", "equipment", "yearFrom", "yearTo"}
+
+// handleAttemptStrategy serves the attempt strategy of all lifters matching
+// the filters.
+func (s *Server) handleAttemptStrategy(c *gin.Context) {
+	filter, err := aggregateFilterFromQuery(c, attemptStrategyFilters)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+
+	strategy, err := db.GetAttemptStrategy(c, s.DB, filter)
+	if err != nil {
+		respondDBError(c, err, "attempt strategy")
+		return
+	}
+	c.JSON(http.StatusOK, strategy)
+}
+
+// handleLifterAttemptStrategy serves the attempt strategy of one lifter.
+func (s *Server) handleLifterAttemptStrategy(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	strategy, err := db.GetLifterAttemptStrategy(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter attempt strategy")
+		return
+	}
+	c.JSON(http.StatusOK, strategy)
+}
+
+// handleAttemptRecommendation recommends the attempts of a lifter's next
+// meet, optionally aiming at the total given as targetTotalKg.
+func (s *Server) handleAttemptRecommendation(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	var target float64
+	if c.Query("targetTotalKg") != "" {
+		if target, ok = positiveQuery(c, "targetTotalKg"); !ok {
+			return
+		}
+	}
+
+	rec, err := db.GetAttemptRecommendation(c, s.DB, c.Param("name"), filter, target)
+	if err != nil {
+		respondDBError(c, err, "attempt recommendation")
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAttemptStrategy(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	name := url.PathEscape("Søren Ørbæk")
+
+	w := get(t, s, "/api/v1/lifters/"+name+"/attempt-strategy")
+	var lifter []db.AttemptStrategy
+	if err := json.Unmarshal(w.Body.Bytes(), &lifter); err != nil || len(lifter) != 3 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	squat, bench := lifter[0], lifter[1]
+	if squat.Lift != "Squat" || squat.Openers.Entries != 1 || *squat.Openers.AvgBelowPreviousBestKg != 5 {
+		t.Errorf("squat openers %+v", squat.Openers)
+	}
+	if bench.AfterMiss.Retries != 1 || bench.AfterMiss.RetriesMade != 1 || *bench.AfterMiss.RetryRate != 1 {
+		t.Errorf("bench after miss %+v", bench.AfterMiss)
+	}
+
+	w = get(t, s, "/api/v1/attempt-strategy?sex=M&yearFrom=2024")
+	var population []db.AttemptStrategy
+	if err := json.Unmarshal(w.Body.Bytes(), &population); err != nil || len(population) != 3 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if population[0].Attempts[0].Attempts != 1 || population[0].Openers.Entries != 1 {
+		t.Errorf("2024 squats %+v", population[0])
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/lifters/Nobody/attempt-strategy":               http.StatusNotFound,
+		"/api/v1/lifters/" + name + "/attempt-strategy?event=X": http.StatusBadRequest,
+		"/api/v1/attempt-strategy?name=Nobody":                  http.StatusBadRequest,
+		"/api/v1/attempt-strategy?yearFrom=soon":                http.StatusBadRequest,
+	} {
+		if w := get(t, s, target); w.Code != status {
+			t.Errorf("%s: status %d, want %d", target, w.Code, status)
+		}
+	}
+}
+
+func TestAttemptRecommendation(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	name := url.PathEscape("Søren Ørbæk")
+
+	w := get(t, s, "/api/v1/lifters/"+name+"/attempt-recommendation?targetTotalKg=650")
+	var rec db.AttemptRecommendation
+	if err := json.Unmarshal(w.Body.Bytes(), &rec); err != nil || len(rec.Lifts) != 3 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if rec.Event != "SBD" || rec.TargetTotalKg == nil || rec.PlannedTotalKg < 645 || rec.PlannedTotalKg > 655 {
+		t.Errorf("got %s", w.Body)
+	}
+	for _, l := range rec.Lifts {
+		if len(l.Attempts) != 3 || l.Attempts[0].Kg > l.Attempts[1].Kg || l.Attempts[1].Kg > l.Attempts[2].Kg {
+			t.Errorf("%s attempts %+v", l.Lift, l.Attempts)
+		}
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/lifters/Nobody/attempt-recommendation":                       http.StatusNotFound,
+		"/api/v1/lifters/" + name + "/attempt-recommendation?targetTotalKg=0": http.StatusBadRequest,
+		"/api/v1/lifters/" + name + "/attempt-recommendation?equipment=Bands": http.StatusBadRequest,
+	} {
+		if w := get(t, s, target); w.Code != status {
+			t.Errorf("%s: status %d, want %d", target, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"liftmetrics/internal/db"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// cacheControl lets clients reuse a response for a minute before
+	// revalidating it with its ETag.
+	cacheControl = "public, max-age=60"
+
+	// maxCachedBody is the largest response body kept in the cache. Larger
+	// responses, such as big exports, are streamed without being kept.
+	maxCachedBody = 1 << 20
+
+	// revisionCheckInterval is how often the data revision is re-read.
+	revisionCheckInterval = 5 * time.Second
+)
+
+// ResponseCache is an in-process LRU cache of successful GET responses of
+// the API. Entries are keyed by the data revision, so the whole cache is
+// dropped as soon as a rebuilt database is noticed.
+type ResponseCache struct {
+	maxBytes      int
+	checkInterval time.Duration
+
+	mu       sync.Mutex
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List // most recently used first
+	revision string
+	checked  time.Time
+}
+
+// cachedResponse is a response body with the headers needed to replay it.
+type cachedResponse struct {
+	key    string
+	header http.Header
+	body   []byte
+}
+
+// cachedHeaders are the response headers stored with a cached body.
+var cachedHeaders = []string{"Content-Type", "Content-Disposition", "X-Next-Cursor", "X-Truncated"}
+
+// NewResponseCache returns a cache holding at most maxBytes of response bodies.
+func NewResponseCache(maxBytes int) *ResponseCache {
+	return &ResponseCache{
+		maxBytes:      maxBytes,
+		checkInterval: revisionCheckInterval,
+		entries:       make(map[string]*list.Element),
+		order:         list.New(),
+	}
+}
+
+// dataRevision returns the revision of the data in database, re-reading it
+// at most every checkInterval, and empties the cache when it has changed.
+// It returns "" if the database records no revision. The revision is read
+// without holding the lock, so that cache lookups do not wait for the
+// database; the request that finds it stale reads it while the others keep
+// using the last one.
+func (rc *ResponseCache) dataRevision(ctx context.Context, database *sql.DB) string {
+	rc.mu.Lock()
+	if time.Since(rc.checked) < rc.checkInterval {
+		revision := rc.revision
+		rc.mu.Unlock()
+		return revision
+	}
+	first := rc.checked.IsZero()
+	rc.checked = time.Now()
+	rc.mu.Unlock()
+
+	revision := ""
+	rev, err := db.GetDataRevision(ctx, database)
+	if err == nil {
+		revision = rev.String()
+	} else if first {
+		log.Printf("Not caching responses: %v", err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if revision != rc.revision {
+		rc.entries = make(map[string]*list.Element)
+		rc.order.Init()
+		rc.size = 0
+		rc.revision = revision
+	}
+	return revision
+}
+
+func (rc *ResponseCache) get(key string) (*cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	rc.order.MoveToFront(elem)
+	return elem.Value.(*cachedResponse), true
+}
+
+func (rc *ResponseCache) add(resp *cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(resp.body) > rc.maxBytes {
+		return
+	}
+	if elem, ok := rc.entries[resp.key]; ok {
+		rc.size -= len(elem.Value.(*cachedResponse).body)
+		rc.order.Remove(elem)
+	}
+	rc.entries[resp.key] = rc.order.PushFront(resp)
+	rc.size += len(resp.body)
+
+	for rc.size > rc.maxBytes {
+		oldest := rc.order.Back()
+		evicted := rc.order.Remove(oldest).(*cachedResponse)
+		delete(rc.entries, evicted.key)
+		rc.size -= len(evicted.body)
+	}
+}
+
+// httpCache is the middleware of the API routes. It compresses text
+// responses with brotli or gzip for clients that accept either and, for GET
+// requests, sets ETags derived from the data revision and the request,
+// answers matching If-None-Match headers with 304 and serves repeated
+// requests from s.Cache.
+func (s *Server) httpCache() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept, Accept-Encoding")
+		w := &cachingWriter{ResponseWriter: c.Writer, encoding: acceptedEncoding(c.GetHeader("Accept-Encoding"))}
+		c.Writer = w
+		defer w.close()
+
+		if c.Request.Method != http.MethodGet || s.Cache == nil || s.DB == nil {
+			c.Next()
+			return
+		}
+		revision := s.Cache.dataRevision(c, s.DB)
+		if revision == "" {
+			c.Next()
+			return
+		}
+
+		key := revision + "\x00" + c.Request.URL.Path + "?" + c.Request.URL.Query().Encode() + "\x00" + c.GetHeader("Accept")
+		sum := sha256.Sum256([]byte(key))
+		w.etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+		if etagMatches(c.GetHeader("If-None-Match"), w.etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		if resp, ok := s.Cache.get(key); ok {
+			for name, values := range resp.header {
+				w.Header()[name] = values
+			}
+			c.Status(http.StatusOK)
+			w.Write(resp.body)
+			c.Abort()
+			return
+		}
+
+		w.capture = &bytes.Buffer{}
+		c.Next()
+
+		if w.capture != nil && w.Status() == http.StatusOK && len(c.Errors) == 0 {
+			resp := &cachedResponse{key: key, header: make(http.Header), body: w.capture.Bytes()}
+			for _, name := range cachedHeaders {
+				if v := w.Header().Values(name); len(v) > 0 {
+					resp.header[name] = v
+				}
+			}
+			s.Cache.add(resp)
+		}
+	}
+}
+
+// cachingWriter sets the caching headers once the status of a response is
+// known, compresses the body in the encoding the client accepts and keeps a
+// copy of it for the cache while it is small enough.
+type cachingWriter struct {
+	gin.ResponseWriter
+
+	// etag is set for cacheable requests.
+	etag string
+	// encoding is the content coding of compressed bodies, "br", "gzip" or
+	// "" if the client accepts neither.
+	encoding string
+	// capture receives the uncompressed body; it is dropped when the body
+	// grows beyond maxCachedBody or the response is not a 200.
+	capture *bytes.Buffer
+
+	started    bool
+	compressor compressor
+}
+
+// compressor is the part of gzip.Writer and brotli.Writer the cachingWriter
+// uses.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressors are pools of the writers of each content coding offered, in
+// order of preference. Brotli level 4 compresses about as fast as gzip's
+// default level and better.
+var compressors = []struct {
+	encoding string
+	pool     *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(nil, 4) }}},
+	{"gzip", &sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}},
+}
+
+// compressorPool returns the pool of writers of a content coding.
+func compressorPool(encoding string) *sync.Pool {
+	for _, c := range compressors {
+		if c.encoding == encoding {
+			return c.pool
+		}
+	}
+	return nil
+}
+
+// begin sets the headers that depend on the status and content type, just
+// before they are sent.
+func (w *cachingWriter) begin() {
+	if w.started {
+		return
+	}
+	w.started = true
+
+	h := w.Header()
+	status := w.Status()
+	switch {
+	case w.etag != "" && (status == http.StatusOK || status == http.StatusNotModified):
+		h.Set("ETag", w.etag)
+		h.Set("Cache-Control", cacheControl)
+	case w.etag != "":
+		h.Set("Cache-Control", "no-store")
+	}
+	if status != http.StatusOK {
+		w.capture = nil
+	}
+
+	if w.encoding != "" && status != http.StatusNotModified && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.compressor = compressorPool(w.encoding).Get().(compressor)
+		w.compressor.Reset(w.ResponseWriter)
+	}
+}
+
+func (w *cachingWriter) Write(data []byte) (int, error) {
+	w.begin()
+	if w.capture != nil {
+		if w.capture.Len()+len(data) > maxCachedBody {
+			w.capture = nil
+		} else {
+			w.capture.Write(data)
+		}
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cachingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *cachingWriter) WriteHeaderNow() {
+	w.begin()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cachingWriter) Flush() {
+	if w.compressor != nil {
+		w.compressor.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close finishes the compressed stream, if any.
+func (w *cachingWriter) close() {
+	if w.compressor != nil {
+		w.compressor.Close()
+		compressorPool(w.encoding).Put(w.compressor)
+		w.compressor = nil
+	}
+}
+
+// acceptedEncoding returns the preferred content coding of compressors an
+// Accept-Encoding header allows, or "" if it allows none. Codings with a
+// weight of zero, such as q=0 or q=0.000, are refused; other weights are
+// ignored in favour of the server's order.
+func acceptedEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if _, seen := accepted[coding]; !seen {
+			accepted[coding] = !zeroWeight(params)
+		}
+	}
+	for _, c := range compressors {
+		if accepted[c.encoding] {
+			return c.encoding
+		}
+	}
+	return ""
+}
+
+// zeroWeight reports whether the parameters of an Accept-Encoding element
+// give it a weight of zero.
+func zeroWeight(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(name), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q == 0
+		}
+	}
+	return false
+}
+
+// compressible reports whether a content type is worth compressing. XLSX
+// workbooks are already zip archives.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json"
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. Weak
+// comparison is used, as it is for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
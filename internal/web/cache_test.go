@@ -0,0 +1,106 @@
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestHTTPCache tests ETags, conditional requests, compression and that cached
+// responses are served until the data revision changes.
+func TestHTTPCache(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	ctx := context.Background()
+	if err := db.SetDataRevision(ctx, s.DB, "rev1"); err != nil {
+		t.Fatal(err)
+	}
+	s.Cache.checkInterval = 0
+
+	const target = "/api/v1/lifters/S%C3%B8ren%20%C3%98rb%C3%A6k"
+	w := get(t, s, target)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) || w.Header().Get("Cache-Control") != cacheControl {
+		t.Fatalf("status %d, headers %v", w.Code, w.Header())
+	}
+	body := w.Body.String()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Errorf("If-None-Match: status %d, headers %v", w.Code, w.Header())
+	}
+
+	for _, tt := range []struct{ acceptEncoding, encoding string }{
+		{"br, gzip", "br"},
+		{"gzip, deflate", "gzip"},
+		{"br;q=0, gzip", "gzip"},
+		{"br;q=0.000, gzip", "gzip"},
+		{"br; Q=0.0, gzip;q=0.0", ""},
+		{"br;q=0.5", "br"},
+		{"identity", ""},
+	} {
+		req = httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w = httptest.NewRecorder()
+		s.Router.ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != tt.encoding {
+			t.Fatalf("Accept-Encoding %q: Content-Encoding = %q", tt.acceptEncoding, w.Header().Get("Content-Encoding"))
+		}
+		var r io.Reader = w.Body
+		switch tt.encoding {
+		case "br":
+			r = brotli.NewReader(w.Body)
+		case "gzip":
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		}
+		if decoded, err := io.ReadAll(r); err != nil || string(decoded) != body {
+			t.Errorf("Accept-Encoding %q: body %q, %v; want %q", tt.acceptEncoding, decoded, err, body)
+		}
+	}
+
+	// Errors are neither cached nor marked cacheable.
+	if w := get(t, s, "/api/v1/lifters/Nobody"); w.Code != http.StatusNotFound || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("not found: status %d, headers %v", w.Code, w.Header())
+	}
+
+	if _, err := s.DB.Exec("DELETE FROM records"); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(t, s, target); w.Code != http.StatusOK || w.Body.String() != body {
+		t.Errorf("cached: status %d: %s", w.Code, w.Body)
+	}
+
+	if err := db.SetDataRevision(ctx, s.DB, "rev2"); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(t, s, target); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Errorf("after new revision: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	rc := NewResponseCache(10)
+	rc.add(&cachedResponse{key: "a", body: []byte("aaaa")})
+	rc.add(&cachedResponse{key: "b", body: []byte("bbbb")})
+	rc.get("a")
+	rc.add(&cachedResponse{key: "c", body: []byte("cccc")})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := rc.get(key); ok != want {
+			t.Errorf("get(%q) ok = %v, want %v", key, ok, want)
+		}
+	}
+}
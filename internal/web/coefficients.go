@@ -0,0 +1,76 @@
+package web
+
+import (
+	"liftmetrics/internal/coefficients"
+	"liftmetrics/internal/db"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coefficientScores is the response of /api/v1/coefficients.
+type coefficientScores struct {
+	Sex          string  `json:"sex"`
+	Equipment    string  `json:"equipment"`
+	Event        string  `json:"event"`
+	BodyweightKg float64 `json:"bodyweightKg"`
+	TotalKg      float64 `json:"totalKg"`
+	Dots         float64 `json:"dots"`
+	Wilks        float64 `json:"wilks"`
+	Glossbrenner float64 `json:"glossbrenner"`
+	// Goodlift is null for equipment and events IPF GL does not cover.
+	Goodlift *float64 `json:"goodlift"`
+}
+
+// handleCoefficients scores any total at any bodyweight with the Dots,
+// Wilks, Glossbrenner and IPF GL formulas.
+func (s *Server) handleCoefficients(c *gin.Context) {
+	scores := coefficientScores{
+		Sex:       c.Query("sex"),
+		Equipment: c.Query("equipment"),
+		Event:     c.Query("event"),
+	}
+	if scores.Equipment == "" {
+		scores.Equipment = "Raw"
+	}
+	if scores.Event == "" {
+		scores.Event = "SBD"
+	}
+	if !slices.Contains([]string{"M", "F", "Mx"}, scores.Sex) {
+		respondBadRequest(c, "sex must be M, F or Mx")
+		return
+	}
+	if err := (db.EntryFilter{Equipment: scores.Equipment, Event: scores.Event}).Validate(); err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+	var ok bool
+	if scores.BodyweightKg, ok = positiveQuery(c, "bodyweightKg"); !ok {
+		return
+	}
+	if scores.TotalKg, ok = positiveQuery(c, "totalKg"); !ok {
+		return
+	}
+
+	scores.Dots = coefficients.Dots(scores.Sex, scores.BodyweightKg, scores.TotalKg)
+	scores.Wilks = coefficients.Wilks(scores.Sex, scores.BodyweightKg, scores.TotalKg)
+	scores.Glossbrenner = coefficients.Glossbrenner(scores.Sex, scores.BodyweightKg, scores.TotalKg)
+	if gl := coefficients.Goodlift(scores.Sex, scores.Equipment, scores.Event, scores.BodyweightKg, scores.TotalKg); gl > 0 {
+		scores.Goodlift = &gl
+	}
+	c.JSON(http.StatusOK, scores)
+}
+
+// positiveQuery parses the query parameter name as a positive number,
+// responding with a 400 if it is not one.
+func positiveQuery(c *gin.Context, name string) (float64, bool) {
+	x, err := strconv.ParseFloat(c.Query(name), 64)
+	if err != nil || !(x > 0) || math.IsInf(x, 1) {
+		respondBadRequest(c, name+" must be a positive number")
+		return 0, false
+	}
+	return x, true
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCoefficients(t *testing.T) {
+	s := newTestServer(t, testRecords)
+
+	w := get(t, s, "/api/v1/coefficients?sex=M&bodyweightKg=93&totalKg=700")
+	var scores coefficientScores
+	if err := json.Unmarshal(w.Body.Bytes(), &scores); err != nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if scores.Equipment != "Raw" || scores.Event != "SBD" || scores.Dots < 445.37 || scores.Dots > 445.38 ||
+		scores.Goodlift == nil || *scores.Goodlift < 91.57 || *scores.Goodlift > 91.58 {
+		t.Errorf("got %s", w.Body)
+	}
+
+	w = get(t, s, "/api/v1/coefficients?sex=F&bodyweightKg=60&totalKg=180&event=D&equipment=Multi-ply")
+	scores = coefficientScores{}
+	if err := json.Unmarshal(w.Body.Bytes(), &scores); err != nil || scores.Wilks == 0 || scores.Goodlift != nil {
+		t.Errorf("deadlift only: %s", w.Body)
+	}
+
+	for _, query := range []string{
+		"sex=X&bodyweightKg=93&totalKg=700",
+		"sex=M&totalKg=700",
+		"sex=M&bodyweightKg=NaN&totalKg=700",
+		"sex=M&bodyweightKg=93&totalKg=-5",
+		"sex=M&bodyweightKg=93&totalKg=700&equipment=Bands",
+	} {
+		if w := get(t, s, "/api/v1/coefficients?"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"fmt"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/plugins"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListCustomMetrics lists the user-defined metrics.
+func (s *Server) handleListCustomMetrics(c *gin.Context) {
+	defs := s.CustomMetrics
+	if defs == nil {
+		defs = []*plugins.Definition{}
+	}
+	c.JSON(http.StatusOK, defs)
+}
+
+// handleCustomMetric serves a page of the table of a user-defined metric.
+// Query parameters named after its columns filter the rows on equality.
+func (s *Server) handleCustomMetric(c *gin.Context) {
+	var def *plugins.Definition
+	for _, d := range s.CustomMetrics {
+		if d.Name == c.Param("metric") {
+			def = d
+		}
+	}
+	if def == nil {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("No user-defined metric named %q", c.Param("metric")))
+		return
+	}
+
+	limit, offset, err := paginationFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	filters := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		switch key {
+		case "limit", "offset", "format":
+			continue
+		}
+		filters[key] = values[0]
+	}
+
+	page, err := db.QueryTable(c, s.DB, def.Table, filters, limit, offset)
+	if err != nil {
+		respondDBError(c, err, def.Name+" rows")
+		return
+	}
+
+	if format != formatJSON {
+		respondExport(c, format, def.Name, exportTable{name: def.Name, rows: page.Rows, columns: page.Columns})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
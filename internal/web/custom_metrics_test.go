@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/plugins"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCustomMetrics tests listing user-defined metrics and querying their
+// tables with column filters.
+func TestCustomMetrics(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	dir := t.TempDir()
+	definition := `description: Total per meet
+inputs: [records]
+schema: MeetName TEXT, TotalKg REAL
+sql: INSERT INTO custom_meet_totals SELECT MeetName, TotalKg FROM records ORDER BY Date
+`
+	if err := os.WriteFile(filepath.Join(dir, "meet_totals.yaml"), []byte(definition), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	defs, err := plugins.Load(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := db.NewFeatureCalculator()
+	for _, calc := range plugins.Calculators(defs) {
+		fc.AddCalculator(calc)
+	}
+	if err := fc.Recompute(ctx, s.DB, "custom_meet_totals"); err != nil {
+		t.Fatal(err)
+	}
+	s.CustomMetrics = defs
+
+	w := get(t, s, "/api/v1/custom-metrics")
+	var listed []plugins.Definition
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Name != "meet_totals" || len(listed[0].Columns) != 2 {
+		t.Errorf("listed %+v", listed)
+	}
+
+	w = get(t, s, "/api/v1/custom-metrics/meet_totals?MeetName=Nationals")
+	var page db.TablePage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || page.Total != 1 || page.Rows[0]["TotalKg"] != 632.5 {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+
+	if w := get(t, s, "/api/v1/custom-metrics/meet_totals?format=csv"); !strings.Contains(w.Body.String(), "Spring Open,620") {
+		t.Errorf("csv: %s", w.Body)
+	}
+	if w := get(t, s, "/api/v1/custom-metrics/meet_totals?Nope=1"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown column: status %d: %s", w.Code, w.Body)
+	}
+	if w := get(t, s, "/api/v1/custom-metrics/nope"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown metric: status %d", w.Code)
+	}
+}
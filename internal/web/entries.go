@@ -0,0 +1,126 @@
+package web
+
+import (
+	"fmt"
+	"liftmetrics/internal/db"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultEntryPageSize = 100
+	maxEntryPageSize     = 1000
+)
+
+// entryQueryParams are the query parameters of /entries that are not filters.
+var entryQueryParams = []string{"fields", "sort", "limit", "cursor", "format"}
+
+// filterParam matches a filter parameter such as "date[gte]".
+var filterParam = regexp.MustCompile(`^(\w+)(?:\[(\w+)\])?$`)
+
+// entryFieldInfo describes a queryable field for /entries/fields.
+type entryFieldInfo struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Operators []string `json:"operators"`
+}
+
+func (s *Server) handleEntryFields(c *gin.Context) {
+	fields := make([]entryFieldInfo, 0, len(db.EntryFields))
+	for _, f := range db.EntryFields {
+		fields = append(fields, entryFieldInfo{Name: f.Name, Type: string(f.Type), Operators: f.Operators()})
+	}
+	c.JSON(http.StatusOK, fields)
+}
+
+// handleQueryEntries serves filtered, sorted and cursor-paginated entries.
+// Every query parameter that is not in entryQueryParams is a filter of the
+// form field=value or field[op]=value.
+func (s *Server) handleQueryEntries(c *gin.Context) {
+	q, err := entryQueryFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	page, err := db.QueryEntries(c, s.DB, q)
+	if err != nil {
+		respondDBError(c, err, "entries")
+		return
+	}
+
+	if format == formatJSON {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	respondExport(c, format, "entries", exportTable{name: "Entries", rows: page.Rows, columns: page.Fields})
+}
+
+// entryQueryFromQuery builds an entry query from the query string. Field and
+// operator names are checked by db.QueryEntries.
+func entryQueryFromQuery(c *gin.Context) (db.EntryQuery, error) {
+	q := db.EntryQuery{Limit: defaultEntryPageSize, Cursor: c.Query("cursor")}
+
+	if v := c.Query("fields"); v != "" {
+		q.Fields = strings.Split(v, ",")
+	}
+
+	var err error
+	q.Sort, err = db.ParseSort(c.Query("sort"))
+	if err != nil {
+		return q, err
+	}
+
+	if v := c.Query("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit < 1 || q.Limit > maxEntryPageSize {
+			return q, fmt.Errorf("limit must be an integer between 1 and %d", maxEntryPageSize)
+		}
+	}
+
+	params := c.Request.URL.Query()
+	for _, key := range sortedParamKeys(params) {
+		if slices.Contains(entryQueryParams, key) {
+			continue
+		}
+		m := filterParam.FindStringSubmatch(key)
+		if m == nil {
+			return q, fmt.Errorf("malformed filter %q, expected field=value or field[op]=value", key)
+		}
+		op := m[2]
+		if op == "" {
+			op = "eq"
+		}
+		for _, value := range params[key] {
+			q.Conditions = append(q.Conditions, db.Condition{Field: m[1], Op: op, Value: value})
+		}
+	}
+
+	return q, nil
+}
+
+// sortedParamKeys returns the keys of params in a stable order so that the
+// generated SQL, and the first reported error, do not vary between requests.
+func sortedParamKeys(params map[string][]string) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestEntriesRoute tests filters, formats and cursor pagination on /entries.
+func TestEntriesRoute(t *testing.T) {
+	s := newTestServer(t, testRecords)
+
+	t.Run("JSON pages", func(t *testing.T) {
+		w := get(t, s, "/api/v1/entries?fields=meetName,totalKg&equipment=Raw&totalKg[gt]=600&sort=-totalKg&limit=1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var page db.EntryPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode page: %v", err)
+		}
+		if len(page.Rows) != 1 || page.Rows[0]["meetName"] != "Nationals" || page.NextCursor == "" {
+			t.Fatalf("Unexpected first page: %+v", page)
+		}
+
+		w = get(t, s, "/api/v1/entries?fields=meetName,totalKg&equipment=Raw&totalKg[gt]=600&sort=-totalKg&limit=1&cursor="+page.NextCursor)
+		page = db.EntryPage{}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Failed to decode page: %v", err)
+		}
+		if len(page.Rows) != 1 || page.Rows[0]["meetName"] != "Spring Open" || page.NextCursor != "" {
+			t.Errorf("Unexpected second page: %+v", page)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		w := get(t, s, "/api/v1/entries?fields=date,totalKg&sort=date&format=csv")
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+			t.Fatalf("Expected CSV, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse CSV: %v", err)
+		}
+		if len(records) != 3 || records[0][1] != "totalKg" || records[2][1] != "632.5" {
+			t.Errorf("Unexpected CSV: %v", records)
+		}
+	})
+
+	for _, query := range []string{"foo=1", "name[gt]=A", "totalKg[gte]=heavy", "sort=-password", "limit=5000", "format=xml"} {
+		t.Run("Bad request "+query, func(t *testing.T) {
+			if w := get(t, s, "/api/v1/entries?"+query); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"liftmetrics/internal/db"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the code field of an error response.
+const (
+	codeBadRequest   = "bad_request"
+	codeUnauthorized = "unauthorized"
+	codeNotFound     = "not_found"
+	codeTimeout      = "timeout"
+	codeInternal     = "internal_error"
+)
+
+// errorResponse is the JSON body of every error response.
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// respondError aborts the request with the given status and error body.
+func respondError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, errorResponse{Error: message, Code: code})
+}
+
+// respondBadRequest aborts the request with a 400 and the given message.
+func respondBadRequest(c *gin.Context, message string) {
+	respondError(c, http.StatusBadRequest, codeBadRequest, message)
+}
+
+// respondDBError maps an error returned by the db package to a response:
+// ErrNoRows becomes a 404, ErrInvalidQuery a 400, ErrQueryTimeout a 504 and
+// anything else a 500.
+func respondDBError(c *gin.Context, err error, what string) {
+	switch {
+	case errors.Is(err, db.ErrInvalidQuery):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, db.ErrNoRows):
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("No %s found", what))
+	case errors.Is(err, db.ErrQueryTimeout):
+		respondError(c, http.StatusGatewayTimeout, codeTimeout, fmt.Sprintf("Timed out fetching %s", what))
+	default:
+		c.Error(err)
+		respondError(c, http.StatusInternalServerError, codeInternal, fmt.Sprintf("Error fetching %s: %v", what, err))
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"liftmetrics/internal/export"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formatJSON is the default response format; export.FormatCSV and
+// export.FormatXLSX are the download formats.
+const formatJSON = "json"
+
+// exportFormat returns the format a result is requested in: the format query
+// parameter if given, otherwise the best match for the Accept header. For an
+// unknown format it responds with 400 and returns false.
+func exportFormat(c *gin.Context) (string, bool) {
+	switch format := c.Query("format"); format {
+	case "":
+	case formatJSON, export.FormatCSV, export.FormatXLSX:
+		return format, true
+	default:
+		respondBadRequest(c, fmt.Sprintf("unknown format %q, expected json, csv or xlsx", format))
+		return "", false
+	}
+
+	switch c.NegotiateFormat(gin.MIMEJSON, "text/csv", export.ContentTypes[export.FormatXLSX]) {
+	case "text/csv":
+		return export.FormatCSV, true
+	case export.ContentTypes[export.FormatXLSX]:
+		return export.FormatXLSX, true
+	}
+	return formatJSON, true
+}
+
+// exportTable is one table of a downloaded result. Rows is either a slice of
+// structs, exported with a column per JSON field, or a []map[string]interface{}
+// exported with the given columns.
+type exportTable struct {
+	name    string
+	rows    interface{}
+	columns []string
+}
+
+// respondExport sends tables as a CSV or XLSX download named filename plus
+// the format's extension. Rows are encoded straight to the response, so an
+// error while writing can only cut the download short; it is recorded on
+// the context.
+func respondExport(c *gin.Context, format, filename string, tables ...exportTable) {
+	c.Header("Content-Type", export.ContentTypes[format])
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename + "." + format,
+	}))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewTableWriter(format, c.Writer)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for _, t := range tables {
+		if maps, ok := t.rows.([]map[string]interface{}); ok {
+			err = export.WriteMaps(w, t.name, t.columns, maps)
+		} else {
+			err = export.WriteStructs(w, t.name, t.rows)
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// groupTables exports rows, ordered by equipment and event, as one table per
+// equipment/event pair, e.g. "Raw SBD".
+func groupTables[T any](rows []T, key func(T) (equipment, event string)) []exportTable {
+	var tables []exportTable
+	for _, g := range groupEntries(rows, key) {
+		tables = append(tables, exportTable{name: g.Equipment + " " + g.Event, rows: g.Entries})
+	}
+	return tables
+}
+
+// wideTableExport exports the wide shape of an aggregate table with the index
+// columns first and the series columns in name order.
+func wideTableExport(name string, table wideTable) exportTable {
+	seen := make(map[string]bool)
+	var series []string
+	for _, row := range table.Rows {
+		for column := range row {
+			if !seen[column] && !slices.Contains(table.Index, column) {
+				seen[column] = true
+				series = append(series, column)
+			}
+		}
+	}
+	sort.Strings(series)
+	return exportTable{name: name, rows: table.Rows, columns: append(append([]string{}, table.Index...), series...)}
+}
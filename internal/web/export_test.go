@@ -0,0 +1,101 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestExport tests CSV and XLSX downloads selected by the format parameter
+// and by the Accept header.
+func TestExport(t *testing.T) {
+	records := append([]*db.Record{{
+		Name: "Søren Ørbæk", Sex: "M", Event: "B", Equipment: "Raw", BodyweightKg: 83, Date: "2024-06-01",
+		MeetName: "Bench Cup", Country: "Denmark", Federation: "DSF",
+		Bench1Kg: 140, Bench2Kg: 145, Bench3Kg: -150, Best3BenchKg: 145, TotalKg: 145,
+	}}, testRecords...)
+	s := newTestServer(t, records)
+	name := url.PathEscape("Søren Ørbæk")
+
+	w := get(t, s, "/api/v1/lifters/"+name+"/details?format=xlsx")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+		t.Fatalf("xlsx details: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") || !strings.Contains(cd, "details.xlsx") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	r, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("xlsx details is not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range r.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(data)
+	}
+	if wb := parts["xl/workbook.xml"]; !strings.Contains(wb, `name="Raw B"`) || !strings.Contains(wb, `name="Raw SBD"`) {
+		t.Errorf("Expected a sheet per equipment and event, got %s", wb)
+	}
+	if sheet := parts["xl/worksheets/sheet2.xml"]; !regexp.MustCompile(`<c r="[A-Z]+2"><v>[0-9.]+</v></c>`).MatchString(sheet) {
+		t.Errorf("Expected numeric cells in %s", sheet)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/lifters/"+name+"/progression", nil)
+	req.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || err != nil {
+		t.Fatalf("csv progression: status %d, %v", w.Code, err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "date,equipment,event,squat,bench,deadlift,total" || rows[3][6] != "632.5" {
+		t.Errorf("Unexpected progression CSV: %v", rows)
+	}
+
+	w = get(t, s, "/api/v1/aggregates/performance-trends?shape=wide&format=csv")
+	rows, _ = csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || len(rows) < 2 || rows[0][0] != "year" || !strings.Contains(strings.Join(rows[0], ","), "M.Raw.avgTotal") {
+		t.Errorf("Unexpected wide CSV: status %d, %v", w.Code, rows)
+	}
+
+	w = get(t, s, "/api/v1/lifters?format=xlsx")
+	r, err = zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("xlsx lifters is not a zip archive: %v", err)
+	}
+	for _, f := range r.File {
+		if f.Name != "xl/workbook.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if wb := string(data); !strings.Contains(wb, `name="Lifters"`) || !strings.Contains(wb, `name="Facets"`) {
+			t.Errorf("Expected lifters and facets sheets, got %s", wb)
+		}
+	}
+	w = get(t, s, "/api/v1/lifters?format=csv")
+	rows, _ = csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || len(rows) < 2 || rows[0][0] == "facet" {
+		t.Errorf("Unexpected lifters CSV: status %d, %v", w.Code, rows)
+	}
+
+	w = get(t, s, "/api/search?q=soren&format=csv")
+	if w.Code != http.StatusOK || w.Body.String() != "name\nSøren Ørbæk\n" {
+		t.Errorf("Unexpected search CSV: status %d, %q", w.Code, w.Body)
+	}
+
+	if w := get(t, s, "/api/v1/lifters/"+name+"/stats?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown format: status %d", w.Code)
+	}
+}
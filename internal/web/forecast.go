@@ -0,0 +1,63 @@
+package web
+
+import (
+	"fmt"
+	"liftmetrics/internal/db"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultBacktestLifters and maxBacktestLifters bound how many lifters
+	// a backtest covers.
+	defaultBacktestLifters = 500
+	maxBacktestLifters     = 5000
+)
+
+// handleLifterForecast forecasts a lifter's lifts at a meet on the given date.
+func (s *Server) handleLifterForecast(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		respondBadRequest(c, "date must be given as YYYY-MM-DD")
+		return
+	}
+
+	forecast, err := db.GetLifterForecast(c, s.DB, c.Param("name"), filter, date)
+	if err != nil {
+		respondDBError(c, err, "lifter forecast")
+		return
+	}
+	c.JSON(http.StatusOK, forecast)
+}
+
+// handleForecastBacktest reports how well forecasts of held-out last meets
+// matched what was lifted.
+func (s *Server) handleForecastBacktest(c *gin.Context) {
+	filter, err := aggregateFilterFromQuery(c, []string{"sex", "equipment"})
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+	lifters := defaultBacktestLifters
+	if v := c.Query("lifters"); v != "" {
+		lifters, err = strconv.Atoi(v)
+		if err != nil || lifters < 1 || lifters > maxBacktestLifters {
+			respondBadRequest(c, fmt.Sprintf("lifters must be an integer between 1 and %d", maxBacktestLifters))
+			return
+		}
+	}
+
+	summaries, err := db.BacktestForecasts(c, s.DB, filter, lifters)
+	if err != nil {
+		respondDBError(c, err, "forecast backtest")
+		return
+	}
+	c.JSON(http.StatusOK, summaries)
+}
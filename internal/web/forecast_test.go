@@ -0,0 +1,43 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/forecast"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestForecast(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	name := url.PathEscape("Søren Ørbæk")
+
+	w := get(t, s, "/api/v1/lifters/"+name+"/forecast?date=2025-03-01")
+	var f db.LifterForecast
+	if err := json.Unmarshal(w.Body.Bytes(), &f); err != nil || len(f.Lifts) != 4 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if total := f.Lifts[3]; total.Lift != "Total" || total.Meets != 2 || total.Model != forecast.ModelTheilSen || total.Kg <= total.LastKg {
+		t.Errorf("total forecast %+v", total)
+	}
+
+	w = get(t, s, "/api/v1/forecasts/backtest?sex=M")
+	var summaries []forecast.Summary
+	if err := json.Unmarshal(w.Body.Bytes(), &summaries); err != nil || len(summaries) != 4 || summaries[0].Forecasts != 1 {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/lifters/Nobody/forecast?date=2025-03-01":               http.StatusNotFound,
+		"/api/v1/lifters/" + name + "/forecast":                         http.StatusBadRequest,
+		"/api/v1/lifters/" + name + "/forecast?date=2020-01-01":         http.StatusBadRequest,
+		"/api/v1/lifters/" + name + "/forecast?date=2025-03-01&event=X": http.StatusBadRequest,
+		"/api/v1/forecasts/backtest?lifters=0":                          http.StatusBadRequest,
+		"/api/v1/forecasts/backtest?yearFrom=2020":                      http.StatusBadRequest,
+	} {
+		if w := get(t, s, target); w.Code != status {
+			t.Errorf("%s: status %d, want %d", target, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/graphql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// graphQLLimits bound the queries accepted by the GraphQL endpoint. The
+// complexity limit leaves room for a lifter's entries with the full field
+// list of each meet they competed at.
+var graphQLLimits = graphql.Limits{MaxDepth: 10, MaxComplexity: 10000}
+
+const (
+	// maxGraphQLListSize is the largest limit accepted by list fields.
+	maxGraphQLListSize = 1000
+	// maxGraphQLBodyBytes bounds the size of POST requests.
+	maxGraphQLBodyBytes = 1 << 20
+)
+
+// handleGraphQL executes a GraphQL request given as a JSON body or, for GET,
+// as the query, operationName and variables query parameters. Requests that
+// cannot be executed at all answer 400 with the errors in GraphQL form.
+func (s *Server) handleGraphQL(schema *graphql.Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req graphql.Request
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if v := c.Query("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					respondBadRequest(c, "variables must be a JSON object")
+					return
+				}
+			}
+		} else if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLBodyBytes)).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondBadRequest(c, fmt.Sprintf("The body must not exceed %d bytes", maxGraphQLBodyBytes))
+				return
+			}
+			respondBadRequest(c, `The body must be a JSON object with a "query"`)
+			return
+		}
+		if req.Query == "" {
+			respondBadRequest(c, "query is required")
+			return
+		}
+
+		resp := schema.Execute(c, req, graphQLLimits)
+		status := http.StatusOK
+		if resp.Data == nil {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, resp)
+	}
+}
+
+// handleGraphQLSchema serves the schema in the GraphQL schema language.
+func handleGraphQLSchema(schema *graphql.Schema) gin.HandlerFunc {
+	sdl := schema.SDL()
+	return func(c *gin.Context) {
+		c.String(http.StatusOK, sdl)
+	}
+}
+
+// graphQLSchema builds the schema served at /api/v1/graphql. Lifters resolve
+// to db.LifterProfile, meets to db.Meet and entries to db.Entry; every field
+// that needs a query is resolved for all objects at one level of the response
+// at once, so a nested query costs one query per level rather than per object.
+func (s *Server) graphQLSchema() *graphql.Schema {
+	limitArg := func(def int) *graphql.Argument {
+		return &graphql.Argument{
+			Name:        "limit",
+			Description: fmt.Sprintf("Maximum number of items, at most %d.", maxGraphQLListSize),
+			Type:        graphql.Int,
+			Default:     def,
+		}
+	}
+
+	attemptMetrics := &graphql.Object{
+		Name:        "AttemptMetrics",
+		Description: "Attempt success and progression of one entry.",
+		Fields: append(intFields(
+			"successfulSquatAttempts", "successfulBenchAttempts", "successfulDeadliftAttempts", "totalSuccessfulAttempts",
+		), nullableFloatFields(
+			"squat1Perc", "squat2Perc", "squat3Perc", "bench1Perc", "bench2Perc", "bench3Perc",
+			"deadlift1Perc", "deadlift2Perc", "deadlift3Perc",
+			"squat1To2Kg", "squat2To3Kg", "bench1To2Kg", "bench2To3Kg", "deadlift1To2Kg", "deadlift2To3Kg",
+		)...),
+	}
+
+	bests := &graphql.Object{
+		Name:        "LifterBests",
+		Description: "A lifter's best results within one equipment category and event.",
+		Fields: append(append(stringFields("name", "equipment", "event"), intFields("meets")...), floatFields(
+			"bestSquatKg", "bestBenchKg", "bestDeadliftKg", "bestTotalKg", "bestDots",
+		)...),
+	}
+
+	lifter := &graphql.Object{Name: "Lifter", Description: "A lifter's career summary."}
+	meet := &graphql.Object{Name: "Meet", Description: "A meet, identified by federation, date and name."}
+	entry := &graphql.Object{Name: "Entry", Description: "One lifter's result at one meet."}
+
+	lifter.Fields = append(stringFields(
+		"name", "sex", "country", "federation", "weightClassKg", "ageClass", "division", "firstMeetDate", "lastMeetDate",
+	), intFields("meets")...)
+	lifter.Fields = append(lifter.Fields,
+		&graphql.Field{Name: "equipment", Type: nonNullList(graphql.String)},
+		&graphql.Field{Name: "events", Type: nonNullList(graphql.String)},
+	)
+	lifter.Fields = append(lifter.Fields, &graphql.Field{
+		Name:        "bests",
+		Description: "The lifter's bests per equipment category and event.",
+		Type:        nonNullList(bests),
+	})
+	lifter.Fields = append(lifter.Fields, &graphql.Field{
+		Name:        "entries",
+		Description: "The lifter's entries, most recent first.",
+		Type:        nonNullList(entry),
+		Args: []*graphql.Argument{
+			{Name: "equipment", Type: graphql.String},
+			{Name: "event", Type: graphql.String},
+			limitArg(50),
+		},
+		MaxLimit: maxGraphQLListSize,
+		Batch: func(ctx context.Context, sources []interface{}, args graphql.Args) ([]interface{}, error) {
+			limit, err := listLimit(args)
+			if err != nil {
+				return nil, err
+			}
+			filter := db.EntryFilter{Equipment: args.String("equipment"), Event: args.String("event")}
+			if err := filter.Validate(); err != nil {
+				return nil, err
+			}
+
+			names := make([]string, len(sources))
+			for i, source := range sources {
+				names[i] = source.(db.LifterProfile).Name
+			}
+			entries, err := db.GetLifterEntries(ctx, s.DB, names, filter)
+			if err != nil {
+				return nil, err
+			}
+
+			values := make([]interface{}, len(sources))
+			for i, name := range names {
+				values[i] = firstN(entries[name], limit)
+			}
+			return values, nil
+		},
+	})
+
+	meet.Fields = append(stringFields("federation", "date", "name", "country", "state", "town"), &graphql.Field{
+		Name:        "entries",
+		Description: "The entries of the meet, best Dots first.",
+		Type:        nonNullList(entry),
+		Args:        []*graphql.Argument{limitArg(100)},
+		MaxLimit:    maxGraphQLListSize,
+		Batch: func(ctx context.Context, sources []interface{}, args graphql.Args) ([]interface{}, error) {
+			limit, err := listLimit(args)
+			if err != nil {
+				return nil, err
+			}
+
+			keys := make([]db.MeetKey, len(sources))
+			for i, source := range sources {
+				keys[i] = source.(db.Meet).Key()
+			}
+			entries, err := db.GetMeetEntries(ctx, s.DB, uniqueMeetKeys(keys))
+			if err != nil {
+				return nil, err
+			}
+
+			values := make([]interface{}, len(sources))
+			for i, key := range keys {
+				values[i] = firstN(entries[key], limit)
+			}
+			return values, nil
+		},
+	})
+
+	entry.Fields = []*graphql.Field{{Name: "id", Type: &graphql.NonNull{Of: graphql.ID}}}
+	entry.Fields = append(entry.Fields, stringFields(
+		"name", "sex", "event", "equipment", "ageClass", "division", "weightClassKg", "place", "tested", "country",
+		"federation", "date", "meetName",
+	)...)
+	entry.Fields = append(entry.Fields, floatFields(
+		"age", "bodyweightKg",
+		"squat1Kg", "squat2Kg", "squat3Kg", "best3SquatKg",
+		"bench1Kg", "bench2Kg", "bench3Kg", "best3BenchKg",
+		"deadlift1Kg", "deadlift2Kg", "deadlift3Kg", "best3DeadliftKg",
+		"totalKg", "dots", "wilks", "glossbrenner", "goodlift",
+	)...)
+	entry.Fields = append(entry.Fields,
+		&graphql.Field{
+			Name:        "lifter",
+			Description: "The lifter who made the entry.",
+			Type:        lifter,
+			Batch: func(ctx context.Context, sources []interface{}, args graphql.Args) ([]interface{}, error) {
+				names := make([]string, len(sources))
+				for i, source := range sources {
+					names[i] = source.(db.Entry).Name
+				}
+				return s.lifterProfiles(ctx, names)
+			},
+		},
+		&graphql.Field{
+			Name:        "meet",
+			Description: "The meet the entry was made at.",
+			Type:        &graphql.NonNull{Of: meet},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return source.(db.Entry).Meet(), nil
+			},
+		},
+		&graphql.Field{
+			Name:        "metrics",
+			Description: "Attempt metrics, null if the entry has none.",
+			Type:        attemptMetrics,
+			Batch: func(ctx context.Context, sources []interface{}, args graphql.Args) ([]interface{}, error) {
+				ids := make([]string, len(sources))
+				for i, source := range sources {
+					ids[i] = source.(db.Entry).ID
+				}
+				metrics, err := db.GetAttemptMetrics(ctx, s.DB, ids)
+				if err != nil {
+					return nil, err
+				}
+
+				values := make([]interface{}, len(sources))
+				for i, id := range ids {
+					if m, ok := metrics[id]; ok {
+						values[i] = m
+					}
+				}
+				return values, nil
+			},
+		},
+	)
+
+	aggregateTable := &graphql.Enum{
+		Name:        "AggregateTable",
+		Description: "The precomputed aggregate tables.",
+		Values:      []string{"PERFORMANCE_TRENDS", "AGE_GROUP_PERFORMANCE", "WEIGHT_CLASS_DISTRIBUTION"},
+	}
+	aggregate := &graphql.Object{
+		Name:        "Aggregate",
+		Description: "A row of an aggregate table. Fields the table does not have are null.",
+		Fields: append(append(
+			nullableIntFields("year"),
+			nullableStringFields("sex", "equipment", "ageClass", "weightClass")...),
+			append(nullableIntFields("count"), nullableFloatFields(
+				"avgSquat", "avgBench", "avgDeadlift", "avgTotal",
+				"medianSquat", "p10Squat", "p25Squat", "p75Squat", "p90Squat",
+				"medianBench", "p10Bench", "p25Bench", "p75Bench", "p90Bench",
+				"medianDeadlift", "p10Deadlift", "p25Deadlift", "p75Deadlift", "p90Deadlift",
+				"medianTotal", "p10Total", "p25Total", "p75Total", "p90Total",
+			)...)...,
+		),
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.Field{
+			{
+				Name:        "lifter",
+				Description: "The lifter with the given name, or null.",
+				Type:        lifter,
+				Args:        []*graphql.Argument{{Name: "name", Type: &graphql.NonNull{Of: graphql.String}}},
+				Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+					values, err := s.lifterProfiles(ctx, []string{args.String("name")})
+					if err != nil {
+						return nil, err
+					}
+					return values[0], nil
+				},
+			},
+			{
+				Name:        "lifters",
+				Description: "Lifters matching a fuzzy name search and facet filters. Searches combined with facets must match at most 1000 names.",
+				Type:        nonNullList(lifter),
+				Args: []*graphql.Argument{
+					{Name: "search", Type: graphql.String},
+					{Name: "sex", Type: graphql.String},
+					{Name: "country", Type: graphql.String},
+					{Name: "federation", Type: graphql.String},
+					{Name: "equipment", Type: graphql.String},
+					{Name: "event", Type: graphql.String},
+					{
+						Name:        "limit",
+						Description: fmt.Sprintf("Maximum number of lifters, at most %d.", maxPageSize),
+						Type:        graphql.Int,
+						Default:     defaultPageSize,
+					},
+					{Name: "offset", Type: graphql.Int, Default: 0},
+				},
+				MaxLimit: maxPageSize,
+				Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+					limit, offset := args.Int("limit"), args.Int("offset")
+					if limit < 1 || limit > maxPageSize {
+						return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+					}
+					if offset < 0 {
+						return nil, errors.New("offset must not be negative")
+					}
+
+					filter := db.LifterFilter{
+						Sex:        args.String("sex"),
+						Country:    args.String("country"),
+						Federation: args.String("federation"),
+						Equipment:  args.String("equipment"),
+						Event:      args.String("event"),
+					}
+					if q := args.String("search"); q != "" {
+						var truncated bool
+						filter.Names, truncated = s.searchNames(q)
+						// Past the best matches lifters would be missing from
+						// the facets' results and later pages
+						if truncated && (hasFacets(filter) || offset+limit > maxSearchCandidates) {
+							return nil, fmt.Errorf("search matches more than %d names; narrow it down", maxSearchCandidates)
+						}
+					}
+					page, err := db.ListLifters(ctx, s.DB, filter, limit, offset)
+					if err != nil {
+						return nil, err
+					}
+
+					names := make([]string, len(page.Lifters))
+					for i, l := range page.Lifters {
+						names[i] = l.Name
+					}
+					return s.lifterProfiles(ctx, names)
+				},
+			},
+			{
+				Name:        "meet",
+				Description: "The meet with the given key, or null.",
+				Type:        meet,
+				Args: []*graphql.Argument{
+					{Name: "federation", Type: &graphql.NonNull{Of: graphql.String}},
+					{Name: "date", Type: &graphql.NonNull{Of: graphql.String}},
+					{Name: "name", Type: &graphql.NonNull{Of: graphql.String}},
+				},
+				Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+					key := db.MeetKey{Federation: args.String("federation"), Date: args.String("date"), Name: args.String("name")}
+					entries, err := db.GetMeetEntries(ctx, s.DB, []db.MeetKey{key})
+					if err != nil {
+						return nil, err
+					}
+					if len(entries[key]) == 0 {
+						return nil, nil
+					}
+					return entries[key][0].Meet(), nil
+				},
+			},
+			{
+				Name:        "entry",
+				Description: "The entry with the given ID, or null.",
+				Type:        entry,
+				Args:        []*graphql.Argument{{Name: "id", Type: &graphql.NonNull{Of: graphql.ID}}},
+				Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+					entries, err := db.GetEntries(ctx, s.DB, []string{args.String("id")})
+					if err != nil {
+						return nil, err
+					}
+					if e, ok := entries[args.String("id")]; ok {
+						return e, nil
+					}
+					return nil, nil
+				},
+			},
+			{
+				Name:        "aggregates",
+				Description: "Rows of an aggregate table.",
+				Type:        nonNullList(aggregate),
+				Args: []*graphql.Argument{
+					{Name: "table", Type: &graphql.NonNull{Of: aggregateTable}},
+					{Name: "sex", Type: graphql.String},
+					{Name: "equipment", Type: graphql.String},
+					{Name: "yearFrom", Type: graphql.Int},
+					{Name: "yearTo", Type: graphql.Int},
+				},
+				Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+					filter := db.AggregateFilter{
+						Sex:       args.String("sex"),
+						Equipment: args.String("equipment"),
+						YearFrom:  args.Int("yearFrom"),
+						YearTo:    args.Int("yearTo"),
+					}
+					endpoint := map[string]aggregateEndpoint{
+						"PERFORMANCE_TRENDS":        performanceTrendsEndpoint,
+						"AGE_GROUP_PERFORMANCE":     ageGroupPerformanceEndpoint,
+						"WEIGHT_CLASS_DISTRIBUTION": weightClassDistributionEndpoint,
+					}[args.String("table")]
+					return endpoint.fetch(ctx, s.DB, filter)
+				},
+			},
+		},
+	}
+
+	schema, err := graphql.NewSchema(query)
+	if err != nil {
+		panic(fmt.Sprintf("building GraphQL schema: %v", err))
+	}
+	return schema
+}
+
+// lifterProfiles returns the unfiltered profiles of the named lifters in
+// order, with nil for unknown names.
+func (s *Server) lifterProfiles(ctx context.Context, names []string) ([]interface{}, error) {
+	profiles, err := db.GetLifterProfiles(ctx, s.DB, uniqueStrings(names), db.EntryFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		if p, ok := profiles[name]; ok {
+			values[i] = p
+		}
+	}
+	return values, nil
+}
+
+// listLimit returns the limit argument of a list field, checking its range.
+func listLimit(args graphql.Args) (int, error) {
+	limit := args.Int("limit")
+	if limit < 1 || limit > maxGraphQLListSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxGraphQLListSize)
+	}
+	return limit, nil
+}
+
+// firstN returns at most the first n entries.
+func firstN(entries []db.Entry, n int) []db.Entry {
+	if len(entries) > n {
+		return entries[:n]
+	}
+	if entries == nil {
+		return []db.Entry{}
+	}
+	return entries
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func uniqueMeetKeys(keys []db.MeetKey) []db.MeetKey {
+	seen := make(map[db.MeetKey]bool, len(keys))
+	var unique []db.MeetKey
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
+
+func nonNullList(of graphql.Type) graphql.Type {
+	return &graphql.NonNull{Of: &graphql.List{Of: &graphql.NonNull{Of: of}}}
+}
+
+// scalarFields declares fields of one type resolved from the json tags of
+// the source struct.
+func scalarFields(typ graphql.Type, names []string) []*graphql.Field {
+	fields := make([]*graphql.Field, len(names))
+	for i, name := range names {
+		fields[i] = &graphql.Field{Name: name, Type: typ}
+	}
+	return fields
+}
+
+func stringFields(names ...string) []*graphql.Field {
+	return scalarFields(&graphql.NonNull{Of: graphql.String}, names)
+}
+
+func intFields(names ...string) []*graphql.Field {
+	return scalarFields(&graphql.NonNull{Of: graphql.Int}, names)
+}
+
+func floatFields(names ...string) []*graphql.Field {
+	return scalarFields(&graphql.NonNull{Of: graphql.Float}, names)
+}
+
+func nullableStringFields(names ...string) []*graphql.Field {
+	return scalarFields(graphql.String, names)
+}
+
+func nullableIntFields(names ...string) []*graphql.Field {
+	return scalarFields(graphql.Int, names)
+}
+
+func nullableFloatFields(names ...string) []*graphql.Field {
+	return scalarFields(graphql.Float, names)
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postGraphQL(t *testing.T, s *Server, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	s.Router.ServeHTTP(w, req)
+	return w
+}
+
+// TestGraphQL tests nested resolution from a lifter through their entries
+// and meets to the other entries of those meets, and the query limits.
+func TestGraphQL(t *testing.T) {
+	records := append([]*db.Record{{
+		Name: "Ida Holm", Sex: "F", Event: "SBD", Equipment: "Raw", BodyweightKg: 63, Date: "2024-03-01",
+		MeetName: "Nationals", Country: "Denmark", Federation: "DSF",
+		Squat1Kg: 120, Squat2Kg: 125, Squat3Kg: 130, Best3SquatKg: 130,
+		Bench1Kg: 70, Bench2Kg: 72.5, Bench3Kg: -75, Best3BenchKg: 72.5,
+		Deadlift1Kg: 150, Deadlift2Kg: 160, Deadlift3Kg: 165, Best3DeadliftKg: 165, TotalKg: 367.5, Dots: 400,
+	}}, testRecords...)
+	s := newTestServer(t, records)
+
+	w := postGraphQL(t, s, `{"query": "query($name: String!) { lifter(name: $name) { name meets bests { event bestTotalKg } entries(limit: 5) { meetName totalKg metrics { successfulBenchAttempts } meet { entries { lifter { name } } } } } nobody: lifter(name: \"Nobody\") { name } }", "variables": {"name": "Søren Ørbæk"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Lifter struct {
+				Name  string
+				Meets int
+				Bests []struct {
+					Event       string
+					BestTotalKg float64
+				}
+				Entries []struct {
+					MeetName string
+					TotalKg  float64
+					Metrics  struct{ SuccessfulBenchAttempts int }
+					Meet     struct {
+						Entries []struct{ Lifter struct{ Name string } }
+					}
+				}
+			}
+			Nobody *struct{}
+		}
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors: %v", resp.Errors)
+	}
+
+	lifter := resp.Data.Lifter
+	if lifter.Name != "Søren Ørbæk" || lifter.Meets != 2 || len(lifter.Entries) != 2 ||
+		len(lifter.Bests) != 1 || lifter.Bests[0].Event != "SBD" || lifter.Bests[0].BestTotalKg != 632.5 {
+		t.Fatalf("unexpected lifter: %+v", lifter)
+	}
+	if e := lifter.Entries[0]; e.MeetName != "Nationals" || e.TotalKg != 632.5 || e.Metrics.SuccessfulBenchAttempts != 2 {
+		t.Errorf("unexpected latest entry: %+v", e)
+	}
+	var others []string
+	for _, e := range lifter.Entries[0].Meet.Entries {
+		others = append(others, e.Lifter.Name)
+	}
+	if strings.Join(others, ", ") != "Søren Ørbæk, Ida Holm" {
+		t.Errorf("Nationals entries = %v", others)
+	}
+	if len(lifter.Entries[1].Meet.Entries) != 1 {
+		t.Errorf("Spring Open entries = %+v", lifter.Entries[1].Meet.Entries)
+	}
+	if resp.Data.Nobody != nil {
+		t.Errorf("unknown lifter resolved to %+v", resp.Data.Nobody)
+	}
+
+	// GET with variables, aggregates by enum.
+	query := url.Values{
+		"query":     {`query($sex: String) { aggregates(table: PERFORMANCE_TRENDS, sex: $sex) { year sex avgTotal count } }`},
+		"variables": {`{"sex": "F"}`},
+	}
+	w = get(t, s, "/api/v1/graphql?"+query.Encode())
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"avgTotal":367.5,"count":null`) {
+		t.Errorf("aggregates: status %d: %s", w.Code, w.Body)
+	}
+
+	for name, body := range map[string]string{
+		"too deep":        `{"query": "{ lifter(name: \"x\") { entries { lifter { entries { lifter { entries { lifter { entries { lifter { entries { name } } } } } } } } } } }"}`,
+		"too complex":     `{"query": "{ lifters(limit: 100) { entries(limit: 1000) { name } } }"}`,
+		"negative limit":  `{"query": "{ a: lifters(limit: 100) { entries(limit: 1000) { name } } b: lifters(limit: -1000) { entries(limit: 1000) { name } } }"}`,
+		"limit too large": `{"query": "{ lifters(limit: 1) { entries(limit: 9223372036854775807) { name } } }"}`,
+		"syntax error":    `{"query": "{ lifter(name: "}`,
+		"no query":        `{}`,
+		"nested too deep": `{"query": "` + strings.Repeat("{a", 1<<16) + `"}`,
+		"body too large":  `{"query": "{ lifters { name } }", "x": "` + strings.Repeat("x", maxGraphQLBodyBytes) + `"}`,
+	} {
+		if w := postGraphQL(t, s, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d: %s", name, w.Code, w.Body)
+		}
+	}
+
+	w = get(t, s, "/api/v1/graphql/schema")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "entries(equipment: String, event: String, limit: Int = 50): [Entry!]!") {
+		t.Errorf("schema: status %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/search"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codeDataLoading is the error code of API requests made while the server
+// waits for its data.
+const codeDataLoading = "data_loading"
+
+// Timeouts configure the HTTP server. Zero means no timeout.
+type Timeouts struct {
+	Read     time.Duration
+	Write    time.Duration
+	Idle     time.Duration
+	Shutdown time.Duration
+}
+
+// Serve serves HTTP on addr until ctx is cancelled. It then stops accepting
+// connections and waits up to timeouts.Shutdown for in-flight requests.
+func (s *Server) Serve(ctx context.Context, addr string, timeouts Timeouts) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Router,
+		ReadTimeout:       timeouts.Read,
+		ReadHeaderTimeout: timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, waiting for in-flight requests")
+	shutdownCtx := context.Background()
+	if timeouts.Shutdown > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, timeouts.Shutdown)
+		defer cancel()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// SetLoading marks the server as waiting for its data, for example while the
+// first ingest runs after it started. Until SetData is called the API
+// responds with 503 and /readyz reports the server as loading.
+func (s *Server) SetLoading() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loading = true
+	s.loadErr = nil
+}
+
+// SetData installs the lifter names and database once they are loaded and
+// ends the loading state.
+func (s *Server) SetData(lifterNames []string, database *sql.DB) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LifterNames = lifterNames
+	s.Index = search.NewIndex(lifterNames)
+	s.DB = database
+	s.loading = false
+	s.loadErr = nil
+}
+
+// SetLoadFailed records that loading the data failed. The server stays
+// unready and the API reports err.
+func (s *Server) SetLoadFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadErr = err
+}
+
+// requireData is the middleware of the API routes that responds with 503
+// while the data is loading. Handlers may only read DB and Index after it:
+// the lock orders their reads after SetData.
+func (s *Server) requireData() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mu.RLock()
+		loading, loadErr := s.loading, s.loadErr
+		s.mu.RUnlock()
+
+		switch {
+		case loadErr != nil:
+			respondError(c, http.StatusServiceUnavailable, codeInternal, fmt.Sprintf("Loading the data failed: %v", loadErr))
+		case loading:
+			c.Header("Retry-After", "30")
+			respondError(c, http.StatusServiceUnavailable, codeDataLoading, "The data is being loaded, try again shortly")
+		}
+	}
+}
+
+// readiness is the body of /readyz.
+type readiness struct {
+	// Status is ready, loading or unavailable.
+	Status        string `json:"status"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+	DataRevision  string `json:"dataRevision,omitempty"`
+	BuiltAt       string `json:"builtAt,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// handleHealthz reports that the process is up.
+func (s *Server) handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz reports whether the server can answer API requests: its data
+// is loaded, the database responds, was built with the current schema and
+// records a data revision. It responds with 503 otherwise.
+func (s *Server) handleReadyz(c *gin.Context) {
+	s.mu.RLock()
+	loading, loadErr, database := s.loading, s.loadErr, s.DB
+	s.mu.RUnlock()
+
+	unavailable := func(status, message string) {
+		c.JSON(http.StatusServiceUnavailable, readiness{Status: status, Error: message})
+	}
+	switch {
+	case loadErr != nil:
+		unavailable("unavailable", fmt.Sprintf("loading the data failed: %v", loadErr))
+		return
+	case loading:
+		unavailable("loading", "the data is being loaded")
+		return
+	case database == nil:
+		unavailable("unavailable", "no database")
+		return
+	}
+
+	if err := database.PingContext(c); err != nil {
+		unavailable("unavailable", fmt.Sprintf("database: %v", err))
+		return
+	}
+	version, err := db.GetSchemaVersion(c, database)
+	if errors.Is(err, db.ErrNoRows) {
+		unavailable("unavailable", "database records no schema version; rebuild it")
+		return
+	}
+	if err != nil {
+		unavailable("unavailable", fmt.Sprintf("schema version: %v", err))
+		return
+	}
+	if version != db.SchemaVersion {
+		unavailable("unavailable", fmt.Sprintf("database has schema version %d, expected %d; rebuild it", version, db.SchemaVersion))
+		return
+	}
+	rev, err := db.GetDataRevision(c, database)
+	if err != nil {
+		unavailable("unavailable", fmt.Sprintf("data revision: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, readiness{Status: "ready", SchemaVersion: version, DataRevision: rev.Revision, BuiltAt: rev.BuiltAt})
+}
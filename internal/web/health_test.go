@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"liftmetrics/internal/db"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadyz tests readiness through loading, a failed load and a database
+// with and without a data revision.
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	database := s.DB
+
+	if w := get(t, s, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("healthz: status %d", w.Code)
+	}
+
+	readyz := func() readiness {
+		t.Helper()
+		w := get(t, s, "/readyz")
+		var r readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if (w.Code == http.StatusOK) != (r.Status == "ready") {
+			t.Errorf("readyz: status %d for %+v", w.Code, r)
+		}
+		return r
+	}
+
+	if r := readyz(); r.Status != "unavailable" || !strings.Contains(r.Error, "data revision") {
+		t.Errorf("without a revision: %+v", r)
+	}
+	if err := db.SetDataRevision(context.Background(), database, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if r := readyz(); r.Status != "ready" || r.SchemaVersion != db.SchemaVersion || r.DataRevision != "abc123" {
+		t.Errorf("with a revision: %+v", r)
+	}
+
+	s.SetLoading()
+	if r := readyz(); r.Status != "loading" {
+		t.Errorf("loading: %+v", r)
+	}
+	w := get(t, s, "/api/search?q=S%C3%B8ren")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), codeDataLoading) || w.Header().Get("Retry-After") == "" {
+		t.Errorf("search while loading: status %d: %s", w.Code, w.Body)
+	}
+
+	s.SetLoadFailed(errors.New("download failed"))
+	if r := readyz(); r.Status != "unavailable" || !strings.Contains(r.Error, "download failed") {
+		t.Errorf("failed load: %+v", r)
+	}
+
+	s.SetData([]string{"Søren Ørbæk"}, database)
+	if r := readyz(); r.Status != "ready" {
+		t.Errorf("after SetData: %+v", r)
+	}
+	if w := get(t, s, "/api/search?q=S%C3%B8ren"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Søren Ørbæk") {
+		t.Errorf("search after SetData: status %d: %s", w.Code, w.Body)
+	}
+}
+
+// TestServeShutdown tests that Serve returns cleanly once its context is
+// cancelled.
+func TestServeShutdown(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, addr, Timeouts{Read: time.Second, Shutdown: 5 * time.Second}) }()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + addr + "/healthz"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("server did not start: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz: status %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+}
@@ -0,0 +1,195 @@
+package web
+
+import (
+	"fmt"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/export"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+
+	// maxSearchCandidates caps how many fuzzy matches are handed to the
+	// database when a search is combined with facet filters. Listings of
+	// searches with more matches are marked truncated.
+	maxSearchCandidates = 1000
+)
+
+// lifterFilterFromQuery reads the facet filters from the query string.
+func lifterFilterFromQuery(c *gin.Context) db.LifterFilter {
+	return db.LifterFilter{
+		Sex:           c.Query("sex"),
+		Country:       c.Query("country"),
+		Federation:    c.Query("federation"),
+		Equipment:     c.Query("equipment"),
+		Event:         c.Query("event"),
+		WeightClassKg: c.Query("weightClassKg"),
+		AgeClass:      c.Query("ageClass"),
+		Division:      c.Query("division"),
+	}
+}
+
+// entryFilterFromQuery reads the equipment and event filters from the query
+// string. If they are invalid it responds with 400 and returns false.
+func entryFilterFromQuery(c *gin.Context) (db.EntryFilter, bool) {
+	filter := db.EntryFilter{
+		Equipment: c.Query("equipment"),
+		Event:     c.Query("event"),
+	}
+	if err := filter.Validate(); err != nil {
+		respondBadRequest(c, err.Error())
+		return db.EntryFilter{}, false
+	}
+	return filter, true
+}
+
+// groupFromQuery reads the group parameter, which defaults to true. If it is
+// invalid it responds with 400 and returns false as its second result.
+func groupFromQuery(c *gin.Context) (grouped, ok bool) {
+	grouped, err := strconv.ParseBool(c.DefaultQuery("group", "true"))
+	if err != nil {
+		respondBadRequest(c, "group must be true or false")
+		return false, false
+	}
+	return grouped, true
+}
+
+// entryGroup holds a lifter's entries for one equipment category and event.
+type entryGroup struct {
+	Equipment string      `json:"equipment"`
+	Event     string      `json:"event"`
+	Entries   interface{} `json:"entries"`
+}
+
+// groupEntries splits rows, already ordered by equipment and event, into one
+// group per equipment/event pair, preserving the order within each group.
+func groupEntries[T any](rows []T, key func(T) (equipment, event string)) []entryGroup {
+	groups := []entryGroup{}
+	var entries []T
+	for i, row := range rows {
+		entries = append(entries, row)
+		equipment, event := key(row)
+		if i+1 < len(rows) {
+			nextEquipment, nextEvent := key(rows[i+1])
+			if nextEquipment == equipment && nextEvent == event {
+				continue
+			}
+		}
+		groups = append(groups, entryGroup{Equipment: equipment, Event: event, Entries: entries})
+		entries = nil
+	}
+	return groups
+}
+
+// hasFacets reports whether any facet filter is set.
+func hasFacets(f db.LifterFilter) bool {
+	return f.Sex != "" || f.Country != "" || f.Federation != "" || f.Equipment != "" || f.Event != "" ||
+		f.WeightClassKg != "" || f.AgeClass != "" || f.Division != ""
+}
+
+// paginationFromQuery reads limit and offset from the query string.
+func paginationFromQuery(c *gin.Context) (limit, offset int, err error) {
+	limit, offset = defaultPageSize, 0
+
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize)
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// searchNames returns the names matching the fuzzy query q, best match
+// first, up to maxSearchCandidates of them. truncated reports whether more
+// names matched.
+func (s *Server) searchNames(q string) (names []string, truncated bool) {
+	names = []string{}
+	for _, r := range s.Index.Search(q, maxSearchCandidates+1) {
+		names = append(names, r.Name)
+	}
+	if len(names) > maxSearchCandidates {
+		return names[:maxSearchCandidates], true
+	}
+	return names, false
+}
+
+// handleListLifters lists lifters filtered by facets and, when q is given,
+// by fuzzy name search. Search results keep their relevance order; plain
+// listings are ordered by name.
+func (s *Server) handleListLifters(c *gin.Context) {
+	limit, offset, err := paginationFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	filter := lifterFilterFromQuery(c)
+	truncated := false
+	if q := c.Query("q"); q != "" {
+		filter.Names, truncated = s.searchNames(q)
+	}
+
+	page, err := db.ListLifters(c, s.DB, filter, limit, offset)
+	if err != nil {
+		respondDBError(c, err, "lifters")
+		return
+	}
+	page.Truncated = truncated
+
+	if format != formatJSON {
+		tables := []exportTable{{name: "Lifters", rows: page.Lifters}}
+		// A CSV file holds the lifters only; the facet counts get a sheet
+		// of their own in workbooks
+		if format == export.FormatXLSX {
+			tables = append(tables, facetTable(page.Facets))
+		}
+		respondExport(c, format, "lifters", tables...)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// respondDetailsExport sends a lifter's details as a download with one table
+// per equipment category and event.
+func respondDetailsExport(c *gin.Context, format, name string, details []db.LifterDetails) {
+	respondExport(c, format, name+" details", groupTables(details, func(d db.LifterDetails) (string, string) {
+		return d.Equipment, d.Event
+	})...)
+}
+
+// facetTable exports the facet counts of a listing as one table.
+func facetTable(facets map[string][]db.FacetCount) exportTable {
+	names := make([]string, 0, len(facets))
+	for f := range facets {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	var rows []map[string]interface{}
+	for _, f := range names {
+		for _, count := range facets[f] {
+			rows = append(rows, map[string]interface{}{"facet": f, "value": count.Value, "count": count.Count})
+		}
+	}
+	return exportTable{name: "Facets", rows: rows, columns: []string{"facet", "value", "count"}}
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the ID of a request. A valid ID sent by a client
+// or proxy is kept, so that its logs can be matched with ours; otherwise one
+// is generated. It is echoed in the response either way.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID reports whether id is short and made of characters that
+// are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogger is the outermost middleware. It assigns the request ID,
+// logs one JSON line per request to s.Logger and records the request
+// duration for /metrics.
+func (s *Server) requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set("requestID", id)
+		c.Header(requestIDHeader, id)
+
+		c.Next()
+
+		duration := time.Since(start)
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		requestDuration.Observe(duration.Seconds(), c.Request.Method, route, strconv.Itoa(status))
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("requestId", id),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("query", c.Request.URL.RawQuery),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Float64("durationMs", float64(duration.Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("clientIp", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		s.Logger.LogAttrs(c, level, "request", attrs...)
+	}
+}
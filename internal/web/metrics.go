@@ -0,0 +1,95 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/metrics"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestDuration records the latency of HTTP requests by route pattern, so
+// that /api/v1/lifters/:name is one series however many lifters are asked
+// for. Requests that match no route share the route "unmatched".
+var requestDuration = metrics.Default.NewHistogram("liftmetrics_http_request_duration_seconds",
+	"Duration of HTTP requests by method, route and status.", metrics.DefBuckets, "method", "route", "status")
+
+// tableRowCounts caches the row counts of the tables, which only change
+// with the data revision and are slow to count on every scrape.
+type tableRowCounts struct {
+	mu       sync.Mutex
+	revision string
+	counts   map[string]int64
+}
+
+// get returns the row counts of database at rev, counting them if rev has
+// changed since the last call.
+func (t *tableRowCounts) get(ctx context.Context, database *sql.DB, rev string) (map[string]int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts != nil && t.revision == rev {
+		return t.counts, nil
+	}
+	counts, err := db.CountTableRows(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	t.revision, t.counts = rev, counts
+	return counts, nil
+}
+
+// handleMetrics serves the metrics in the Prometheus text format: those of
+// metrics.Default, followed by the row counts, revision and build time of
+// the data once it is loaded.
+func (s *Server) handleMetrics(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := metrics.Default.Write(&buf); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	s.mu.RLock()
+	database := s.DB
+	s.mu.RUnlock()
+	if database != nil {
+		if err := s.writeDataMetrics(c, &buf, database); err != nil {
+			// The process metrics are still worth serving
+			c.Error(err)
+		}
+	}
+
+	c.Data(http.StatusOK, metrics.ContentType, buf.Bytes())
+}
+
+// writeDataMetrics writes the metrics describing the loaded data to buf.
+func (s *Server) writeDataMetrics(ctx context.Context, buf *bytes.Buffer, database *sql.DB) error {
+	rev, err := db.GetDataRevision(ctx, database)
+	if err != nil && !errors.Is(err, db.ErrNoRows) {
+		return err
+	}
+	counts, err := s.tableRows.get(ctx, database, rev.String())
+	if err != nil {
+		return err
+	}
+
+	r := metrics.NewRegistry()
+	rows := r.NewGauge("liftmetrics_table_rows", "Number of rows per database table.", "table")
+	for table, n := range counts {
+		rows.Set(float64(n), table)
+	}
+	if rev.BuiltAt != "" {
+		r.NewGauge("liftmetrics_data_revision_info", "The OpenPowerlifting revision the database was built from.", "revision").
+			Set(1, rev.Revision)
+		if builtAt, err := time.Parse(time.RFC3339Nano, rev.BuiltAt); err == nil {
+			r.NewGauge("liftmetrics_data_built_timestamp_seconds", "Unix time the data was last refreshed.").
+				Set(float64(builtAt.UnixNano()) / 1e9)
+		}
+	}
+	return r.Write(buf)
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMetrics tests that /metrics reports the request latencies by route
+// and describes the loaded data.
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	if err := db.SetDataRevision(context.Background(), s.DB, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	get(t, s, "/api/v1/lifters/S%C3%B8ren%20%C3%98rb%C3%A6k")
+	get(t, s, "/no/such/page")
+
+	w := get(t, s, "/metrics")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`liftmetrics_http_request_duration_seconds_count{method="GET",route="/api/v1/lifters/:name",status="200"}`,
+		`liftmetrics_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"}`,
+		`liftmetrics_db_query_duration_seconds_count{function="GetLifterProfiles"}`,
+		`liftmetrics_table_rows{table="records"} 2`,
+		`liftmetrics_data_revision_info{revision="abc123"} 1`,
+		`liftmetrics_data_built_timestamp_seconds `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s:\n%s", want, body)
+		}
+	}
+}
+
+// TestRequestLogger tests that each request is logged as JSON under an ID
+// that is echoed in the response, keeping a valid ID sent by the client.
+func TestRequestLogger(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	var logs bytes.Buffer
+	s.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=S%C3%B8ren", nil)
+	req.Header.Set(requestIDHeader, "trace-42")
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	if got := w.Header().Get(requestIDHeader); got != "trace-42" {
+		t.Errorf("request ID %q, want trace-42", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	s.Router.ServeHTTP(w, req)
+	generated := w.Header().Get(requestIDHeader)
+	if generated == "" || generated == "bad id\n" {
+		t.Errorf("invalid request ID kept: %q", generated)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), logs.String())
+	}
+	var entry struct {
+		RequestID string `json:"requestId"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.RequestID != "trace-42" || entry.Route != "/api/search" || entry.Status != http.StatusOK {
+		t.Errorf("log entry %+v", entry)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.RequestID != generated {
+		t.Errorf("logged ID %q, responded %q", entry.RequestID, generated)
+	}
+}
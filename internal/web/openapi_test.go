@@ -0,0 +1,189 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/forecast"
+	"liftmetrics/internal/graphql"
+	"liftmetrics/internal/plugins"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openAPIDocument is the subset of an OpenAPI document the tests look at.
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+// documentedSchemas maps each schema in openapi.json to the Go type it describes.
+var documentedSchemas = map[string]interface{}{
+	"Error":                  errorResponse{},
+	"FacetCount":             db.FacetCount{},
+	"LifterSummary":          db.LifterSummary{},
+	"LifterPage":             db.LifterPage{},
+	"LifterProfile":          db.LifterProfile{},
+	"LifterBests":            db.LifterBests{},
+	"LifterDetails":          db.LifterDetails{},
+	"LifterPerformance":      db.LifterPerformance{},
+	"LifterDetailsGroup":     entryGroup{},
+	"LifterPerformanceGroup": entryGroup{},
+	"LifterStats":            db.LifterStats{},
+	"EntryField":             entryFieldInfo{},
+	"EntryPage":              db.EntryPage{},
+	"SQLConsoleRequest":      sqlConsoleRequest{},
+	"GraphQLRequest":         graphql.Request{},
+	"GraphQLResponse":        graphql.Response{},
+	"GraphQLError":           graphql.Error{},
+	"WeightClassCount":       db.WeightClassCount{},
+	"AgeGroupStats":          db.AgeGroupStats{},
+	"PerformanceTrend":       db.PerformanceTrend{},
+	"LifterAggregateSBD":     db.LifterAggregateSBD{},
+	"LifterAggregateBench":   db.LifterAggregateBench{},
+	"WideTable":              wideTable{},
+	"CustomMetric":           plugins.Definition{},
+	"TablePage":              db.TablePage{},
+	"CoefficientScores":      coefficientScores{},
+	"AttemptStrategy":        db.AttemptStrategy{},
+	"AttemptOutcomes":        db.AttemptOutcomes{},
+	"JumpSizeOutcomes":       db.JumpSizeOutcomes{},
+	"MissFollowUp":           db.MissFollowUp{},
+	"OpenerStats":            db.OpenerStats{},
+	"AttemptRecommendation":  db.AttemptRecommendation{},
+	"LiftRecommendation":     db.LiftRecommendation{},
+	"RecommendedAttempt":     db.RecommendedAttempt{},
+	"LifterForecast":         db.LifterForecast{},
+	"LiftForecast":           db.LiftForecast{},
+	"ForecastBacktest":       forecast.Summary{},
+	"LifterPeerRanks":        db.LifterPeerRanks{},
+	"EntryPeerRank":          db.EntryPeerRank{},
+	"RankingPage":            db.RankingPage{},
+	"RankingEntry":           db.RankingEntry{},
+}
+
+func loadOpenAPIDocument(t *testing.T) openAPIDocument {
+	t.Helper()
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+// TestOpenAPIRoutes checks that openapi.json documents exactly the /api/v1
+// routes registered on the router.
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+	server := newTestServer(t, nil)
+
+	param := regexp.MustCompile(`:(\w+)`)
+	registered := map[string]bool{}
+	for _, route := range server.Router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		path := param.ReplaceAllString(strings.TrimPrefix(route.Path, "/api/v1"), "{$1}")
+		key := strings.ToLower(route.Method) + " " + path
+		registered[key] = true
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[method+" "+path] = true
+		}
+	}
+
+	for _, key := range sortedKeys(registered) {
+		if !documented[key] {
+			t.Errorf("Route %q is registered but not documented in openapi.json", key)
+		}
+	}
+	for _, key := range sortedKeys(documented) {
+		if !registered[key] {
+			t.Errorf("Route %q is documented in openapi.json but not registered", key)
+		}
+	}
+}
+
+// TestOpenAPISchemas checks that every documented schema lists exactly the
+// JSON fields of the Go type that is serialized for it, and that every $ref
+// in the document resolves.
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	for name, value := range documentedSchemas {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("Schema %s is missing from openapi.json", name)
+			continue
+		}
+
+		want := jsonFields(reflect.TypeOf(value))
+		var got []string
+		for property := range schema.Properties {
+			got = append(got, property)
+		}
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Schema %s has properties %v, want %v", name, got, want)
+		}
+	}
+
+	for name := range doc.Components.Schemas {
+		if _, ok := documentedSchemas[name]; !ok {
+			t.Errorf("Schema %s is not mapped to a Go type in documentedSchemas", name)
+		}
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &raw); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	for _, ref := range regexp.MustCompile(`"\$ref":\s*"#/([^"]+)"`).FindAllStringSubmatch(string(openAPISpec), -1) {
+		var node interface{} = raw
+		for _, part := range strings.Split(ref[1], "/") {
+			m, ok := node.(map[string]interface{})
+			if !ok {
+				node = nil
+				break
+			}
+			node = m[part]
+		}
+		if node == nil {
+			t.Errorf("Unresolved $ref #/%s", ref[1])
+		}
+	}
+}
+
+// jsonFields returns the sorted JSON field names of a struct type.
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		fields = append(fields, tag)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"liftmetrics/internal/db"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleLifterPeerRanks places a lifter's entries and current bests among
+// their peers.
+func (s *Server) handleLifterPeerRanks(c *gin.Context) {
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	ranks, err := db.GetLifterPeerRanks(c, s.DB, c.Param("name"), filter)
+	if err != nil {
+		respondDBError(c, err, "lifter peer ranks")
+		return
+	}
+	c.JSON(http.StatusOK, ranks)
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestLifterPeerRanks(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	name := url.PathEscape("Søren Ørbæk")
+
+	w := get(t, s, "/api/v1/lifters/"+name+"/peer-ranks")
+	var ranks db.LifterPeerRanks
+	if err := json.Unmarshal(w.Body.Bytes(), &ranks); err != nil || len(ranks.Entries) != 2 || len(ranks.CurrentBests) != 1 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if b := ranks.CurrentBests[0]; b.Year != 2024 || b.GlobalRank == nil || *b.GlobalRank != 1 || b.CountryRank == nil {
+		t.Errorf("current best %+v", b)
+	}
+
+	w = get(t, s, "/api/v1/lifters/"+name+"/details?group=false")
+	var details []db.LifterDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil || len(details) == 0 || details[0].GlobalPercentile == nil {
+		t.Errorf("details with peer ranks: status %d: %s", w.Code, w.Body)
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/lifters/Nobody/peer-ranks":               http.StatusNotFound,
+		"/api/v1/lifters/" + name + "/peer-ranks?event=X": http.StatusBadRequest,
+	} {
+		if w := get(t, s, target); w.Code != status {
+			t.Errorf("%s: status %d, want %d", target, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"liftmetrics/internal/db"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rankingFilterFromQuery reads the dimensions and sort key of a ranking from
+// the query string. If they are invalid it responds with 400 and returns
+// false.
+func rankingFilterFromQuery(c *gin.Context) (db.RankingFilter, bool) {
+	filter := db.RankingFilter{
+		Sex:           c.Query("sex"),
+		Equipment:     c.Query("equipment"),
+		Event:         c.Query("event"),
+		WeightClassKg: c.Query("weightClassKg"),
+		AgeClass:      c.Query("ageClass"),
+		Country:       c.Query("country"),
+		Federation:    c.Query("federation"),
+		SortBy:        c.Query("sortBy"),
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"year", &filter.Year}, {"windowDays", &filter.WindowDays}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondBadRequest(c, p.name+" must be a positive integer")
+			return db.RankingFilter{}, false
+		}
+		*p.value = n
+	}
+	if err := filter.Validate(); err != nil {
+		respondBadRequest(c, err.Error())
+		return db.RankingFilter{}, false
+	}
+	return filter, true
+}
+
+// handleRankings lists lifters by their best result for the sort key among
+// the entries matching the ranking's dimensions.
+func (s *Server) handleRankings(c *gin.Context) {
+	filter, ok := rankingFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset, err := paginationFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	page, err := db.GetRankings(c, s.DB, filter, limit, offset)
+	if err != nil {
+		respondDBError(c, err, "rankings")
+		return
+	}
+
+	if format != formatJSON {
+		respondExport(c, format, "rankings", exportTable{name: "Rankings", rows: page.Rankings})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
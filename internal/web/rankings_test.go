@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRankings(t *testing.T) {
+	s := newTestServer(t, testRecords)
+
+	w := get(t, s, "/api/v1/rankings?equipment=Raw&country=Denmark&year=2024&sortBy=dots")
+	var page db.RankingPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.Total != 1 || len(page.Rankings) != 1 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if e := page.Rankings[0]; e.Rank != 1 || e.Name != "Søren Ørbæk" || e.Date != "2024-03-01" || page.SortBy != "dots" {
+		t.Errorf("ranking %+v", page)
+	}
+
+	w = get(t, s, "/api/v1/rankings?format=csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "rank,name,") || strings.Count(w.Body.String(), "\n") != 2 {
+		t.Errorf("CSV status %d: %s", w.Code, w.Body)
+	}
+
+	for _, target := range []string{
+		"/api/v1/rankings?sortBy=press",
+		"/api/v1/rankings?year=2024&windowDays=30",
+		"/api/v1/rankings?windowDays=0",
+		"/api/v1/rankings?limit=0",
+		"/api/v1/rankings?event=X",
+	} {
+		if w := get(t, s, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, w.Code)
+		}
+	}
+}
@@ -3,11 +3,15 @@ package web
 import (
 	"database/sql"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"liftmetrics/internal/db"
-	"log"
+	"liftmetrics/internal/plugins"
+	"liftmetrics/internal/search"
+	"log/slog"
 	"net/http"
-	"path/filepath"
-	"strings"
+	"os"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,32 +20,64 @@ type Server struct {
 	LifterNames []string
 	DB          *sql.DB
 	Router      *gin.Engine
+	Index       *search.Index
+	SQLConsole  *SQLConsoleConfig
+	Cache       *ResponseCache
+	// CustomMetrics are the user-defined metrics served under
+	// /api/v1/custom-metrics.
+	CustomMetrics []*plugins.Definition
+	// Logger receives one JSON line per request.
+	Logger *slog.Logger
+
+	// mu guards the loading state, and the data while it is replaced by
+	// SetData.
+	mu      sync.RWMutex
+	loading bool
+	loadErr error
+
+	tableRows tableRowCounts
 }
 
-func NewServer(lifterNames []string, db *sql.DB, indexHTMLPath string) *Server {
+// responseCacheSize bounds the memory used by cached API responses.
+const responseCacheSize = 64 << 20
+
+// NewServer returns a server for the given lifters and database. assets holds
+// the frontend: templates/index.html and the static directory.
+func NewServer(lifterNames []string, db *sql.DB, assets fs.FS) (*Server, error) {
 	s := &Server{
 		LifterNames: lifterNames,
 		DB:          db,
-		Router:      gin.Default(),
+		Router:      gin.New(),
+		Index:       search.NewIndex(lifterNames),
+		Cache:       NewResponseCache(responseCacheSize),
+		Logger:      slog.New(slog.NewJSONHandler(os.Stdout, nil)),
 	}
+	s.Router.Use(s.requestLogger(), gin.Recovery())
 
-	// Load the specific index.html file
-	s.Router.LoadHTMLFiles(indexHTMLPath)
-
-	// Set up static file serving
-	staticPath := filepath.Join(filepath.Dir(indexHTMLPath), "..", "static")
-	s.Router.Static("/static", staticPath)
+	// Load the page template and serve the static files
+	tmpl, err := template.ParseFS(assets, "templates/index.html")
+	if err != nil {
+		return nil, fmt.Errorf("loading templates: %w", err)
+	}
+	s.Router.SetHTMLTemplate(tmpl)
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return nil, fmt.Errorf("loading static files: %w", err)
+	}
+	s.Router.StaticFS("/static", http.FS(static))
 
 	// Set up routes
 	s.Router.GET("/", s.handleRoot)
-	s.Router.GET("/api/search", s.handleSearch)
-	s.Router.GET("/api/lifter-details", s.handleLifterDetails)
-
-	return s
-}
-
-func (s *Server) Start(addr string) error {
-	return s.Router.Run(addr)
+	s.Router.GET("/healthz", s.handleHealthz)
+	s.Router.GET("/readyz", s.handleReadyz)
+	s.Router.GET("/metrics", s.handleMetrics)
+	api := s.Router.Group("/api", s.requireData(), s.httpCache())
+	api.GET("/search", s.handleSearch)
+	api.GET("/lifters", s.handleListLifters)
+	api.GET("/lifter-details", s.handleLifterDetails)
+	s.registerV1Routes(api.Group("/v1"))
+
+	return s, nil
 }
 
 func (s *Server) handleRoot(c *gin.Context) {
@@ -51,39 +87,78 @@ func (s *Server) handleRoot(c *gin.Context) {
 }
 
 func (s *Server) handleSearch(c *gin.Context) {
-	query := normalizeSpace(strings.ToLower(strings.TrimSpace(c.Query("q"))))
-	log.Printf("Received search query: %q", query) // Debugging line
-
-	var results []string
-	for _, name := range s.LifterNames {
-		normalizedName := normalizeSpace(strings.ToLower(name))
-		if strings.Contains(normalizedName, query) {
-			results = append(results, name)
-			if len(results) >= 25 {
-				break
-			}
-		}
+	query := c.Query("q")
+
+	limit, offset, err := paginationFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
 	}
 
-	log.Printf("Number of results: %d", len(results))
+	results := []string{}
+	if filter := lifterFilterFromQuery(c); hasFacets(filter) {
+		// Without a query the facets alone select the lifters
+		truncated := false
+		if query != "" {
+			filter.Names, truncated = s.searchNames(query)
+		}
+		page, err := db.ListLifters(c, s.DB, filter, limit, offset)
+		if err != nil {
+			respondDBError(c, err, "lifters")
+			return
+		}
+		if truncated {
+			c.Header("X-Truncated", "true")
+		}
+		for _, l := range page.Lifters {
+			results = append(results, l.Name)
+		}
+	} else {
+		matches := s.Index.Search(query, offset+limit)
+		for i := offset; i < len(matches); i++ {
+			results = append(results, matches[i].Name)
+		}
+	}
 
+	if format != formatJSON {
+		rows := make([]map[string]interface{}, len(results))
+		for i, name := range results {
+			rows[i] = map[string]interface{}{"name": name}
+		}
+		respondExport(c, format, "search", exportTable{name: "Lifters", rows: rows, columns: []string{"name"}})
+		return
+	}
 	c.JSON(http.StatusOK, results)
 }
 
-func normalizeSpace(s string) string {
-	return strings.Join(strings.Fields(s), " ")
-}
-
 func (s *Server) handleLifterDetails(c *gin.Context) {
 	lifterName := c.Query("name")
 	if lifterName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Lifter name is required"})
+		respondBadRequest(c, "Lifter name is required")
 		return
 	}
 
-	details, err := db.GetLifterDetails(c, s.DB, lifterName)
+	filter, ok := entryFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	details, err := db.GetLifterDetails(c, s.DB, lifterName, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error fetching lifter details: %v", err)})
+		respondDBError(c, err, "lifter details")
+		return
+	}
+
+	if format != formatJSON {
+		respondDetailsExport(c, format, lifterName, details)
 		return
 	}
 
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"liftmetrics/internal/db"
+	"liftmetrics/internal/export"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SQLConsoleConfig enables the read-only SQL console. The route is always
+// registered but answers 404 while Server.SQLConsole is nil.
+type SQLConsoleConfig struct {
+	// DB must be opened with db.OpenReadOnly.
+	DB *sql.DB
+	// Token is the bearer token clients must present.
+	Token   string
+	Options db.ConsoleOptions
+}
+
+// sqlConsoleRequest is the body of a console request.
+type sqlConsoleRequest struct {
+	Query string `json:"query"`
+}
+
+// handleSQLConsole runs a read-only query and streams the result as JSON or,
+// with format=csv, as CSV. Errors that occur after streaming has started are
+// reported in the "error" member of the JSON body or the X-Error trailer of
+// CSV; X-Truncated tells CSV clients that the row cap was hit.
+func (s *Server) handleSQLConsole(c *gin.Context) {
+	console := s.SQLConsole
+	if console == nil {
+		respondError(c, http.StatusNotFound, codeNotFound, "The SQL console is not enabled")
+		return
+	}
+
+	token, bearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !bearer || subtle.ConstantTimeCompare([]byte(token), []byte(console.Token)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		respondError(c, http.StatusUnauthorized, codeUnauthorized, "A valid bearer token is required")
+		return
+	}
+
+	var req sqlConsoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		respondBadRequest(c, `The body must be a JSON object with a non-empty "query"`)
+		return
+	}
+
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		w := export.NewJSONRows(c.Writer)
+		truncated, err := db.RunConsoleQuery(c, console.DB, req.Query, console.Options, w)
+		if err != nil && !w.Started() {
+			respondDBError(c, err, "query results")
+			return
+		}
+		w.Finish(truncated, err)
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Trailer", "X-Truncated, X-Error")
+		w := export.NewCSVRows(c.Writer)
+		truncated, err := db.RunConsoleQuery(c, console.DB, req.Query, console.Options, w)
+		if err != nil && !w.Started() {
+			c.Header("Trailer", "")
+			respondDBError(c, err, "query results")
+			return
+		}
+		w.Finish()
+		if truncated {
+			c.Writer.Header().Set("X-Truncated", "true")
+		}
+		if err != nil {
+			c.Writer.Header().Set("X-Error", err.Error())
+		}
+	default:
+		respondBadRequest(c, "format must be json or csv")
+	}
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"liftmetrics/internal/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postSQL(t *testing.T, s *Server, target, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	s.Router.ServeHTTP(w, req)
+	return w
+}
+
+// TestSQLConsole tests authentication, formats and the refusal of writes on
+// the SQL console route.
+func TestSQLConsole(t *testing.T) {
+	s := newTestServer(t, testRecords)
+	query := `{"query": "SELECT MeetName, TotalKg FROM records ORDER BY Date"}`
+
+	if w := postSQL(t, s, "/api/v1/sql", "secret", query); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 while disabled, got %d: %s", w.Code, w.Body)
+	}
+
+	var dbPath string
+	if err := s.DB.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&dbPath); err != nil {
+		t.Fatalf("Failed to find the database file: %v", err)
+	}
+	readOnly, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer readOnly.Close()
+	s.SQLConsole = &SQLConsoleConfig{DB: readOnly, Token: "secret", Options: db.ConsoleOptions{Timeout: db.DefaultConsoleOptions.Timeout, MaxRows: 1}}
+
+	t.Run("Wrong token", func(t *testing.T) {
+		if w := postSQL(t, s, "/api/v1/sql", "guess", query); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("No bearer scheme", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sql", strings.NewReader(query))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "secret")
+		s.Router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := postSQL(t, s, "/api/v1/sql", "secret", query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var result struct {
+			Columns   []string        `json:"columns"`
+			Rows      [][]interface{} `json:"rows"`
+			Truncated bool            `json:"truncated"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode result %s: %v", w.Body, err)
+		}
+		if len(result.Rows) != 1 || result.Rows[0][0] != "Spring Open" || !result.Truncated {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		w := postSQL(t, s, "/api/v1/sql?format=csv", "secret", query)
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse CSV: %v", err)
+		}
+		if len(records) != 2 || records[1][1] != "620" {
+			t.Errorf("Unexpected CSV: %v", records)
+		}
+		if got := w.Result().Trailer.Get("X-Truncated"); got != "true" {
+			t.Errorf("Expected the X-Truncated trailer, got %q", got)
+		}
+	})
+
+	t.Run("Write refused", func(t *testing.T) {
+		w := postSQL(t, s, "/api/v1/sql", "secret", `{"query": "DELETE FROM records"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d: %s", w.Code, w.Body)
+		}
+	})
+}